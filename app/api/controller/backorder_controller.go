@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	BackorderWaiting   = "waiting"
+	BackorderFulfilled = "fulfilled"
+	BackorderCancelled = "cancelled"
+)
+
+// События листа ожидания
+const (
+	EventBackorderCreated   = "backorder.created"
+	EventBackorderAllocated = "backorder.allocated"
+	EventBackorderFulfilled = "backorder.fulfilled"
+	EventBackorderCancelled = "backorder.cancelled"
+)
+
+// Backorder — спрос на товар, которого не было в наличии. Лист ожидания товара обслуживается
+// в порядке ID; Fulfilled — сколько единиц уже зарезервировано.
+type Backorder struct {
+	ID          int64     `json:"id"`
+	ProductID   int       `json:"product_id"`
+	Code        string    `json:"code"`
+	WarehouseID int       `json:"warehouse_id"`
+	Reference   *string   `json:"reference,omitempty"`
+	Quantity    int       `json:"quantity"`
+	Fulfilled   int       `json:"fulfilled"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BackorderEvent — тело событий листа ожидания. Allocated — сколько единиц зарезервировано этим изменением.
+type BackorderEvent struct {
+	BackorderID int64  `json:"backorder_id"`
+	ProductID   int    `json:"product_id"`
+	Code        string `json:"code"`
+	WarehouseID int    `json:"warehouse_id"`
+	Reference   string `json:"reference,omitempty"`
+	Quantity    int    `json:"quantity"`
+	Fulfilled   int    `json:"fulfilled"`
+	Allocated   int    `json:"allocated,omitempty"`
+}
+
+// ReservationResult — итог резерва с листом ожидания: зарезервированные коды и созданные заявки
+type ReservationResult struct {
+	Reserved   []string    `json:"reserved"`
+	Backorders []Backorder `json:"backorders"`
+}
+
+// ReserveProductsWithBackorder резервирует товары как ReserveProducts, но единицы, которых нет в наличии
+// или которые лежат на недоступном сейчас складе, ставит в лист ожидания вместо ошибки. Если у товара уже есть ожидающие заявки, новый спрос
+// встает за ними, чтобы не обходить очередь.
+func ReserveProductsWithBackorder(db *sql.DB, productCodes []string, reference string) (*ReservationResult, error) {
+	if len(productCodes) == 0 {
+		return nil, ErrEmptyProductCodes
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &ReservationResult{Reserved: []string{}, Backorders: []Backorder{}}
+	var queued []int
+	missing := make(map[int]int)
+	for _, code := range productCodes {
+		codes, err := expandCode(tx, code)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, code := range codes {
+			var productID int
+			var waiting bool
+			err := tx.QueryRow(`SELECT id, EXISTS (SELECT 1 FROM backorders b WHERE b.product_id = p.id AND b.status = $2)
+				FROM products p WHERE code = $1`, code, BackorderWaiting).Scan(&productID, &waiting)
+			if err != nil {
+				return nil, err
+			}
+
+			if !waiting {
+				p, err := reserveUnit(tx, code, reference)
+				if err == nil {
+					if err := reserveAnySerial(tx, p); err != nil {
+						return nil, err
+					}
+					result.Reserved = append(result.Reserved, code)
+					continue
+				}
+				if !errors.Is(err, ErrOutOfStock) && !errors.Is(err, ErrWarehouseClosed) {
+					return nil, err
+				}
+			}
+
+			if missing[productID] == 0 {
+				queued = append(queued, productID)
+			}
+			missing[productID]++
+		}
+	}
+
+	for _, productID := range queued {
+		b, err := createBackorder(tx, productID, missing[productID], reference)
+		if err != nil {
+			return nil, err
+		}
+		result.Backorders = append(result.Backorders, *b)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func createBackorder(tx *sql.Tx, productID, quantity int, reference string) (*Backorder, error) {
+	b := Backorder{ProductID: productID, Quantity: quantity, Status: BackorderWaiting}
+	err := tx.QueryRow(`WITH b AS (
+			INSERT INTO backorders(product_id, reference, quantity) VALUES($1, NULLIF($2, ''), $3)
+			RETURNING id, created_at, updated_at
+		)
+		SELECT b.id, b.created_at, b.updated_at, p.code, p.warehouse_id FROM b, products p WHERE p.id = $1`,
+		productID, reference, quantity,
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt, &b.Code, &b.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if reference != "" {
+		b.Reference = &reference
+	}
+
+	return &b, enqueueBackorderEvent(tx, EventBackorderCreated, &b, 0)
+}
+
+func enqueueBackorderEvent(q queryer, eventType string, b *Backorder, allocated int) error {
+	e := BackorderEvent{
+		BackorderID: b.ID,
+		ProductID:   b.ProductID,
+		Code:        b.Code,
+		WarehouseID: b.WarehouseID,
+		Quantity:    b.Quantity,
+		Fulfilled:   b.Fulfilled,
+		Allocated:   allocated,
+	}
+	if b.Reference != nil {
+		e.Reference = *b.Reference
+	}
+
+	return enqueueEvent(q, eventType, productEventKey(b.ProductID), e)
+}
+
+// fulfillBackorders превращает ожидающие заявки в резервы, пока у товаров есть остаток.
+// Заявки обслуживаются по очереди; замороженные инвентаризацией товары пропускаются, а заявки на товары
+// недоступного сейчас склада ждут, пока планировщик календарей его не откроет.
+// Вызывается из recordMovement при любом увеличении остатка и из импорта, который пишет движения пачкой.
+func fulfillBackorders(tx *sql.Tx, productIDs []int) error {
+	for _, productID := range productIDs {
+		if err := checkNotFrozen(tx, productID); errors.Is(err, ErrProductFrozen) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		waiting, err := listBackorders(tx, `WHERE b.product_id = $1 AND b.status = $2 ORDER BY b.id FOR UPDATE OF b`,
+			productID, BackorderWaiting)
+		if err != nil {
+			return err
+		}
+
+		for i := range waiting {
+			b := &waiting[i]
+			if err := allocateBackorder(tx, b); err != nil {
+				return err
+			}
+			if b.Fulfilled < b.Quantity {
+				// остаток закончился, следующие заявки подождут
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// allocateBackorder резервирует для заявки столько единиц, сколько есть в наличии
+func allocateBackorder(tx *sql.Tx, b *Backorder) error {
+	reference := "backorder:" + strconv.FormatInt(b.ID, 10)
+
+	allocated := 0
+	for b.Fulfilled+allocated < b.Quantity {
+		p, err := takeUnit(tx, b.Code, reference, 0, false)
+		if errors.Is(err, ErrOutOfStock) || errors.Is(err, ErrWarehouseClosed) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := reserveAnySerial(tx, p); err != nil {
+			return err
+		}
+		allocated++
+	}
+	if allocated == 0 {
+		return nil
+	}
+
+	b.Fulfilled += allocated
+	eventType := EventBackorderAllocated
+	if b.Fulfilled == b.Quantity {
+		b.Status = BackorderFulfilled
+		eventType = EventBackorderFulfilled
+	}
+
+	err := tx.QueryRow("UPDATE backorders SET fulfilled = $2, status = $3, updated_at = now() WHERE id = $1 RETURNING updated_at",
+		b.ID, b.Fulfilled, b.Status).Scan(&b.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return enqueueBackorderEvent(tx, eventType, b, allocated)
+}
+
+func listBackorders(q queryer, where string, args ...interface{}) ([]Backorder, error) {
+	rows, err := q.Query(`SELECT b.id, b.product_id, p.code, p.warehouse_id, b.reference, b.quantity, b.fulfilled,
+			b.status, b.created_at, b.updated_at
+		FROM backorders b JOIN products p ON p.id = b.product_id `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backorders := []Backorder{}
+	for rows.Next() {
+		var b Backorder
+		var reference sql.NullString
+		if err := rows.Scan(&b.ID, &b.ProductID, &b.Code, &b.WarehouseID, &reference, &b.Quantity, &b.Fulfilled,
+			&b.Status, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if reference.Valid {
+			b.Reference = &reference.String
+		}
+		backorders = append(backorders, b)
+	}
+
+	return backorders, rows.Err()
+}
+
+//	@Summary		List backorders.
+//	@Description	List backorders in waitlist order, optionally for one product code and status.
+//	@Tags			backorders
+//	@Produce		json
+//	@Param			code	query		string			false	"Product code or barcode"
+//	@Param			status	query		string			false	"waiting, fulfilled or cancelled"
+//	@Success		200		{array}		Backorder
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/backorders [get]
+//
+func GetBackorders(db *sql.DB, code, status string) ([]Backorder, error) {
+	if code != "" {
+		resolved, err := resolveCode(db, code)
+		if err != nil {
+			return nil, err
+		}
+		code = resolved
+	}
+
+	return listBackorders(db, `WHERE ($1 = '' OR p.code = $1) AND ($2 = '' OR b.status = $2) ORDER BY b.id`, code, status)
+}
+
+//	@Summary		Cancel a backorder.
+//	@Description	Remove a waiting backorder from the waitlist. Units already allocated to it stay reserved and must be released separately.
+//	@Tags			backorders
+//	@Produce		json
+//	@Param			id	path		int				true	"Backorder ID"
+//	@Success		200	{object}	Backorder
+//	@Failure		404	{object}	ErrorResponse	"Backorder not found"
+//	@Failure		409	{object}	ErrorResponse	"Backorder is not waiting"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/backorders/{id} [delete]
+//
+func CancelBackorder(db *sql.DB, id int64) (*Backorder, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	backorders, err := listBackorders(tx, "WHERE b.id = $1 FOR UPDATE OF b", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(backorders) == 0 {
+		return nil, ErrNotFound
+	}
+	b := &backorders[0]
+	if b.Status != BackorderWaiting {
+		return nil, ErrInvalidStatus
+	}
+
+	err = tx.QueryRow("UPDATE backorders SET status = $2, updated_at = now() WHERE id = $1 RETURNING updated_at",
+		id, BackorderCancelled).Scan(&b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	b.Status = BackorderCancelled
+
+	if err := enqueueBackorderEvent(tx, EventBackorderCancelled, b, 0); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
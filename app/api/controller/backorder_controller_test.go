@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReserveWithBackorderQueuesMissingUnits(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+
+	result, err := ReserveProductsWithBackorder(db, []string{p.Code, p.Code, p.Code}, "cart-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Reserved) != 1 {
+		t.Errorf("Expected 1 reserved unit, got %d", len(result.Reserved))
+	}
+	if len(result.Backorders) != 1 || result.Backorders[0].Quantity != 2 {
+		t.Fatalf("Expected one backorder for 2 units, got %+v", result.Backorders)
+	}
+
+	backorders, err := GetBackorders(db, p.Code, BackorderWaiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backorders) != 1 || *backorders[0].Reference != "cart-1" {
+		t.Errorf("Expected waiting backorder with reference cart-1, got %+v", backorders)
+	}
+
+	// возвращенная единица сразу уходит листу ожидания
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	backorders, err = GetBackorders(db, p.Code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backorders[0].Fulfilled != 1 || backorders[0].Status != BackorderWaiting {
+		t.Errorf("Expected released unit to be allocated to the backorder, got %+v", backorders[0])
+	}
+	if q := productQuantity(t, db, p.ID); q != 0 {
+		t.Errorf("Expected released unit to be reserved again, got quantity %d", q)
+	}
+}
+
+func TestBackordersFillInOrder(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	first, err := ReserveProductsWithBackorder(db, []string{p.Code, p.Code}, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ReserveProductsWithBackorder(db, []string{p.Code}, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 1}}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	backorders, err := GetBackorders(db, p.Code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backorders) != 2 {
+		t.Fatalf("Expected 2 backorders, got %d", len(backorders))
+	}
+	if b := backorders[0]; b.ID != first.Backorders[0].ID || b.Fulfilled != 1 || b.Status != BackorderWaiting {
+		t.Errorf("Expected first backorder to be partly filled, got %+v", b)
+	}
+	if b := backorders[1]; b.Fulfilled != 0 {
+		t.Errorf("Expected second backorder to wait, got %+v", b)
+	}
+	if q := productQuantity(t, db, p.ID); q != 0 {
+		t.Errorf("Expected received unit to be reserved, got quantity %d", q)
+	}
+
+	// новый спрос встает в очередь, даже если остаток появился
+	result, err := ReserveProductsWithBackorder(db, []string{p.Code}, "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Reserved) != 0 || len(result.Backorders) != 1 {
+		t.Errorf("Expected new demand to be queued, got %+v", result)
+	}
+
+	if _, err := CancelBackorder(db, second.Backorders[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CancelBackorder(db, second.Backorders[0].ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+
+	r = &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 2}}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	backorders, err = GetBackorders(db, p.Code, BackorderFulfilled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backorders) != 2 || backorders[0].ID != first.Backorders[0].ID || backorders[1].ID != result.Backorders[0].ID {
+		t.Errorf("Expected first and third backorders to be fulfilled, got %+v", backorders)
+	}
+}
+
+func TestBackordersFillOnAnyStockIncrease(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	queued, err := ReserveProductsWithBackorder(db, []string{p.Code, p.Code}, "queued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitingFor := func(want int) {
+		t.Helper()
+		backorders, err := GetBackorders(db, p.Code, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(backorders) != 1 || backorders[0].ID != queued.Backorders[0].ID || backorders[0].Fulfilled != want {
+			t.Errorf("Expected the backorder to have %d units, got %+v", want, backorders)
+		}
+	}
+
+	// приемка партии, как и поставка, сразу отдает остаток листу ожидания
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waitingFor(1)
+
+	// пока товар заморожен, заявка ждет, а после разморозки остаток остается за ней
+	cc := &CycleCount{WarehouseID: w.ID, FreezeReservations: true}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waitingFor(1)
+	if err := CancelCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock while the backorder waits, got %v", err)
+	}
+
+	// излишек, найденный пересчетом, тоже получает лист ожидания
+	cc = &CycleCount{WarehouseID: w.ID, FreezeReservations: true}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApproveCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitingFor(2)
+	if q := productQuantity(t, db, p.ID); q != 1 {
+		t.Errorf("Expected one unit left after the backorder is filled, got %d", q)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Errorf("Expected a reservation to succeed once the waitlist is empty, got %v", err)
+	}
+}
+
+func TestCancelBackorderNotFound(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := CancelBackorder(db, -1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/barcode"
+	"github.com/lib/pq"
+)
+
+// codeValidator проверяет коды товаров и штрихкоды; настраивается при старте приложения
+var codeValidator = barcode.DefaultValidator()
+
+// SetCodeValidator задает правила проверки кодов товаров
+func SetCodeValidator(v *barcode.Validator) {
+	codeValidator = v
+}
+
+type ProductBarcode struct {
+	Barcode string `json:"barcode"`
+	Format  string `json:"format"`
+}
+
+//	@Summary		Attach a barcode to a product.
+//	@Description	Attach an additional barcode to a product. The product can then be found by this barcode in reserve and release calls.
+//	@Description	A barcode must not match any product code or another barcode.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	path		string			true	"Product code"
+//	@Param			barcode	body		ProductBarcode	true	"Barcode"
+//	@Success		201		{object}	ProductBarcode
+//	@Failure		400		{object}	ErrorResponse	"Invalid barcode"
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Failure		409		{object}	ErrorResponse	"Barcode is already used"
+//	@Router			/products/{code}/barcodes [post]
+//
+func AddProductBarcode(db *sql.DB, code string, b *ProductBarcode) error {
+	normalized, format, err := validateCode(b.Barcode)
+	if err != nil {
+		return err
+	}
+	b.Barcode, b.Format = normalized, format
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	productCode, err := resolveCode(tx, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var taken bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE code = $1)
+		OR EXISTS (SELECT 1 FROM product_barcodes WHERE barcode = $1)`, b.Barcode).Scan(&taken)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if taken {
+		tx.Rollback()
+		return ErrDuplicateCode
+	}
+
+	_, err = tx.Exec(`INSERT INTO product_barcodes(barcode, product_id, format)
+		SELECT $1, id, $2 FROM products WHERE code = $3`, b.Barcode, b.Format, productCode)
+	if err != nil {
+		tx.Rollback()
+		return duplicateCodeError(err)
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Look up a product.
+//	@Description	Find a product by its code or any of its barcodes. The code as given wins over the normalized code, which wins over barcodes.
+//	@Tags			products
+//	@Produce		json
+//	@Param			code	path		string			true	"Product code or barcode"
+//	@Success		200		{object}	Product
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Router			/products/{code} [get]
+//
+func GetProductByCode(db *sql.DB, code string) (*Product, error) {
+	productCode, err := resolveCode(db, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Product
+	err = db.QueryRow(
+		"SELECT id, name, size, code, quantity, warehouse_id, serial_tracked, is_bundle, version FROM products WHERE code = $1", productCode,
+	).Scan(&p.ID, &p.Name, &p.Size, &p.Code, &p.Quantity, &p.WarehouseID, &p.SerialTracked, &p.IsBundle, &p.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// validateCode нормализует и проверяет код товара или штрихкод
+func validateCode(code string) (string, string, error) {
+	normalized, format, err := codeValidator.Validate(code)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidCode, err)
+	}
+
+	return normalized, format, nil
+}
+
+// resolveCode находит основной код товара по коду или любому из его штрихкодов.
+// Код в исходном виде важнее нормализованного, а код товара важнее штрихкода.
+func resolveCode(q queryer, code string) (string, error) {
+	trimmed := strings.TrimSpace(code)
+
+	var productCode string
+	err := q.QueryRow(`SELECT code FROM (
+			SELECT code, CASE WHEN code = $1 THEN 0 ELSE 1 END AS rank FROM products WHERE code = $1 OR code = $2
+			UNION ALL
+			SELECT p.code, 2 FROM product_barcodes b JOIN products p ON p.id = b.product_id WHERE b.barcode = $2
+		) c ORDER BY rank LIMIT 1`, trimmed, barcode.Normalize(code)).Scan(&productCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return productCode, nil
+}
+
+// duplicateCodeError переводит нарушение уникальности кода товара или штрихкода в ErrDuplicateCode.
+// Код товара не может совпадать со штрихкодом другого товара, это проверяет триггер в базе.
+func duplicateCodeError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateCode
+	}
+
+	return err
+}
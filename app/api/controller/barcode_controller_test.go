@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestCreateProductNormalizesCode(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	p := &Product{Name: utils.RandomString(6), Code: "  sku-" + utils.RandomString(8) + " ", Quantity: 1, WarehouseID: w.ID}
+	if err := CreateProduct(db, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code[:4] != "SKU-" || p.Code[len(p.Code)-1] == ' ' {
+		t.Errorf("Expected trimmed upper-case code, got %q", p.Code)
+	}
+
+	empty := &Product{Name: utils.RandomString(6), Code: "   ", Quantity: 1, WarehouseID: w.ID}
+	if err := CreateProduct(db, empty); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected ErrInvalidCode for empty code, but got %v", err)
+	}
+
+	badEAN := &Product{Name: utils.RandomString(6), Code: "4006381333932", Quantity: 1, WarehouseID: w.ID}
+	if err := CreateProduct(db, badEAN); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected ErrInvalidCode for bad EAN-13 checksum, but got %v", err)
+	}
+}
+
+func TestReserveProductsByBarcode(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 2)
+
+	b := &ProductBarcode{Barcode: randomEAN13()}
+	if err := AddProductBarcode(db, p.Code, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{b.Barcode}); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 1 {
+		t.Errorf("Expected product quantity to be 1, but got %d", q)
+	}
+
+	if err := ReleaseProducts(db, []string{b.Barcode}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetProductByCode(db, b.Barcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != p.ID || got.Quantity != 2 {
+		t.Errorf("Expected product %d with quantity 2, got %+v", p.ID, got)
+	}
+
+	if err := AddProductBarcode(db, p.Code, &ProductBarcode{Barcode: b.Barcode}); !errors.Is(err, ErrDuplicateCode) {
+		t.Errorf("Expected ErrDuplicateCode, but got %v", err)
+	}
+}
+
+// randomEAN13 генерирует EAN-13 с корректной контрольной цифрой
+func randomEAN13() string {
+	body := "2"
+	for len(body) < 12 {
+		body += string(rune('0' + utils.RandomInt(1)))
+	}
+
+	sum := 0
+	for i := len(body) - 1; i >= 0; i-- {
+		d := int(body[i] - '0')
+		if (len(body)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return body + string(rune('0'+(10-sum%10)%10))
+}
+
+func TestProductCodeAndBarcodeDoNotOverlap(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+	other := createTestProduct(t, db, w.ID, 1)
+
+	b := &ProductBarcode{Barcode: randomEAN13()}
+	if err := AddProductBarcode(db, p.Code, b); err != nil {
+		t.Fatal(err)
+	}
+
+	clash := &Product{Name: utils.RandomString(6), Code: b.Barcode, WarehouseID: w.ID}
+	if err := CreateProduct(db, clash); !errors.Is(err, ErrDuplicateCode) {
+		t.Errorf("Expected ErrDuplicateCode for a code used as a barcode, but got %v", err)
+	}
+	if err := AddProductBarcode(db, p.Code, &ProductBarcode{Barcode: other.Code}); !errors.Is(err, ErrDuplicateCode) {
+		t.Errorf("Expected ErrDuplicateCode for a barcode used as a product code, but got %v", err)
+	}
+
+	got, err := resolveCode(db, other.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != other.Code {
+		t.Errorf("Expected %s, got %s", other.Code, got)
+	}
+}
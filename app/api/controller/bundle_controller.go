@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Bundle — набор товаров, который резервируется одним кодом.
+// Доступное количество набора вычисляется из остатков компонентов.
+type Bundle struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Size        string            `json:"size"`
+	Code        string            `json:"code"`
+	WarehouseID int               `json:"warehouse_id"`
+	Available   int               `json:"available"`
+	Components  []BundleComponent `json:"components"`
+}
+
+type BundleComponent struct {
+	Code      string `json:"code"`
+	Quantity  int    `json:"quantity"`
+	Available int    `json:"available"`
+}
+
+//	@Summary		Create a bundle.
+//	@Description	Create a bundle product defined by component codes and quantities. Components must be regular products of the same warehouse.
+//	@Description	A component code listed several times is merged into one component with the summed quantity.
+//	@Tags			bundles
+//	@Accept			json
+//	@Produce		json
+//	@Param			bundle	body		Bundle			true	"Bundle information"
+//	@Success		201		{object}	Bundle
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Component not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/bundles [post]
+//
+func CreateBundle(db *sql.DB, b *Bundle) error {
+	if len(b.Components) == 0 {
+		return ErrInvalidBundle
+	}
+
+	code, _, err := validateCode(b.Code)
+	if err != nil {
+		return err
+	}
+	b.Code = code
+	b.Components = mergeComponents(b.Components)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO products(name, size, code, quantity, warehouse_id, is_bundle) VALUES($1, $2, $3, 0, $4, TRUE) RETURNING id",
+		b.Name, b.Size, b.Code, b.WarehouseID,
+	).Scan(&b.ID)
+	if err != nil {
+		tx.Rollback()
+		return duplicateCodeError(err)
+	}
+
+	for _, c := range b.Components {
+		if c.Quantity <= 0 {
+			tx.Rollback()
+			return ErrInvalidQuantity
+		}
+
+		var componentID, warehouseID int
+		var isBundle bool
+		err := tx.QueryRow("SELECT id, warehouse_id, is_bundle FROM products WHERE code = $1", c.Code).Scan(&componentID, &warehouseID, &isBundle)
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return ErrNotFound
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if isBundle || warehouseID != b.WarehouseID {
+			tx.Rollback()
+			return ErrInvalidBundle
+		}
+
+		_, err = tx.Exec("INSERT INTO bundle_components(bundle_id, component_id, quantity) VALUES($1, $2, $3)", b.ID, componentID, c.Quantity)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mergeComponents складывает количества повторяющихся кодов компонентов, сохраняя порядок первого упоминания.
+// Неположительные количества не складываются, чтобы их отклонила проверка компонентов.
+func mergeComponents(components []BundleComponent) []BundleComponent {
+	merged := make([]BundleComponent, 0, len(components))
+	index := make(map[string]int, len(components))
+	for _, c := range components {
+		if i, ok := index[c.Code]; ok && c.Quantity > 0 {
+			merged[i].Quantity += c.Quantity
+			continue
+		}
+		index[c.Code] = len(merged)
+		merged = append(merged, c)
+	}
+
+	return merged
+}
+
+//	@Summary		Get a bundle.
+//	@Description	Get a bundle with its components and the number of bundles that can be reserved from component stock.
+//	@Tags			bundles
+//	@Produce		json
+//	@Param			code	path		string			true	"Bundle code"
+//	@Success		200		{object}	Bundle
+//	@Failure		404		{object}	ErrorResponse	"Bundle not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/bundles/{code} [get]
+//
+func GetBundle(db *sql.DB, code string) (*Bundle, error) {
+	var b Bundle
+	err := db.QueryRow(
+		"SELECT id, name, size, code, warehouse_id FROM products WHERE code = $1 AND is_bundle", code,
+	).Scan(&b.ID, &b.Name, &b.Size, &b.Code, &b.WarehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT p.code, c.quantity, `+availableSQL+`
+		FROM bundle_components c JOIN products p ON p.id = c.component_id
+		WHERE c.bundle_id = $1 ORDER BY p.code`, b.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c BundleComponent
+		if err := rows.Scan(&c.Code, &c.Quantity, &c.Available); err != nil {
+			return nil, err
+		}
+		b.Components = append(b.Components, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	b.Available = bundleAvailability(b.Components)
+
+	return &b, nil
+}
+
+// bundleAvailability возвращает количество наборов, которое можно собрать из остатков компонентов
+func bundleAvailability(components []BundleComponent) int {
+	if len(components) == 0 {
+		return 0
+	}
+
+	available := -1
+	for _, c := range components {
+		n := c.Available / c.Quantity
+		if n < 0 {
+			n = 0
+		}
+		if available == -1 || n < available {
+			available = n
+		}
+	}
+
+	return available
+}
+
+// fillBundleQuantities заменяет количество наборов склада на количество, доступное из компонентов
+func fillBundleQuantities(q queryer, warehouseID int, products []Product) error {
+	rows, err := q.Query(`SELECT c.bundle_id, c.component_id, c.quantity
+		FROM bundle_components c JOIN products b ON b.id = c.bundle_id
+		WHERE b.warehouse_id = $1`, warehouseID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	available := make(map[int]int, len(products))
+	for _, p := range products {
+		available[p.ID] = p.Quantity
+	}
+
+	components := make(map[int][]BundleComponent)
+	for rows.Next() {
+		var bundleID, componentID int
+		var c BundleComponent
+		if err := rows.Scan(&bundleID, &componentID, &c.Quantity); err != nil {
+			return err
+		}
+		c.Available = available[componentID]
+		components[bundleID] = append(components[bundleID], c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range products {
+		if products[i].IsBundle {
+			products[i].Quantity = bundleAvailability(components[products[i].ID])
+		}
+	}
+
+	return nil
+}
+
+type bundleLine struct {
+	code     string
+	quantity int
+}
+
+// getBundleLines возвращает компоненты набора или nil, если товар не является набором
+func getBundleLines(tx *sql.Tx, code string) ([]bundleLine, error) {
+	var bundleID int
+	var isBundle bool
+	err := tx.QueryRow("SELECT id, is_bundle FROM products WHERE code = $1", code).Scan(&bundleID, &isBundle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil || !isBundle {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`SELECT p.code, c.quantity FROM bundle_components c JOIN products p ON p.id = c.component_id
+		WHERE c.bundle_id = $1 ORDER BY p.id`, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []bundleLine
+	for rows.Next() {
+		var l bundleLine
+		if err := rows.Scan(&l.code, &l.quantity); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+// expandCode раскладывает код набора на коды компонентов с учетом количества.
+// Код обычного товара возвращается как есть; штрихкоды заменяются основным кодом товара.
+func expandCode(tx *sql.Tx, code string) ([]string, error) {
+	code, err := resolveCode(tx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := getBundleLines(tx, code)
+	if err != nil {
+		return nil, err
+	}
+	if lines == nil {
+		return []string{code}, nil
+	}
+
+	var codes []string
+	for _, l := range lines {
+		for i := 0; i < l.quantity; i++ {
+			codes = append(codes, l.code)
+		}
+	}
+
+	return codes, nil
+}
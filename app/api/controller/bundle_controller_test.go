@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestReserveBundleReservesComponents(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	shirt := createTestProduct(t, db, w.ID, 5)
+	socks := createTestProduct(t, db, w.ID, 4)
+
+	b := &Bundle{
+		Name:        utils.RandomString(6),
+		Code:        utils.RandomString(10),
+		WarehouseID: w.ID,
+		Components:  []BundleComponent{{Code: shirt.Code, Quantity: 1}, {Code: socks.Code, Quantity: 2}},
+	}
+	if err := CreateBundle(db, b); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetBundle(db, b.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Available != 2 {
+		t.Errorf("Expected 2 bundles available, got %d", got.Available)
+	}
+
+	if err := ReserveProducts(db, []string{b.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, shirt.ID); q != 4 {
+		t.Errorf("Expected shirt quantity to be 4, but got %d", q)
+	}
+	if q := productQuantity(t, db, socks.ID); q != 2 {
+		t.Errorf("Expected socks quantity to be 2, but got %d", q)
+	}
+
+	if err := ReleaseProducts(db, []string{b.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, socks.ID); q != 4 {
+		t.Errorf("Expected socks quantity to be 4 after release, but got %d", q)
+	}
+}
+
+func TestReserveBundleIsAtomic(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	shirt := createTestProduct(t, db, w.ID, 5)
+	socks := createTestProduct(t, db, w.ID, 1)
+
+	b := &Bundle{
+		Name:        utils.RandomString(6),
+		Code:        utils.RandomString(10),
+		WarehouseID: w.ID,
+		Components:  []BundleComponent{{Code: shirt.Code, Quantity: 1}, {Code: socks.Code, Quantity: 2}},
+	}
+	if err := CreateBundle(db, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{b.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock, but got %v", err)
+	}
+	if q := productQuantity(t, db, shirt.ID); q != 5 {
+		t.Errorf("Expected shirt quantity to stay 5, but got %d", q)
+	}
+
+	products, err := GetRemainingProducts(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range products {
+		if p.Code == b.Code && p.Quantity != 0 {
+			t.Errorf("Expected bundle availability to be 0, got %d", p.Quantity)
+		}
+	}
+}
+
+func TestCreateBundleMergesDuplicateComponents(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	socks := createTestProduct(t, db, w.ID, 4)
+
+	b := &Bundle{
+		Code:        utils.RandomString(10),
+		WarehouseID: w.ID,
+		Components:  []BundleComponent{{Code: socks.Code, Quantity: 1}, {Code: socks.Code, Quantity: 1}},
+	}
+	if err := CreateBundle(db, b); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetBundle(db, b.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Components) != 1 || got.Components[0].Quantity != 2 || got.Available != 2 {
+		t.Errorf("Expected one component of 2 units and 2 bundles available, got %+v", got)
+	}
+
+	bin := createTestBin(t, db, w.ID)
+	if err := ReceiveToBin(db, bin.ID, b.Code, 1); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for a bin receipt, but got %v", err)
+	}
+	if err := ReceiveLot(db, &StockLot{Code: b.Code, LotNumber: "L-1", Quantity: 1}); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle for a lot receipt, but got %v", err)
+	}
+}
@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/calendar"
+)
+
+// AvailabilityDisabled — склад открыт по календарю, но выключен вручную
+const AvailabilityDisabled = "disabled"
+
+// WarehouseCalendar — график работы склада: часовой пояс, рабочие часы по дням недели и праздники.
+// Без рабочих часов склад работает круглосуточно. Maintenance — текущие и будущие окна обслуживания, только для чтения.
+type WarehouseCalendar struct {
+	Timezone    string              `json:"timezone"`
+	Hours       []calendar.Hours    `json:"hours"`
+	Holidays    []Holiday           `json:"holidays"`
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// Holiday — нерабочий день склада по местной дате
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name,omitempty"`
+}
+
+// MaintenanceWindow — период, когда склад закрыт на обслуживание
+type MaintenanceWindow struct {
+	ID          int       `json:"id"`
+	WarehouseID int       `json:"warehouse_id"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// WarehouseAvailability — доступность склада в момент At с учетом календаря и ручного флага.
+// Reason объясняет недоступность: maintenance, holiday, closed или disabled.
+type WarehouseAvailability struct {
+	WarehouseID int       `json:"warehouse_id"`
+	At          time.Time `json:"at"`
+	IsAvailable bool      `json:"is_available"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+func (c *WarehouseCalendar) validate() (calendar.Calendar, error) {
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return calendar.Calendar{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalidCalendar, c.Timezone)
+	}
+
+	cal := calendar.Calendar{Location: loc, Hours: c.Hours}
+	for _, h := range c.Holidays {
+		cal.Holidays = append(cal.Holidays, h.Date)
+	}
+	if err := cal.Validate(); err != nil {
+		return calendar.Calendar{}, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+
+	return cal, nil
+}
+
+//	@Summary		Set warehouse calendar.
+//	@Description	Replace the timezone, weekly operating hours and holidays of a warehouse. Hours are local "HH:MM" intervals per weekday (0 is Sunday); a warehouse without hours operates around the clock. Availability follows the calendar immediately; the scheduler flips is_available and emits warehouse.online and warehouse.offline events.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Warehouse ID"
+//	@Param			calendar	body		WarehouseCalendar	true	"Calendar"
+//	@Success		200			{object}	WarehouseCalendar
+//	@Failure		400			{object}	ErrorResponse		"Invalid calendar"
+//	@Failure		404			{object}	ErrorResponse		"Warehouse not found"
+//	@Failure		500			{object}	ErrorResponse		"Internal server error"
+//	@Router			/warehouses/{id}/calendar [put]
+//
+func SetWarehouseCalendar(db *sql.DB, warehouseID int, c *WarehouseCalendar) error {
+	if _, err := c.validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE warehouse SET timezone = $1 WHERE id = $2", c.Timezone, warehouseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec("DELETE FROM warehouse_hours WHERE warehouse_id = $1", warehouseID); err != nil {
+		return err
+	}
+	for _, h := range c.Hours {
+		_, err := tx.Exec("INSERT INTO warehouse_hours(warehouse_id, weekday, opens, closes) VALUES($1, $2, $3, $4)",
+			warehouseID, int(h.Weekday), h.Opens, h.Closes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM warehouse_holidays WHERE warehouse_id = $1", warehouseID); err != nil {
+		return err
+	}
+	for _, h := range c.Holidays {
+		_, err := tx.Exec(`INSERT INTO warehouse_holidays(warehouse_id, holiday, name) VALUES($1, $2, NULLIF($3, ''))
+			ON CONFLICT (warehouse_id, holiday) DO UPDATE SET name = EXCLUDED.name`, warehouseID, h.Date, h.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Get warehouse calendar.
+//	@Description	Get the timezone, weekly operating hours and holidays of a warehouse with its current and upcoming maintenance windows.
+//	@Tags			warehouses
+//	@Produce		json
+//	@Param			id	path		int				true	"Warehouse ID"
+//	@Success		200	{object}	WarehouseCalendar
+//	@Failure		404	{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/calendar [get]
+//
+func GetWarehouseCalendar(db *sql.DB, warehouseID int) (*WarehouseCalendar, error) {
+	c := WarehouseCalendar{Hours: []calendar.Hours{}, Holidays: []Holiday{}}
+	err := db.QueryRow("SELECT timezone FROM warehouse WHERE id = $1", warehouseID).Scan(&c.Timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Hours, err = warehouseHours(db, warehouseID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT to_char(holiday, 'YYYY-MM-DD'), COALESCE(name, '') FROM warehouse_holidays
+		WHERE warehouse_id = $1 ORDER BY holiday`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h Holiday
+		if err := rows.Scan(&h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		c.Holidays = append(c.Holidays, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.Maintenance, err = maintenanceWindows(db, warehouseID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+//	@Summary		Schedule warehouse maintenance.
+//	@Description	Schedule a maintenance window during which the warehouse is unavailable regardless of its operating hours.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Warehouse ID"
+//	@Param			window	body		MaintenanceWindow	true	"Maintenance window"
+//	@Success		201		{object}	MaintenanceWindow
+//	@Failure		400		{object}	ErrorResponse		"Invalid maintenance window"
+//	@Failure		404		{object}	ErrorResponse		"Warehouse not found"
+//	@Failure		500		{object}	ErrorResponse		"Internal server error"
+//	@Router			/warehouses/{id}/maintenance [post]
+//
+func CreateMaintenanceWindow(db *sql.DB, w *MaintenanceWindow) error {
+	if !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("%w: maintenance window must end after it starts", ErrInvalidCalendar)
+	}
+
+	err := db.QueryRow(`INSERT INTO maintenance_windows(warehouse_id, starts_at, ends_at, reason)
+		SELECT id, $2, $3, NULLIF($4, '') FROM warehouse WHERE id = $1
+		RETURNING id`, w.WarehouseID, w.StartsAt, w.EndsAt, w.Reason).Scan(&w.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+//	@Summary		Cancel warehouse maintenance.
+//	@Description	Delete a maintenance window of a warehouse.
+//	@Tags			warehouses
+//	@Param			id			path	int	true	"Warehouse ID"
+//	@Param			window_id	path	int	true	"Maintenance window ID"
+//	@Success		204
+//	@Failure		404	{object}	ErrorResponse	"Maintenance window not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/maintenance/{window_id} [delete]
+//
+func DeleteMaintenanceWindow(db *sql.DB, warehouseID, windowID int) error {
+	res, err := db.Exec("DELETE FROM maintenance_windows WHERE id = $1 AND warehouse_id = $2", windowID, warehouseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Get warehouse availability.
+//	@Description	Compute whether a warehouse is available at the given moment (now by default) from its calendar, maintenance windows and is_available flag.
+//	@Tags			warehouses
+//	@Produce		json
+//	@Param			id	path		int				true	"Warehouse ID"
+//	@Param			at	query		string			false	"Moment in RFC 3339 format"
+//	@Success		200	{object}	WarehouseAvailability
+//	@Failure		400	{object}	ErrorResponse	"Invalid moment"
+//	@Failure		404	{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/availability [get]
+//
+func GetWarehouseAvailability(db *sql.DB, warehouseID int, at time.Time) (*WarehouseAvailability, error) {
+	return availabilityAt(db, warehouseID, at)
+}
+
+// checkWarehouseOpen возвращает ErrWarehouseClosed, если склад сейчас недоступен по календарю,
+// окну обслуживания или ручному флагу. Через нее проходит каждый резерв единицы товара.
+func checkWarehouseOpen(q queryer, warehouseID int) error {
+	a, err := availabilityAt(q, warehouseID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !a.IsAvailable {
+		return ErrWarehouseClosed
+	}
+
+	return nil
+}
+
+func availabilityAt(q queryer, warehouseID int, at time.Time) (*WarehouseAvailability, error) {
+	var isAvailable, calendarClosed bool
+	err := q.QueryRow("SELECT COALESCE(is_available, false), calendar_closed FROM warehouse WHERE id = $1", warehouseID).
+		Scan(&isAvailable, &calendarClosed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return warehouseAvailability(q, warehouseID, isAvailable, calendarClosed, at)
+}
+
+// ApplyWarehouseCalendars переводит склады в состояние, которое требует календарь в момент now, и
+// возвращает количество переключенных складов. При закрытии по календарю планировщик выключает
+// доступный склад, а при открытии включает только те склады, которые выключил сам: выключенный
+// вручную склад остается выключенным. Если доступность изменилась, в outbox пишется warehouse.online
+// или warehouse.offline; окна обслуживания, целиком прошедшие между запусками, дают пару событий.
+func ApplyWarehouseCalendars(db *sql.DB, now time.Time) (int, error) {
+	ids, err := queryIDs(db, "SELECT id FROM warehouse ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+
+	switched := 0
+	for _, id := range ids {
+		ok, err := applyWarehouseCalendar(db, id, now)
+		if err != nil {
+			return switched, err
+		}
+		if ok {
+			switched++
+		}
+	}
+
+	return switched, nil
+}
+
+func applyWarehouseCalendar(db *sql.DB, warehouseID int, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	w := Warehouse{ID: warehouseID}
+	var calendarOpen, calendarClosed bool
+	var checkedAt sql.NullTime
+	err = tx.QueryRow(`SELECT COALESCE(name, ''), COALESCE(is_available, false), calendar_open, calendar_closed, calendar_checked_at
+		FROM warehouse WHERE id = $1 FOR UPDATE`, warehouseID).Scan(&w.Name, &w.IsAvailable, &calendarOpen, &calendarClosed, &checkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cal, err := loadCalendar(tx, warehouseID, now)
+	if err != nil {
+		return false, err
+	}
+	open, reason := cal.Status(now)
+
+	if _, err := tx.Exec("UPDATE warehouse SET calendar_open = $1, calendar_checked_at = $2 WHERE id = $3", open, now, warehouseID); err != nil {
+		return false, err
+	}
+
+	if open == calendarOpen {
+		if open && w.IsAvailable && checkedAt.Valid {
+			if err := enqueueMissedMaintenance(tx, &w, checkedAt.Time, now); err != nil {
+				return false, err
+			}
+		}
+		return false, tx.Commit()
+	}
+
+	// календарь сменил состояние, но склад переключается только в двух случаях: доступный склад
+	// закрывается, а закрытый планировщиком — открывается
+	switch {
+	case !open && w.IsAvailable:
+		_, err = tx.Exec("UPDATE warehouse SET is_available = false, calendar_closed = true WHERE id = $1", warehouseID)
+		if err != nil {
+			return false, err
+		}
+		w.IsAvailable = false
+		if err := enqueueWarehouseStatus(tx, &w, reason); err != nil {
+			return false, err
+		}
+	case open && calendarClosed:
+		_, err = tx.Exec("UPDATE warehouse SET is_available = true, calendar_closed = false WHERE id = $1", warehouseID)
+		if err != nil {
+			return false, err
+		}
+		w.IsAvailable = true
+		if err := enqueueWarehouseStatus(tx, &w, ""); err != nil {
+			return false, err
+		}
+
+		// остаток открывшегося склада сразу получает лист ожидания
+		productIDs, err := queryIDs(tx, `SELECT DISTINCT b.product_id FROM backorders b
+			JOIN products p ON p.id = b.product_id WHERE p.warehouse_id = $1 AND b.status = $2 ORDER BY 1`,
+			warehouseID, BackorderWaiting)
+		if err != nil {
+			return false, err
+		}
+		if err := fulfillBackorders(tx, productIDs); err != nil {
+			return false, err
+		}
+	default:
+		return false, tx.Commit()
+	}
+
+	return true, tx.Commit()
+}
+
+// enqueueMissedMaintenance пишет warehouse.offline и warehouse.online для окон обслуживания,
+// которые начались и закончились между запусками планировщика since и now
+func enqueueMissedMaintenance(tx *sql.Tx, w *Warehouse, since, now time.Time) error {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM maintenance_windows
+		WHERE warehouse_id = $1 AND starts_at > $2 AND ends_at <= $3`, w.ID, since, now).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < count; i++ {
+		offline := *w
+		offline.IsAvailable = false
+		if err := enqueueWarehouseStatus(tx, &offline, calendar.ReasonMaintenance); err != nil {
+			return err
+		}
+		if err := enqueueWarehouseStatus(tx, w, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// warehouseAvailability считает доступность склада в момент at. Склад, который выключил
+// планировщик (calendarClosed), доступен, как только открывается по календарю, еще до следующего
+// запуска планировщика; выключенный вручную склад недоступен при любом календаре.
+func warehouseAvailability(q queryer, warehouseID int, isAvailable, calendarClosed bool, at time.Time) (*WarehouseAvailability, error) {
+	cal, err := loadCalendar(q, warehouseID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	a := WarehouseAvailability{WarehouseID: warehouseID, At: at}
+	open, reason := cal.Status(at)
+	switch {
+	case !open:
+		a.Reason = reason
+	case !isAvailable && !calendarClosed:
+		a.Reason = AvailabilityDisabled
+	default:
+		a.IsAvailable = true
+	}
+
+	return &a, nil
+}
+
+// loadCalendar читает график склада с окнами обслуживания, которые идут в момент at
+func loadCalendar(q queryer, warehouseID int, at time.Time) (calendar.Calendar, error) {
+	var cal calendar.Calendar
+	var timezone string
+	err := q.QueryRow("SELECT timezone FROM warehouse WHERE id = $1", warehouseID).Scan(&timezone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cal, ErrNotFound
+	}
+	if err != nil {
+		return cal, err
+	}
+	if cal.Location, err = time.LoadLocation(timezone); err != nil {
+		return cal, err
+	}
+
+	if cal.Hours, err = warehouseHours(q, warehouseID); err != nil {
+		return cal, err
+	}
+
+	rows, err := q.Query("SELECT to_char(holiday, 'YYYY-MM-DD') FROM warehouse_holidays WHERE warehouse_id = $1", warehouseID)
+	if err != nil {
+		return cal, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return cal, err
+		}
+		cal.Holidays = append(cal.Holidays, date)
+	}
+	if err := rows.Err(); err != nil {
+		return cal, err
+	}
+
+	windows, err := maintenanceWindows(q, warehouseID, at)
+	if err != nil {
+		return cal, err
+	}
+	for _, w := range windows {
+		cal.Maintenance = append(cal.Maintenance, calendar.Window{Start: w.StartsAt, End: w.EndsAt})
+	}
+
+	return cal, nil
+}
+
+func warehouseHours(q queryer, warehouseID int) ([]calendar.Hours, error) {
+	rows, err := q.Query(`SELECT weekday, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI') FROM warehouse_hours
+		WHERE warehouse_id = $1 ORDER BY weekday, opens`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []calendar.Hours{}
+	for rows.Next() {
+		var h calendar.Hours
+		if err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes); err != nil {
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+
+	return hours, rows.Err()
+}
+
+// maintenanceWindows возвращает окна обслуживания склада, которые не закончились к моменту since
+func maintenanceWindows(q queryer, warehouseID int, since time.Time) ([]MaintenanceWindow, error) {
+	rows, err := q.Query(`SELECT id, warehouse_id, starts_at, ends_at, COALESCE(reason, '') FROM maintenance_windows
+		WHERE warehouse_id = $1 AND ends_at > $2 ORDER BY starts_at`, warehouseID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []MaintenanceWindow
+	for rows.Next() {
+		var w MaintenanceWindow
+		if err := rows.Scan(&w.ID, &w.WarehouseID, &w.StartsAt, &w.EndsAt, &w.Reason); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+
+	return windows, rows.Err()
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/calendar"
+)
+
+// lastWarehouseEvent возвращает тип и тело последнего события склада в outbox
+func lastWarehouseEvent(t *testing.T, db *sql.DB, warehouseID int) (string, WarehouseStatusEvent) {
+	t.Helper()
+
+	var eventType string
+	var payload []byte
+	err := db.QueryRow("SELECT event_type, payload FROM outbox_events WHERE aggregate_key = $1 ORDER BY id DESC LIMIT 1",
+		"warehouse:"+strconv.Itoa(warehouseID)).Scan(&eventType, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e WarehouseStatusEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		t.Fatal(err)
+	}
+	return eventType, e
+}
+
+func TestWarehouseCalendarAvailability(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	cal := WarehouseCalendar{
+		Timezone: "Europe/Moscow",
+		Hours:    []calendar.Hours{{Weekday: time.Monday, Opens: "09:00", Closes: "18:00"}},
+		Holidays: []Holiday{{Date: "2024-01-08", Name: "Christmas holidays"}},
+	}
+	if err := SetWarehouseCalendar(db, w.ID, &cal); err != nil {
+		t.Fatal(err)
+	}
+
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	cases := map[time.Time]string{
+		time.Date(2024, 1, 15, 10, 0, 0, 0, moscow): "",
+		time.Date(2024, 1, 15, 20, 0, 0, 0, moscow): calendar.ReasonClosed,
+		time.Date(2024, 1, 8, 10, 0, 0, 0, moscow):  calendar.ReasonHoliday,
+	}
+	for at, reason := range cases {
+		a, err := GetWarehouseAvailability(db, w.ID, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.IsAvailable != (reason == "") || a.Reason != reason {
+			t.Errorf("Expected reason %q at %v, got %+v", reason, at, a)
+		}
+	}
+
+	window := MaintenanceWindow{
+		WarehouseID: w.ID,
+		StartsAt:    time.Date(2024, 1, 15, 10, 0, 0, 0, moscow),
+		EndsAt:      time.Date(2024, 1, 15, 11, 0, 0, 0, moscow),
+		Reason:      "Conveyor repair",
+	}
+	if err := CreateMaintenanceWindow(db, &window); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := GetWarehouseAvailability(db, w.ID, window.StartsAt.Add(30*time.Minute)); err != nil || a.Reason != calendar.ReasonMaintenance {
+		t.Errorf("Expected maintenance, got %+v, %v", a, err)
+	}
+	if err := DeleteMaintenanceWindow(db, w.ID, window.ID); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := GetWarehouseAvailability(db, w.ID, window.StartsAt.Add(30*time.Minute)); err != nil || !a.IsAvailable {
+		t.Errorf("Expected available after the window is cancelled, got %+v, %v", a, err)
+	}
+
+	got, err := GetWarehouseCalendar(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timezone != "Europe/Moscow" || len(got.Hours) != 1 || got.Hours[0].Opens != "09:00" || len(got.Holidays) != 1 {
+		t.Errorf("Unexpected calendar %+v", got)
+	}
+}
+
+func TestApplyWarehouseCalendars(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	cal := WarehouseCalendar{Hours: []calendar.Hours{{Weekday: time.Monday, Opens: "09:00", Closes: "18:00"}}}
+	if err := SetWarehouseCalendar(db, w.ID, &cal); err != nil {
+		t.Fatal(err)
+	}
+
+	evening := time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC)
+	if _, err := ApplyWarehouseCalendars(db, evening); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetWarehouse(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsAvailable {
+		t.Error("Expected the warehouse to be switched off after closing time")
+	}
+	if eventType, e := lastWarehouseEvent(t, db, w.ID); eventType != EventWarehouseOffline || e.Reason != calendar.ReasonClosed {
+		t.Errorf("Expected offline event with reason closed, got %s %+v", eventType, e)
+	}
+
+	// повторный запуск в том же состоянии ничего не меняет
+	if _, err := ApplyWarehouseCalendars(db, evening.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if eventType, _ := lastWarehouseEvent(t, db, w.ID); eventType != EventWarehouseOffline {
+		t.Errorf("Expected no new events, got %s", eventType)
+	}
+
+	// склад, закрытый планировщиком, доступен по календарю еще до следующего запуска
+	morning := time.Date(2024, 1, 22, 10, 0, 0, 0, time.UTC)
+	if a, err := GetWarehouseAvailability(db, w.ID, morning); err != nil || !a.IsAvailable {
+		t.Errorf("Expected available in the morning, got %+v, %v", a, err)
+	}
+
+	if _, err := ApplyWarehouseCalendars(db, morning); err != nil {
+		t.Fatal(err)
+	}
+	if eventType, e := lastWarehouseEvent(t, db, w.ID); eventType != EventWarehouseOnline || !e.IsAvailable {
+		t.Errorf("Expected online event, got %s %+v", eventType, e)
+	}
+
+	// выключенный вручную склад остается недоступным, пока календарь не сменит состояние
+	w.IsAvailable = false
+	if err := UpdateWarehouse(db, w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := GetWarehouseAvailability(db, w.ID, morning.Add(time.Hour)); err != nil || a.Reason != AvailabilityDisabled {
+		t.Errorf("Expected disabled warehouse, got %+v, %v", a, err)
+	}
+
+	// планировщик не включает склад, который выключили вручную, даже после закрытия и открытия
+	for _, at := range []time.Time{morning.Add(10 * time.Hour), morning.AddDate(0, 0, 7)} {
+		switched, err := applyWarehouseCalendar(db, w.ID, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if switched {
+			t.Errorf("Expected the manually disabled warehouse not to count as switched at %s", at)
+		}
+	}
+	got, err = GetWarehouse(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsAvailable {
+		t.Error("Expected the manually disabled warehouse to stay switched off")
+	}
+	if a, err := GetWarehouseAvailability(db, w.ID, morning.AddDate(0, 0, 7)); err != nil || a.Reason != AvailabilityDisabled {
+		t.Errorf("Expected the manually disabled warehouse to stay disabled, got %+v, %v", a, err)
+	}
+	if eventType, _ := lastWarehouseEvent(t, db, w.ID); eventType != EventWarehouseOffline {
+		t.Errorf("Expected no online event for a manually disabled warehouse, got %s", eventType)
+	}
+}
+
+func TestApplyWarehouseCalendarsReportsMissedMaintenance(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	start := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	if _, err := ApplyWarehouseCalendars(db, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// окно короче интервала опроса: оба его края приходятся между запусками
+	window := MaintenanceWindow{WarehouseID: w.ID, StartsAt: start.Add(10 * time.Second), EndsAt: start.Add(20 * time.Second)}
+	if err := CreateMaintenanceWindow(db, &window); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyWarehouseCalendars(db, start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT event_type, payload FROM outbox_events WHERE aggregate_key = $1 ORDER BY id",
+		"warehouse:"+strconv.Itoa(w.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var eventType string
+		var payload []byte
+		if err := rows.Scan(&eventType, &payload); err != nil {
+			t.Fatal(err)
+		}
+		var e WarehouseStatusEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, eventType+":"+e.Reason)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{EventWarehouseOffline + ":" + calendar.ReasonMaintenance, EventWarehouseOnline + ":"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v for the missed maintenance window, got %v", want, got)
+	}
+}
+
+func TestReservationsRespectWarehouseAvailability(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 2)
+
+	now := time.Now()
+	window := MaintenanceWindow{WarehouseID: w.ID, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}
+	if err := CreateMaintenanceWindow(db, &window); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrWarehouseClosed) {
+		t.Errorf("Expected ErrWarehouseClosed during maintenance, got %v", err)
+	}
+	result, err := ReserveProductsWithBackorder(db, []string{p.Code}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Reserved) != 0 || len(result.Backorders) != 1 {
+		t.Errorf("Expected the unit to be waitlisted until the warehouse opens, got %+v", result)
+	}
+
+	if err := DeleteMaintenanceWindow(db, w.ID, window.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected the open warehouse to keep its stock for the waitlist, got %v", err)
+	}
+}
+
+func TestSetWarehouseCalendarValidates(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	invalid := []WarehouseCalendar{
+		{Timezone: "Mars/Olympus"},
+		{Hours: []calendar.Hours{{Weekday: time.Monday, Opens: "18:00", Closes: "09:00"}}},
+		{Holidays: []Holiday{{Date: "08.01.2024"}}},
+	}
+	for _, c := range invalid {
+		if err := SetWarehouseCalendar(db, w.ID, &c); !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("Expected ErrInvalidCalendar for %+v, got %v", c, err)
+		}
+	}
+
+	now := time.Now()
+	if err := CreateMaintenanceWindow(db, &MaintenanceWindow{WarehouseID: w.ID, StartsAt: now, EndsAt: now}); !errors.Is(err, ErrInvalidCalendar) {
+		t.Errorf("Expected ErrInvalidCalendar for an empty window, got %v", err)
+	}
+}
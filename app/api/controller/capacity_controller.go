@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strconv"
+)
+
+const (
+	CapacityReject = "reject"
+	CapacityWarn   = "warn"
+)
+
+// EventWarehouseCapacityExceeded публикуется, когда склад с политикой warn принял поставку сверх вместимости
+const EventWarehouseCapacityExceeded = "warehouse.capacity_exceeded"
+
+// productVolumeSQL — объем единицы товара в литрах по габаритам товара или, если их нет, модели.
+// Требует псевдонимов p для products и s для styles.
+const productVolumeSQL = `COALESCE(p.length_cm * p.width_cm * p.height_cm, s.length_cm * s.width_cm * s.height_cm) / 1000`
+
+// WarehouseCapacity — вместимость склада в единицах и в литрах. Пустое значение — без ограничения.
+// Policy определяет, что делать с поставкой сверх вместимости: reject — отклонить, warn — принять с предупреждением.
+type WarehouseCapacity struct {
+	Units  *int     `json:"units"`
+	Volume *float64 `json:"volume"`
+	Policy string   `json:"policy"`
+}
+
+// WarehouseUtilization — заполненность склада по текущим остаткам вместе с зарезервированными, но еще
+// не отобранными единицами: они лежат на складе. UnknownVolumeUnits — единицы товаров
+// без габаритов, они не входят в Volume. Проценты заданы, только если задана ненулевая вместимость.
+type WarehouseUtilization struct {
+	Units              int      `json:"units"`
+	Volume             float64  `json:"volume"`
+	UnitsPercent       *float64 `json:"units_percent,omitempty"`
+	VolumePercent      *float64 `json:"volume_percent,omitempty"`
+	UnknownVolumeUnits int      `json:"unknown_volume_units"`
+}
+
+// Dimensions — габариты единицы товара в сантиметрах
+type Dimensions struct {
+	LengthCM *float64 `json:"length_cm"`
+	WidthCM  *float64 `json:"width_cm"`
+	HeightCM *float64 `json:"height_cm"`
+}
+
+// WarehouseCapacityEvent — тело события warehouse.capacity_exceeded. ReceiptID задан для проведения поставки,
+// Reference — для остальных зачислений (приемки в ячейку или партией, излишков пересчета, импорта).
+type WarehouseCapacityEvent struct {
+	WarehouseID    int      `json:"warehouse_id"`
+	ReceiptID      int      `json:"receipt_id,omitempty"`
+	Reference      string   `json:"reference,omitempty"`
+	CapacityUnits  *int     `json:"capacity_units,omitempty"`
+	CapacityVolume *float64 `json:"capacity_volume,omitempty"`
+	Units          int      `json:"units"`
+	Volume         float64  `json:"volume"`
+}
+
+func (c *WarehouseCapacity) validate() error {
+	if c.Policy == "" {
+		c.Policy = CapacityReject
+	}
+	if c.Policy != CapacityReject && c.Policy != CapacityWarn {
+		return ErrInvalidCapacity
+	}
+	if (c.Units != nil && *c.Units < 0) || (c.Volume != nil && *c.Volume < 0) {
+		return ErrInvalidCapacity
+	}
+	return nil
+}
+
+func (d Dimensions) validate() error {
+	for _, v := range []*float64{d.LengthCM, d.WidthCM, d.HeightCM} {
+		if v != nil && *v <= 0 {
+			return ErrInvalidCapacity
+		}
+	}
+	return nil
+}
+
+//	@Summary		Set warehouse capacity.
+//	@Description	Set the capacity of a warehouse in units and in litres of product volume. Null values remove the limit. Receipts that would exceed the capacity are rejected with policy=reject (default) or accepted with a warning and a warehouse.capacity_exceeded event with policy=warn.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Warehouse ID"
+//	@Param			capacity	body		WarehouseCapacity	true	"Capacity"
+//	@Success		200			{object}	WarehouseCapacity
+//	@Failure		400			{object}	ErrorResponse		"Invalid capacity"
+//	@Failure		404			{object}	ErrorResponse		"Warehouse not found"
+//	@Failure		500			{object}	ErrorResponse		"Internal server error"
+//	@Router			/warehouses/{id}/capacity [put]
+//
+func SetWarehouseCapacity(db *sql.DB, warehouseID int, c *WarehouseCapacity) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE warehouse SET capacity_units = $1, capacity_volume = $2, capacity_policy = $3 WHERE id = $4",
+		c.Units, c.Volume, c.Policy, warehouseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Get warehouse utilization.
+//	@Description	Get the units and litres of product volume currently stored in a warehouse, reserved units not yet picked included, as a percentage of its capacity when one is set.
+//	@Tags			warehouses
+//	@Produce		json
+//	@Param			id	path		int				true	"Warehouse ID"
+//	@Success		200	{object}	WarehouseUtilization
+//	@Failure		404	{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/utilization [get]
+//
+func GetWarehouseUtilization(db *sql.DB, warehouseID int) (*WarehouseUtilization, error) {
+	c, err := warehouseCapacity(db, warehouseID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return warehouseUtilization(db, warehouseID, c)
+}
+
+//	@Summary		Set product dimensions.
+//	@Description	Set the unit dimensions of a product in centimetres. Products without their own dimensions use the dimensions of their style.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Param			code		path		string			true	"Product code or barcode"
+//	@Param			dimensions	body		Dimensions		true	"Dimensions"
+//	@Success		200			{object}	Dimensions
+//	@Failure		400			{object}	ErrorResponse	"Invalid dimensions"
+//	@Failure		404			{object}	ErrorResponse	"Product not found"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/products/{code}/dimensions [put]
+//
+func SetProductDimensions(db *sql.DB, code string, d Dimensions) error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+
+	productCode, err := resolveCode(db, code)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE products SET length_cm = $1, width_cm = $2, height_cm = $3 WHERE code = $4",
+		d.LengthCM, d.WidthCM, d.HeightCM, productCode)
+	return err
+}
+
+//	@Summary		Set style dimensions.
+//	@Description	Set the default unit dimensions in centimetres for products of a style.
+//	@Tags			catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			code		path		string			true	"Style code"
+//	@Param			dimensions	body		Dimensions		true	"Dimensions"
+//	@Success		200			{object}	Dimensions
+//	@Failure		400			{object}	ErrorResponse	"Invalid dimensions"
+//	@Failure		404			{object}	ErrorResponse	"Style not found"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/styles/{code}/dimensions [put]
+//
+func SetStyleDimensions(db *sql.DB, code string, d Dimensions) error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE styles SET length_cm = $1, width_cm = $2, height_cm = $3 WHERE code = $4",
+		d.LengthCM, d.WidthCM, d.HeightCM, code)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// warehouseCapacity читает вместимость склада; с lock строка склада блокируется,
+// чтобы параллельные поставки проверялись по очереди
+func warehouseCapacity(q queryer, warehouseID int, lock bool) (*WarehouseCapacity, error) {
+	query := "SELECT capacity_units, capacity_volume, capacity_policy FROM warehouse WHERE id = $1"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var c WarehouseCapacity
+	var units sql.NullInt64
+	var volume sql.NullFloat64
+	err := q.QueryRow(query, warehouseID).Scan(&units, &volume, &c.Policy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Units = nullIntPtr(units)
+	if volume.Valid {
+		c.Volume = &volume.Float64
+	}
+
+	return &c, nil
+}
+
+func warehouseUtilization(q queryer, warehouseID int, c *WarehouseCapacity) (*WarehouseUtilization, error) {
+	var u WarehouseUtilization
+	err := q.QueryRow(`SELECT COALESCE(SUM(p.quantity + p.reserved), 0),
+			COALESCE(SUM((p.quantity + p.reserved) * `+productVolumeSQL+`), 0),
+			COALESCE(SUM(p.quantity + p.reserved) FILTER (WHERE `+productVolumeSQL+` IS NULL), 0)
+		FROM products p LEFT JOIN styles s ON s.id = p.style_id
+		WHERE p.warehouse_id = $1 AND NOT p.is_bundle`, warehouseID,
+	).Scan(&u.Units, &u.Volume, &u.UnknownVolumeUnits)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Volume = math.Round(u.Volume*1e3) / 1e3
+	if c.Units != nil && *c.Units > 0 {
+		u.UnitsPercent = percent(float64(u.Units), float64(*c.Units))
+	}
+	if c.Volume != nil && *c.Volume > 0 {
+		u.VolumePercent = percent(u.Volume, *c.Volume)
+	}
+
+	return &u, nil
+}
+
+func percent(v, total float64) *float64 {
+	p := math.Round(v/total*1e4) / 100
+	return &p
+}
+
+// checkReceiptCapacity проверяет, поместится ли поставка на склад. При политике reject превышение
+// возвращает ErrCapacityExceeded, при warn — отмечает поставку и пишет событие в outbox.
+// Вызывается в транзакции проведения поставки до зачисления строк.
+func checkReceiptCapacity(tx *sql.Tx, receiptID, warehouseID int) error {
+	c, err := warehouseCapacity(tx, warehouseID, true)
+	if err != nil {
+		return err
+	}
+	if c.Units == nil && c.Volume == nil {
+		return nil
+	}
+
+	u, err := warehouseUtilization(tx, warehouseID, c)
+	if err != nil {
+		return err
+	}
+
+	var units int
+	var volume float64
+	err = tx.QueryRow(`SELECT COALESCE(SUM(l.quantity), 0), COALESCE(SUM(l.quantity * `+productVolumeSQL+`), 0)
+		FROM receipt_lines l JOIN products p ON p.id = l.product_id LEFT JOIN styles s ON s.id = p.style_id
+		WHERE l.receipt_id = $1`, receiptID).Scan(&units, &volume)
+	if err != nil {
+		return err
+	}
+
+	exceeded, err := applyCapacityPolicy(tx, c, WarehouseCapacityEvent{
+		WarehouseID: warehouseID,
+		ReceiptID:   receiptID,
+		Units:       units + u.Units,
+		Volume:      math.Round((volume+u.Volume)*1e3) / 1e3,
+	})
+	if err != nil || !exceeded {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE receipts SET capacity_exceeded = true WHERE id = $1", receiptID)
+	return err
+}
+
+// lockProductCapacity блокирует склад товара с кодом code и читает его вместимость. Зачисления мимо
+// поставок блокируют склад до блокировки товаров, в том же порядке, что и проведение поставки,
+// а после зачисления проверяют вместимость через checkStockCapacity.
+func lockProductCapacity(tx *sql.Tx, code string) (int, *WarehouseCapacity, error) {
+	var warehouseID int
+	err := tx.QueryRow("SELECT warehouse_id FROM products WHERE code = $1", code).Scan(&warehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, ErrNotFound
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	c, err := warehouseCapacity(tx, warehouseID, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return warehouseID, c, nil
+}
+
+// checkStockCapacity проверяет вместимость склада, остаток которого уже увеличен в транзакции: приемкой
+// в ячейку или партией, созданием товара с остатком, излишками пересчета или импортом. c — вместимость,
+// прочитанная с блокировкой склада. Возврат резерва заполненность не меняет: зарезервированные
+// единицы уже учтены, поэтому возвраты вместимость не проверяют.
+func checkStockCapacity(tx *sql.Tx, warehouseID int, c *WarehouseCapacity, reference string) error {
+	if c.Units == nil && c.Volume == nil {
+		return nil
+	}
+
+	u, err := warehouseUtilization(tx, warehouseID, c)
+	if err != nil {
+		return err
+	}
+
+	_, err = applyCapacityPolicy(tx, c, WarehouseCapacityEvent{
+		WarehouseID: warehouseID,
+		Reference:   reference,
+		Units:       u.Units,
+		Volume:      u.Volume,
+	})
+	return err
+}
+
+// applyCapacityPolicy сравнивает заполненность из e с вместимостью c. При политике reject превышение
+// возвращает ErrCapacityExceeded, при warn — пишет событие warehouse.capacity_exceeded в outbox
+// и сообщает о превышении.
+func applyCapacityPolicy(tx *sql.Tx, c *WarehouseCapacity, e WarehouseCapacityEvent) (bool, error) {
+	if !exceedsCapacity(c, e.Units, e.Volume) {
+		return false, nil
+	}
+	if c.Policy != CapacityWarn {
+		return false, ErrCapacityExceeded
+	}
+
+	e.CapacityUnits = c.Units
+	e.CapacityVolume = c.Volume
+	if err := enqueueEvent(tx, EventWarehouseCapacityExceeded, "warehouse:"+strconv.Itoa(e.WarehouseID), e); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func exceedsCapacity(c *WarehouseCapacity, units int, volume float64) bool {
+	return (c.Units != nil && units > *c.Units) || (c.Volume != nil && volume > *c.Volume)
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestReceiptRespectsWarehouseCapacity(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 8)
+
+	if err := SetWarehouseCapacity(db, w.ID, &WarehouseCapacity{Units: intPtr(10)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 3}}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); !errors.Is(err, ErrCapacityExceeded) {
+		t.Fatalf("Expected ErrCapacityExceeded, got %v", err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 8 {
+		t.Errorf("Expected rejected receipt not to change stock, got %d", q)
+	}
+
+	if err := SetWarehouseCapacity(db, w.ID, &WarehouseCapacity{Units: intPtr(10), Policy: CapacityWarn}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	received, err := GetReceipt(db, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.CapacityExceeded {
+		t.Error("Expected receipt to be marked as exceeding capacity")
+	}
+
+	wh, err := GetWarehouse(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := wh.Utilization; u == nil || u.Units != 11 || u.UnitsPercent == nil || *u.UnitsPercent != 110 {
+		t.Errorf("Expected utilization of 11 units (110%%), got %+v", u)
+	}
+}
+
+func TestStockIncreasesRespectWarehouseCapacity(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 8)
+
+	if err := SetWarehouseCapacity(db, w.ID, &WarehouseCapacity{Units: intPtr(10)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// зарезервированные единицы лежат на складе и занимают место
+	if err := ReserveProducts(db, []string{p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := GetWarehouseUtilization(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Units != 8 {
+		t.Errorf("Expected reserved units to count towards utilization, got %d units", u.Units)
+	}
+
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 3}); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Expected ErrCapacityExceeded for a lot, got %v", err)
+	}
+	created := &Product{Name: "Overflow", Size: "M", Code: strings.ToUpper(utils.RandomString(10)), Quantity: 3, WarehouseID: w.ID}
+	if err := CreateProduct(db, created); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Expected ErrCapacityExceeded for a new product with stock, got %v", err)
+	}
+	file := fmt.Sprintf("name,size,code,quantity,warehouse_id\nOverflow,M,%s,11,%d\n", p.Code, w.ID)
+	if _, err := ImportProducts(db, strings.NewReader(file), ImportFormatCSV, ImportOptions{}); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Expected ErrCapacityExceeded for an import, got %v", err)
+	}
+
+	cc := &CycleCount{WarehouseID: w.ID}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 9}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApproveCycleCount(db, cc.ID); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("Expected ErrCapacityExceeded for cycle count gains, got %v", err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 6 {
+		t.Errorf("Expected rejected increases not to change stock, got %d", q)
+	}
+
+	// возврат резерва заполненность не меняет, а поступление в пределах вместимости принимается
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 2}); err != nil {
+		t.Errorf("Expected a lot within capacity to be received, got %v", err)
+	}
+}
+
+func TestWarehouseUtilizationVolume(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 4)
+	createTestProduct(t, db, w.ID, 2)
+
+	side := 10.0
+	if err := SetProductDimensions(db, p.Code, Dimensions{LengthCM: &side, WidthCM: &side, HeightCM: &side}); err != nil {
+		t.Fatal(err)
+	}
+	volume := 8.0
+	if err := SetWarehouseCapacity(db, w.ID, &WarehouseCapacity{Volume: &volume}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := GetWarehouseUtilization(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Volume != 4 || u.UnknownVolumeUnits != 2 || u.VolumePercent == nil || *u.VolumePercent != 50 {
+		t.Errorf("Expected 4 litres (50%%) and 2 units without dimensions, got %+v", u)
+	}
+
+	if err := SetProductDimensions(db, p.Code, Dimensions{LengthCM: floatPtr(-1)}); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("Expected ErrInvalidCapacity, got %v", err)
+	}
+	if err := SetWarehouseCapacity(db, w.ID, &WarehouseCapacity{Policy: "ignore"}); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("Expected ErrInvalidCapacity, got %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+)
+
+const (
+	GenderMen    = "men"
+	GenderWomen  = "women"
+	GenderUnisex = "unisex"
+	GenderKids   = "kids"
+)
+
+const (
+	SizeSystemEU = "eu"
+	SizeSystemUS = "us"
+	SizeSystemRU = "ru"
+)
+
+// seasonPattern описывает код сезона коллекции: SS24 — весна-лето, FW24 — осень-зима
+var seasonPattern = regexp.MustCompile(`^(SS|FW)\d{2}$`)
+
+type Category struct {
+	ID    int        `json:"id"`
+	Name  string     `json:"name"`
+	Sizes []SizeGrid `json:"sizes"`
+}
+
+// SizeGrid — строка размерной сетки категории с соответствием размеров в системах EU/US/RU
+type SizeGrid struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+	EU    string `json:"eu,omitempty"`
+	US    string `json:"us,omitempty"`
+	RU    string `json:"ru,omitempty"`
+}
+
+// Style — модель товара, объединяющая размерные варианты (товары) с общими атрибутами
+type Style struct {
+	ID         int    `json:"id"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	CategoryID int    `json:"category_id"`
+	Brand      string `json:"brand"`
+	Color      string `json:"color,omitempty"`
+	Gender     string `json:"gender"`
+	Season     string `json:"season,omitempty"`
+}
+
+type StyleVariant struct {
+	Code string `json:"code"`
+	Size string `json:"size"`
+}
+
+type StyleStock struct {
+	Style    Style               `json:"style"`
+	Total    int                 `json:"total"`
+	Variants []StyleVariantStock `json:"variants"`
+}
+
+type StyleVariantStock struct {
+	Code        string   `json:"code"`
+	Size        SizeGrid `json:"size"`
+	WarehouseID int      `json:"warehouse_id"`
+	Quantity    int      `json:"quantity"`
+}
+
+//	@Summary		Create a category.
+//	@Description	Create a catalog category with its size grid. Sizes are listed in ascending order.
+//	@Tags			catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			category	body		Category		true	"Category with size grid"
+//	@Success		201			{object}	Category
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/categories [post]
+//
+func CreateCategory(db *sql.DB, c *Category) error {
+	if c.Name == "" {
+		return ErrInvalidCatalog
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow("INSERT INTO categories(name) VALUES($1) RETURNING id", c.Name).Scan(&c.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range c.Sizes {
+		s := &c.Sizes[i]
+		if s.Label == "" {
+			tx.Rollback()
+			return ErrInvalidCatalog
+		}
+
+		err := tx.QueryRow(
+			"INSERT INTO size_grid(category_id, label, eu, us, ru, sort_order) VALUES($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6) RETURNING id",
+			c.ID, s.Label, s.EU, s.US, s.RU, i,
+		).Scan(&s.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Get a category.
+//	@Description	Get a catalog category with its size grid.
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			id	path		int				true	"Category ID"
+//	@Success		200	{object}	Category
+//	@Failure		404	{object}	ErrorResponse	"Category not found"
+//	@Router			/categories/{id} [get]
+//
+func GetCategory(db *sql.DB, id int) (*Category, error) {
+	var c Category
+	err := db.QueryRow("SELECT id, name FROM categories WHERE id = $1", id).Scan(&c.ID, &c.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT id, label, COALESCE(eu, ''), COALESCE(us, ''), COALESCE(ru, '')
+		FROM size_grid WHERE category_id = $1 ORDER BY sort_order, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s SizeGrid
+		if err := rows.Scan(&s.ID, &s.Label, &s.EU, &s.US, &s.RU); err != nil {
+			return nil, err
+		}
+		c.Sizes = append(c.Sizes, s)
+	}
+
+	return &c, rows.Err()
+}
+
+//	@Summary		Create a style.
+//	@Description	Create a parent style with typed attributes. Gender is one of men, women, unisex, kids; season looks like SS24 or FW24.
+//	@Tags			catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			style	body		Style			true	"Style information"
+//	@Success		201		{object}	Style
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/styles [post]
+//
+func CreateStyle(db *sql.DB, s *Style) error {
+	if err := validateStyle(s); err != nil {
+		return err
+	}
+
+	return db.QueryRow(
+		"INSERT INTO styles(code, name, category_id, brand, color, gender, season) VALUES($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, '')) RETURNING id",
+		s.Code, s.Name, s.CategoryID, s.Brand, s.Color, s.Gender, s.Season,
+	).Scan(&s.ID)
+}
+
+//	@Summary		Add a size variant to a style.
+//	@Description	Attach an existing product to a style as the variant of a size from the style category grid.
+//	@Tags			catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	path		string			true	"Style code"
+//	@Param			variant	body		StyleVariant	true	"Product code and size label"
+//	@Success		204		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Size is not in the category grid"
+//	@Failure		404		{object}	ErrorResponse	"Style or product not found"
+//	@Router			/styles/{code}/variants [post]
+//
+func AddStyleVariant(db *sql.DB, styleCode string, v StyleVariant) error {
+	var styleID int
+	var sizeID sql.NullInt64
+	err := db.QueryRow(`SELECT s.id, g.id FROM styles s
+		LEFT JOIN size_grid g ON g.category_id = s.category_id AND g.label = $2
+		WHERE s.code = $1`, styleCode, v.Size).Scan(&styleID, &sizeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !sizeID.Valid {
+		return ErrInvalidCatalog
+	}
+
+	res, err := db.Exec("UPDATE products SET style_id = $1, size_id = $2, size = $3 WHERE code = $4", styleID, sizeID.Int64, v.Size, v.Code)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Get style stock across sizes.
+//	@Description	Get stock of all size variants of a style in every warehouse.
+//	@Description	The size can be filtered by label or by its value in the eu, us or ru system.
+//	@Tags			catalog
+//	@Produce		json
+//	@Param			code	path		string			true	"Style code"
+//	@Param			size	query		string			false	"Size value"
+//	@Param			system	query		string			false	"Size system: eu, us or ru"
+//	@Success		200		{object}	StyleStock
+//	@Failure		400		{object}	ErrorResponse	"Unknown size system"
+//	@Failure		404		{object}	ErrorResponse	"Style not found"
+//	@Router			/styles/{code}/stock [get]
+//
+func GetStyleStock(db *sql.DB, styleCode, size, system string) (*StyleStock, error) {
+	var sizeColumn string
+	switch system {
+	case "":
+		sizeColumn = "g.label"
+	case SizeSystemEU, SizeSystemUS, SizeSystemRU:
+		sizeColumn = "g." + system
+	default:
+		return nil, ErrInvalidCatalog
+	}
+
+	var st StyleStock
+	var color, season sql.NullString
+	err := db.QueryRow(
+		"SELECT id, code, name, category_id, brand, color, gender, season FROM styles WHERE code = $1", styleCode,
+	).Scan(&st.Style.ID, &st.Style.Code, &st.Style.Name, &st.Style.CategoryID, &st.Style.Brand, &color, &st.Style.Gender, &season)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.Style.Color, st.Style.Season = color.String, season.String
+
+	rows, err := db.Query(`SELECT p.code, g.id, g.label, COALESCE(g.eu, ''), COALESCE(g.us, ''), COALESCE(g.ru, ''),
+			p.warehouse_id, `+availableSQL+`
+		FROM products p JOIN size_grid g ON g.id = p.size_id
+		WHERE p.style_id = $1 AND ($2 = '' OR `+sizeColumn+` = $2)
+		ORDER BY g.sort_order, p.warehouse_id`, st.Style.ID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v StyleVariantStock
+		if err := rows.Scan(&v.Code, &v.Size.ID, &v.Size.Label, &v.Size.EU, &v.Size.US, &v.Size.RU, &v.WarehouseID, &v.Quantity); err != nil {
+			return nil, err
+		}
+		st.Total += v.Quantity
+		st.Variants = append(st.Variants, v)
+	}
+
+	return &st, rows.Err()
+}
+
+func validateStyle(s *Style) error {
+	if s.Code == "" || s.Name == "" || s.Brand == "" {
+		return ErrInvalidCatalog
+	}
+
+	switch s.Gender {
+	case GenderMen, GenderWomen, GenderUnisex, GenderKids:
+	default:
+		return ErrInvalidCatalog
+	}
+
+	if s.Season != "" && !seasonPattern.MatchString(s.Season) {
+		return ErrInvalidCatalog
+	}
+
+	return nil
+}
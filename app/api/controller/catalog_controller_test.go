@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func createTestStyle(t *testing.T, db *sql.DB) *Style {
+	t.Helper()
+
+	c := &Category{
+		Name: utils.RandomString(10),
+		Sizes: []SizeGrid{
+			{Label: "M", EU: "48", US: "38", RU: "48"},
+			{Label: "L", EU: "50", US: "40", RU: "50"},
+		},
+	}
+	if err := CreateCategory(db, c); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Style{
+		Code:       utils.RandomString(10),
+		Name:       "Basic tee",
+		CategoryID: c.ID,
+		Brand:      "Lamoda",
+		Color:      "white",
+		Gender:     GenderUnisex,
+		Season:     "SS24",
+	}
+	if err := CreateStyle(db, s); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestGetStyleStockAcrossSizes(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	s := createTestStyle(t, db)
+	m := createTestProduct(t, db, w.ID, 3)
+	l := createTestProduct(t, db, w.ID, 2)
+
+	if err := AddStyleVariant(db, s.Code, StyleVariant{Code: m.Code, Size: "M"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddStyleVariant(db, s.Code, StyleVariant{Code: l.Code, Size: "L"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := GetStyleStock(db, s.Code, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Total != 5 || len(stock.Variants) != 2 || stock.Variants[0].Size.Label != "M" {
+		t.Errorf("Expected 5 units in sizes M and L, got %+v", stock)
+	}
+
+	stock, err = GetStyleStock(db, s.Code, "50", SizeSystemEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Total != 2 || len(stock.Variants) != 1 || stock.Variants[0].Code != l.Code {
+		t.Errorf("Expected only size L for EU 50, got %+v", stock)
+	}
+}
+
+func TestAddStyleVariantRejectsUnknownSize(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	s := createTestStyle(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+
+	err := AddStyleVariant(db, s.Code, StyleVariant{Code: p.Code, Size: "10.00"})
+	if !errors.Is(err, ErrInvalidCatalog) {
+		t.Errorf("Expected ErrInvalidCatalog, but got %v", err)
+	}
+}
+
+func TestCreateStyleValidatesAttributes(t *testing.T) {
+	db := openTestDB(t)
+
+	s := &Style{Code: utils.RandomString(10), Name: "Tee", Brand: "Lamoda", Gender: "male"}
+	if err := CreateStyle(db, s); !errors.Is(err, ErrInvalidCatalog) {
+		t.Errorf("Expected ErrInvalidCatalog for unknown gender, but got %v", err)
+	}
+}
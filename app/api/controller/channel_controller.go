@@ -0,0 +1,454 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AllocationFixed   = "fixed"
+	AllocationPercent = "percent"
+)
+
+// SalesChannel — канал продаж, за которым можно закрепить часть остатка товаров
+type SalesChannel struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChannelAllocation — закрепленный за каналом остаток товара: фиксированное число единиц
+// или процент от остатка. Закрепленные единицы может резервировать только этот канал.
+type ChannelAllocation struct {
+	Kind  string `json:"kind"`
+	Value int    `json:"value"`
+}
+
+// ChannelAvailability — доступность товара для канала. Allocated — закрепленные единицы,
+// Reserved — сколько из них канал уже зарезервировал, Available — сколько еще можно зарезервировать.
+type ChannelAvailability struct {
+	ProductID   int    `json:"product_id"`
+	Code        string `json:"code"`
+	WarehouseID int    `json:"warehouse_id"`
+	Kind        string `json:"kind"`
+	Value       int    `json:"value"`
+	Allocated   int    `json:"allocated"`
+	Reserved    int    `json:"reserved"`
+	Available   int    `json:"available"`
+}
+
+func (a ChannelAllocation) validate() error {
+	switch {
+	case a.Kind == AllocationFixed && a.Value >= 0:
+	case a.Kind == AllocationPercent && a.Value >= 0 && a.Value <= 100:
+	default:
+		return ErrInvalidChannel
+	}
+	return nil
+}
+
+//	@Summary		Create a sales channel.
+//	@Tags			channels
+//	@Accept			json
+//	@Produce		json
+//	@Param			channel	body		SalesChannel	true	"Channel"
+//	@Success		201		{object}	SalesChannel
+//	@Failure		400		{object}	ErrorResponse	"Invalid or duplicate channel name"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/channels [post]
+//
+func CreateSalesChannel(db *sql.DB, ch *SalesChannel) error {
+	ch.Name = strings.TrimSpace(ch.Name)
+	if ch.Name == "" {
+		return ErrInvalidChannel
+	}
+
+	err := db.QueryRow("INSERT INTO sales_channels(name) VALUES($1) RETURNING id, created_at", ch.Name).
+		Scan(&ch.ID, &ch.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrInvalidChannel
+	}
+
+	return err
+}
+
+//	@Summary		List sales channels.
+//	@Tags			channels
+//	@Produce		json
+//	@Success		200	{array}		SalesChannel
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/channels [get]
+//
+func GetSalesChannels(db *sql.DB) ([]SalesChannel, error) {
+	rows, err := db.Query("SELECT id, name, created_at FROM sales_channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []SalesChannel{}
+	for rows.Next() {
+		var ch SalesChannel
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+
+	return channels, rows.Err()
+}
+
+//	@Summary		Ring-fence stock for a sales channel.
+//	@Description	Set the allocation of a product for a channel: a fixed number of units or a percentage of the product stock including units held by channels. Allocated units can only be reserved on behalf of the channel; other reservations use the remaining stock.
+//	@Tags			channels
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Channel ID"
+//	@Param			code		path		string				true	"Product code or barcode"
+//	@Param			allocation	body		ChannelAllocation	true	"Allocation"
+//	@Success		200			{object}	ChannelAllocation
+//	@Failure		400			{object}	ErrorResponse		"Invalid allocation or bundle code"
+//	@Failure		404			{object}	ErrorResponse		"Channel or product not found"
+//	@Failure		500			{object}	ErrorResponse		"Internal server error"
+//	@Router			/channels/{id}/allocations/{code} [put]
+//
+func SetChannelAllocation(db *sql.DB, channelID int, code string, a ChannelAllocation) error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	productCode, err := resolveCode(db, code)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.Exec(`INSERT INTO channel_allocations(channel_id, product_id, kind, value)
+		SELECT $1, id, $3, $4 FROM products WHERE code = $2 AND NOT is_bundle
+		ON CONFLICT (channel_id, product_id) DO UPDATE SET kind = EXCLUDED.kind, value = EXCLUDED.value, updated_at = now()`,
+		channelID, productCode, a.Kind, a.Value)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// наборы не хранят остаток, закреплять нужно их компоненты
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidChannel
+	}
+
+	return nil
+}
+
+//	@Summary		Remove a channel allocation.
+//	@Description	Return the ring-fenced stock of a product to the shared pool. Reservations already made by the channel are kept and can still be released on behalf of the channel.
+//	@Tags			channels
+//	@Param			id		path	int		true	"Channel ID"
+//	@Param			code	path	string	true	"Product code or barcode"
+//	@Success		204
+//	@Failure		404	{object}	ErrorResponse	"Allocation not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/channels/{id}/allocations/{code} [delete]
+//
+func DeleteChannelAllocation(db *sql.DB, channelID int, code string) error {
+	productCode, err := resolveCode(db, code)
+	if err != nil {
+		return err
+	}
+
+	// пока канал держит резервы, закрепление обнуляется, а не удаляется, чтобы резервы оставались за каналом
+	res, err := db.Exec(`WITH a AS (
+			SELECT channel_id, product_id, reserved FROM channel_allocations
+			WHERE channel_id = $1 AND product_id = (SELECT id FROM products WHERE code = $2)
+			FOR UPDATE
+		), removed AS (
+			DELETE FROM channel_allocations d USING a
+			WHERE d.channel_id = a.channel_id AND d.product_id = a.product_id AND a.reserved = 0
+			RETURNING 1
+		), cleared AS (
+			UPDATE channel_allocations u SET kind = $3, value = 0, updated_at = now() FROM a
+			WHERE u.channel_id = a.channel_id AND u.product_id = a.product_id AND a.reserved > 0
+			RETURNING 1
+		)
+		SELECT 1 FROM removed UNION ALL SELECT 1 FROM cleared`, channelID, productCode, AllocationFixed)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Channel availability.
+//	@Description	List the products allocated to a channel with allocated, reserved and still available units.
+//	@Tags			channels
+//	@Produce		json
+//	@Param			id				path		int				true	"Channel ID"
+//	@Param			warehouse_id	query		int				false	"Warehouse ID"
+//	@Success		200				{array}		ChannelAvailability
+//	@Failure		404				{object}	ErrorResponse	"Channel not found"
+//	@Failure		500				{object}	ErrorResponse	"Internal server error"
+//	@Router			/channels/{id}/availability [get]
+//
+func GetChannelAvailability(db *sql.DB, channelID, warehouseID int) ([]ChannelAvailability, error) {
+	if err := checkChannelExists(db, channelID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT p.id, p.code, p.warehouse_id, a.kind, a.value, a.reserved, p.quantity,
+			(SELECT SUM(o.reserved) FROM channel_allocations o WHERE o.product_id = p.id)
+		FROM channel_allocations a JOIN products p ON p.id = a.product_id
+		WHERE a.channel_id = $1 AND ($2 = 0 OR p.warehouse_id = $2)
+		ORDER BY p.warehouse_id, p.code`, channelID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []ChannelAvailability{}
+	for rows.Next() {
+		var l ChannelAvailability
+		var quantity, channelsReserved int
+		if err := rows.Scan(&l.ProductID, &l.Code, &l.WarehouseID, &l.Kind, &l.Value, &l.Reserved,
+			&quantity, &channelsReserved); err != nil {
+			return nil, err
+		}
+		l.Allocated = channelAllocation(l.Kind, l.Value, quantity+channelsReserved)
+		l.Available = channelFree(l.Allocated, l.Reserved)
+		if l.Available > quantity {
+			l.Available = quantity
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+func checkChannelExists(q queryer, channelID int) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM sales_channels WHERE id = $1)", channelID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// channelAllocation возвращает число закрепленных за каналом единиц. Процент считается от base —
+// свободного остатка вместе с единицами, которые уже держат каналы, с округлением вниз.
+func channelAllocation(kind string, value, base int) int {
+	if kind == AllocationPercent {
+		return base * value / 100
+	}
+	return value
+}
+
+// channelFree возвращает, сколько закрепленных единиц канал еще не зарезервировал
+func channelFree(allocated, reserved int) int {
+	if allocated < reserved {
+		return 0
+	}
+	return allocated - reserved
+}
+
+// takeChannelStock проверяет, что единицу товара с остатком quantity можно зарезервировать,
+// и учитывает резерв канала. Без канала (channelID = 0) доступен только остаток, не закрепленный
+// за каналами; канал может резервировать только свои закрепленные единицы.
+// Строка товара должна быть заблокирована вызывающим.
+func takeChannelStock(tx *sql.Tx, productID, quantity, channelID int) error {
+	fences, err := channelFences(tx, productID, quantity)
+	if err != nil {
+		return err
+	}
+
+	fenced := 0
+	for _, f := range fences {
+		if f.channelID != channelID {
+			fenced += f.free
+			continue
+		}
+
+		if f.free < 1 {
+			return ErrOutOfStock
+		}
+		_, err := tx.Exec("UPDATE channel_allocations SET reserved = reserved + 1 WHERE channel_id = $1 AND product_id = $2",
+			channelID, productID)
+		return err
+	}
+
+	if channelID != 0 || quantity-fenced < 1 {
+		return ErrOutOfStock
+	}
+
+	return nil
+}
+
+// channelFence — сколько закрепленных за каналом единиц товара он еще не зарезервировал
+type channelFence struct {
+	channelID int
+	free      int
+}
+
+// channelFences читает закрепления товара с остатком quantity за каналами в порядке каналов
+func channelFences(q queryer, productID, quantity int) ([]channelFence, error) {
+	rows, err := q.Query(`SELECT channel_id, kind, value, reserved FROM channel_allocations
+		WHERE product_id = $1 ORDER BY channel_id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type allocation struct {
+		channelID int
+		kind      string
+		value     int
+		reserved  int
+	}
+	var allocations []allocation
+	base := quantity
+	for rows.Next() {
+		var a allocation
+		if err := rows.Scan(&a.channelID, &a.kind, &a.value, &a.reserved); err != nil {
+			return nil, err
+		}
+		base += a.reserved
+		allocations = append(allocations, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fences := make([]channelFence, len(allocations))
+	for i, a := range allocations {
+		fences[i] = channelFence{channelID: a.channelID, free: channelFree(channelAllocation(a.kind, a.value, base), a.reserved)}
+	}
+
+	return fences, nil
+}
+
+// sharedStock возвращает, сколько единиц товара с остатком quantity не закреплено за каналами
+// и доступно резерву без канала
+func sharedStock(q queryer, productID, quantity int) (int, error) {
+	fences, err := channelFences(q, productID, quantity)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range fences {
+		quantity -= f.free
+	}
+
+	return max(quantity, 0), nil
+}
+
+// releaseChannelStock снимает возвращаемую единицу товара с резерва канала и возвращает канал, с резерва
+// которого она снята (0 — ни с какого). Канал, от имени которого возвращают единицу, должен ее держать,
+// иначе — ErrNotReserved. Единица, возвращенная без канала (по кодам или при отмене заказа), снимается
+// с резерва канала, когда все зарезервированные единицы товара (reserved — до возврата) держат каналы.
+func releaseChannelStock(tx *sql.Tx, productID, channelID, reserved int) (int, error) {
+	if channelID != 0 {
+		res, err := tx.Exec(`UPDATE channel_allocations SET reserved = reserved - 1
+			WHERE channel_id = $1 AND product_id = $2 AND reserved > 0`, channelID, productID)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return 0, ErrNotReserved
+		}
+		return channelID, nil
+	}
+
+	var released int
+	err := tx.QueryRow(`UPDATE channel_allocations SET reserved = reserved - 1
+		WHERE (channel_id, product_id) = (
+			SELECT channel_id, product_id FROM channel_allocations
+			WHERE product_id = $1 AND reserved > 0
+			ORDER BY channel_id LIMIT 1
+		) AND (SELECT SUM(reserved) FROM channel_allocations WHERE product_id = $1) >= $2
+		RETURNING channel_id`, productID, reserved).Scan(&released)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return released, err
+}
+
+//	@Summary		Reserve products for a sales channel.
+//	@Description	Reserve products from the stock ring-fenced for the channel. Bundle codes reserve all of their components atomically. Fails with 409 when the channel allocation of any product is used up.
+//	@Tags			channels
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int				true	"Channel ID"
+//	@Param			productCodes	body		[]string		true	"Product codes"
+//	@Success		204				{string}	string			""
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		404				{object}	ErrorResponse	"Channel or product not found"
+//	@Failure		409				{object}	ErrorResponse	"Channel allocation is used up or the warehouse is not available now"
+//	@Failure		500				{object}	ErrorResponse
+//	@Router			/channels/{id}/reserve-products [post]
+//
+func ReserveChannelProducts(db *sql.DB, channelID int, productCodes []string) error {
+	if len(productCodes) == 0 {
+		return ErrEmptyProductCodes
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkChannelExists(tx, channelID); err != nil {
+		return err
+	}
+
+	if err := reserveChannelCodes(tx, productCodes, "", channelID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Release products reserved by a sales channel.
+//	@Description	Release products and return them to the channel allocation.
+//	@Tags			channels
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int				true	"Channel ID"
+//	@Param			productCodes	body		[]string		true	"Product codes"
+//	@Success		204				{string}	string			""
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		404				{object}	ErrorResponse	"Channel or product not found"
+//	@Failure		409				{object}	ErrorResponse	"Channel holds no reserved unit of a product"
+//	@Failure		500				{object}	ErrorResponse
+//	@Router			/channels/{id}/release-products [post]
+//
+func ReleaseChannelProducts(db *sql.DB, channelID int, productCodes []string) error {
+	if len(productCodes) == 0 {
+		return ErrEmptyProductCodes
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkChannelExists(tx, channelID); err != nil {
+		return err
+	}
+
+	if err := releaseChannelCodes(tx, productCodes, "", channelID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestChannelAllocation(t *testing.T) {
+	cases := []struct {
+		kind  string
+		value int
+		base  int
+		want  int
+	}{
+		{AllocationFixed, 3, 10, 3},
+		{AllocationFixed, 3, 0, 3},
+		{AllocationPercent, 50, 10, 5},
+		{AllocationPercent, 33, 10, 3},
+		{AllocationPercent, 100, 7, 7},
+	}
+	for _, c := range cases {
+		if got := channelAllocation(c.kind, c.value, c.base); got != c.want {
+			t.Errorf("channelAllocation(%s, %d, %d) = %d, want %d", c.kind, c.value, c.base, got, c.want)
+		}
+	}
+}
+
+func TestChannelRingFencing(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 4)
+
+	partner := &SalesChannel{Name: "partner-" + utils.RandomString(8)}
+	if err := CreateSalesChannel(db, partner); err != nil {
+		t.Fatal(err)
+	}
+	storefront := &SalesChannel{Name: "storefront-" + utils.RandomString(8)}
+	if err := CreateSalesChannel(db, storefront); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChannelAllocation(db, partner.ID, p.Code, ChannelAllocation{Kind: AllocationFixed, Value: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChannelAllocation(db, storefront.ID, p.Code, ChannelAllocation{Kind: AllocationPercent, Value: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	// весь остаток закреплен за каналами
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ring-fenced stock to be unavailable, got %v", err)
+	}
+
+	if err := ReserveChannelProducts(db, partner.ID, []string{p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveChannelProducts(db, partner.ID, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected partner allocation to be used up, got %v", err)
+	}
+
+	lines, err := GetChannelAvailability(db, storefront.ID, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].Allocated != 2 || lines[0].Available != 2 {
+		t.Fatalf("Expected storefront to have 2 available units, got %+v", lines)
+	}
+	if err := ReserveChannelProducts(db, storefront.ID, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 1 {
+		t.Errorf("Expected product quantity to be 1, got %d", q)
+	}
+
+	if err := ReleaseChannelProducts(db, partner.ID, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected released partner unit to stay ring-fenced, got %v", err)
+	}
+	lines, err = GetChannelAvailability(db, partner.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].Reserved != 1 || lines[0].Available != 1 {
+		t.Errorf("Expected partner to have 1 reserved and 1 available unit, got %+v", lines)
+	}
+}
+
+func TestChannelReservationsFollowEveryRelease(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 4)
+
+	ch := &SalesChannel{Name: "channel-" + utils.RandomString(8)}
+	if err := CreateSalesChannel(db, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChannelAllocation(db, ch.ID, p.Code, ChannelAllocation{Kind: AllocationFixed, Value: 2}); err != nil {
+		t.Fatal(err)
+	}
+	channelReserved := func(want int) {
+		t.Helper()
+		lines, err := GetChannelAvailability(db, ch.ID, w.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 || lines[0].Reserved != want {
+			t.Errorf("Expected the channel to hold %d units, got %+v", want, lines)
+		}
+	}
+
+	if err := ReleaseChannelProducts(db, ch.ID, []string{p.Code}); !errors.Is(err, ErrNotReserved) {
+		t.Errorf("Expected ErrNotReserved when the channel holds nothing, got %v", err)
+	}
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveChannelProducts(db, ch.ID, []string{p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	// возврат без канала сначала снимает резерв без канала, а затем резервы каналов
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	channelReserved(2)
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	channelReserved(1)
+
+	// после снятия закрепления резерв остается за каналом и возвращается от его имени
+	if err := DeleteChannelAllocation(db, ch.ID, p.Code); err != nil {
+		t.Fatal(err)
+	}
+	channelReserved(1)
+	if err := ReleaseChannelProducts(db, ch.ID, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	channelReserved(0)
+	if err := DeleteChannelAllocation(db, ch.ID, p.Code); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteChannelAllocation(db, ch.ID, p.Code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the allocation to be removed once nothing is reserved, got %v", err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 4 {
+		t.Errorf("Expected all units back in stock, got %d", q)
+	}
+
+	var channelMovements int
+	err := db.QueryRow("SELECT COUNT(*) FROM stock_movements WHERE product_id = $1 AND channel_id = $2", p.ID, ch.ID).
+		Scan(&channelMovements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channelMovements != 4 {
+		t.Errorf("Expected 2 reserves and 2 releases recorded with the channel, got %d", channelMovements)
+	}
+}
+
+func TestSetChannelAllocationValidation(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+
+	ch := &SalesChannel{Name: "channel-" + utils.RandomString(8)}
+	if err := CreateSalesChannel(db, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateSalesChannel(db, &SalesChannel{Name: ch.Name}); !errors.Is(err, ErrInvalidChannel) {
+		t.Errorf("Expected ErrInvalidChannel for duplicate name, got %v", err)
+	}
+	if err := SetChannelAllocation(db, ch.ID, p.Code, ChannelAllocation{Kind: AllocationPercent, Value: 101}); !errors.Is(err, ErrInvalidChannel) {
+		t.Errorf("Expected ErrInvalidChannel, got %v", err)
+	}
+	if err := SetChannelAllocation(db, -1, p.Code, ChannelAllocation{Kind: AllocationFixed, Value: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	CycleCountOpen      = "open"
+	CycleCountApproved  = "approved"
+	CycleCountCancelled = "cancelled"
+)
+
+type CycleCount struct {
+	ID                 int              `json:"id"`
+	WarehouseID        int              `json:"warehouse_id"`
+	Status             string           `json:"status"`
+	Blind              bool             `json:"blind"`
+	FreezeReservations bool             `json:"freeze_reservations"`
+	CreatedAt          time.Time        `json:"created_at"`
+	ClosedAt           *time.Time       `json:"closed_at,omitempty"`
+	Lines              []CycleCountLine `json:"lines,omitempty"`
+}
+
+// CycleCountLine хранит ожидаемое и фактическое количество товара в сессии пересчета.
+// ExpectedQuantity и Variance скрываются, пока слепой пересчет не закрыт.
+type CycleCountLine struct {
+	ProductID        int    `json:"product_id"`
+	Code             string `json:"code"`
+	ExpectedQuantity *int   `json:"expected_quantity,omitempty"`
+	CountedQuantity  *int   `json:"counted_quantity,omitempty"`
+	Variance         *int   `json:"variance,omitempty"`
+}
+
+type CycleCountEntry struct {
+	Code            string `json:"code"`
+	CountedQuantity int    `json:"counted_quantity"`
+}
+
+//	@Summary		Start a cycle count.
+//	@Description	Start a cycle count session for a warehouse and snapshot expected quantities.
+//	@Description	The expected quantity of a product is its free and reserved units on hand; bundles are not counted.
+//	@Tags			cycle-counts
+//	@Accept			json
+//	@Produce		json
+//	@Param			cycleCount	body		CycleCount		true	"Cycle count settings"
+//	@Success		201			{object}	CycleCount
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/cycle-counts [post]
+//
+func CreateCycleCount(db *sql.DB, c *CycleCount) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO cycle_counts(warehouse_id, blind, freeze_reservations) VALUES($1, $2, $3) RETURNING id, status, created_at",
+		c.WarehouseID, c.Blind, c.FreezeReservations,
+	).Scan(&c.ID, &c.Status, &c.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// на полке лежат и свободные, и зарезервированные, но еще не отобранные единицы; комплекты
+	// собираются из компонентов и отдельно не пересчитываются
+	_, err = tx.Exec(`INSERT INTO cycle_count_lines(cycle_count_id, product_id, expected_quantity)
+		SELECT $1, id, quantity + reserved FROM products WHERE warehouse_id = $2 AND NOT is_bundle`, c.ID, c.WarehouseID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Get a cycle count.
+//	@Description	Get a cycle count session with its lines. Expected quantities are hidden while a blind count is open.
+//	@Tags			cycle-counts
+//	@Produce		json
+//	@Param			id	path		int				true	"Cycle count ID"
+//	@Success		200	{object}	CycleCount
+//	@Failure		404	{object}	ErrorResponse	"Cycle count not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/cycle-counts/{id} [get]
+//
+func GetCycleCount(db *sql.DB, id int) (*CycleCount, error) {
+	var c CycleCount
+	err := db.QueryRow(
+		"SELECT id, warehouse_id, status, blind, freeze_reservations, created_at, closed_at FROM cycle_counts WHERE id = $1", id,
+	).Scan(&c.ID, &c.WarehouseID, &c.Status, &c.Blind, &c.FreezeReservations, &c.CreatedAt, &c.ClosedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT l.product_id, p.code, l.expected_quantity, l.counted_quantity
+		FROM cycle_count_lines l JOIN products p ON p.id = l.product_id
+		WHERE l.cycle_count_id = $1 ORDER BY p.code`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hideExpected := c.Blind && c.Status == CycleCountOpen
+	for rows.Next() {
+		var l CycleCountLine
+		var expected int
+		if err := rows.Scan(&l.ProductID, &l.Code, &expected, &l.CountedQuantity); err != nil {
+			return nil, err
+		}
+		if !hideExpected {
+			l.ExpectedQuantity = &expected
+			if l.CountedQuantity != nil {
+				variance := *l.CountedQuantity - expected
+				l.Variance = &variance
+			}
+		}
+		c.Lines = append(c.Lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+//	@Summary		Record counted quantities.
+//	@Description	Record counted quantities for products of an open cycle count. Repeated entries overwrite earlier ones.
+//	@Tags			cycle-counts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Cycle count ID"
+//	@Param			entries	body		[]CycleCountEntry	true	"Counted quantities"
+//	@Success		204		{string}	string				""
+//	@Failure		400		{object}	ErrorResponse		"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse		"Cycle count or product not found"
+//	@Failure		409		{object}	ErrorResponse		"Cycle count is closed"
+//	@Router			/cycle-counts/{id}/counts [post]
+//
+func RecordCycleCountEntries(db *sql.DB, id int, entries []CycleCountEntry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := lockOpenCycleCount(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, e := range entries {
+		if e.CountedQuantity < 0 {
+			tx.Rollback()
+			return ErrNegativeStock
+		}
+
+		res, err := tx.Exec(`UPDATE cycle_count_lines l SET counted_quantity = $1
+			FROM products p WHERE p.id = l.product_id AND l.cycle_count_id = $2 AND p.code = $3`,
+			e.CountedQuantity, id, e.Code)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return ErrNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Approve a cycle count.
+//	@Description	Close a cycle count and post stock adjustments for every counted line with a variance.
+//	@Description	Adjustments are applied as deltas, so reservations made during an unfrozen count are preserved.
+//	@Tags			cycle-counts
+//	@Produce		json
+//	@Param			id	path		int				true	"Cycle count ID"
+//	@Success		200	{object}	CycleCount
+//	@Failure		404	{object}	ErrorResponse	"Cycle count not found"
+//	@Failure		409	{object}	ErrorResponse	"Cycle count is closed or gains exceed warehouse capacity"
+//	@Router			/cycle-counts/{id}/approve [post]
+//
+func ApproveCycleCount(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := lockOpenCycleCount(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// склад блокируется до товаров, как при проведении поставки: излишки проверяются по вместимости
+	var warehouseID int
+	if err := tx.QueryRow("SELECT warehouse_id FROM cycle_counts WHERE id = $1", id).Scan(&warehouseID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	capacity, err := warehouseCapacity(tx, warehouseID, true)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT product_id, counted_quantity - expected_quantity FROM cycle_count_lines
+		WHERE cycle_count_id = $1 AND counted_quantity IS NOT NULL AND counted_quantity <> expected_quantity`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	type adjustment struct{ productID, delta int }
+	var adjustments []adjustment
+	for rows.Next() {
+		var a adjustment
+		if err := rows.Scan(&a.productID, &a.delta); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		adjustments = append(adjustments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// пересчет закрывается до корректировок: товары размораживаются, и найденные излишки
+	// сразу получает лист ожидания
+	_, err = tx.Exec("UPDATE cycle_counts SET status = $1, closed_at = now() WHERE id = $2", CycleCountApproved, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	reference := "cycle_count:" + strconv.Itoa(id)
+	gained := false
+	for _, a := range adjustments {
+		var quantity int
+		err := tx.QueryRow("UPDATE products SET quantity = quantity + $1 WHERE id = $2 RETURNING quantity", a.delta, a.productID).Scan(&quantity)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if quantity < 0 {
+			tx.Rollback()
+			return ErrNegativeStock
+		}
+
+		if err := recordMovement(tx, a.productID, a.delta, MovementCycleCount, reference); err != nil {
+			tx.Rollback()
+			return err
+		}
+		gained = gained || a.delta > 0
+	}
+
+	if gained {
+		if err := checkStockCapacity(tx, warehouseID, capacity, reference); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Cancel a cycle count.
+//	@Description	Close a cycle count without posting adjustments.
+//	@Tags			cycle-counts
+//	@Produce		json
+//	@Param			id	path		int				true	"Cycle count ID"
+//	@Success		204	{string}	string			""
+//	@Failure		404	{object}	ErrorResponse	"Cycle count not found"
+//	@Failure		409	{object}	ErrorResponse	"Cycle count is closed"
+//	@Router			/cycle-counts/{id}/cancel [post]
+//
+func CancelCycleCount(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := lockOpenCycleCount(tx, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE cycle_counts SET status = $1, closed_at = now() WHERE id = $2", CycleCountCancelled, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockOpenCycleCount блокирует сессию пересчета и проверяет, что она еще открыта
+func lockOpenCycleCount(tx *sql.Tx, id int) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM cycle_counts WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != CycleCountOpen {
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
+
+// checkNotFrozen возвращает ErrProductFrozen, если товар участвует в открытом пересчете с заморозкой резервов
+func checkNotFrozen(q queryer, productID int) error {
+	var frozen bool
+	err := q.QueryRow(`SELECT EXISTS (
+		SELECT 1 FROM cycle_count_lines l JOIN cycle_counts c ON c.id = l.cycle_count_id
+		WHERE l.product_id = $1 AND c.status = $2 AND c.freeze_reservations)`, productID, CycleCountOpen).Scan(&frozen)
+	if err != nil {
+		return err
+	}
+	if frozen {
+		return ErrProductFrozen
+	}
+
+	return nil
+}
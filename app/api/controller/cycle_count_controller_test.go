@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApproveCycleCountPostsAdjustments(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+
+	cc := &CycleCount{WarehouseID: w.ID}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApproveCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 7 {
+		t.Errorf("Expected product quantity to be 7, but got %d", q)
+	}
+
+	err = RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 1}})
+	if !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus for approved cycle count, but got %v", err)
+	}
+}
+
+func TestApproveCycleCountKeepsReservationsMadeDuringCount(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+
+	cc := &CycleCount{WarehouseID: w.ID}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApproveCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 8 {
+		t.Errorf("Expected product quantity to be 8, but got %d", q)
+	}
+}
+
+func TestApproveCycleCountCountsReservedUnitsOnHand(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := &CycleCount{WarehouseID: w.ID}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	// зарезервированная единица еще не отобрана и лежит на полке вместе со свободными
+	err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApproveCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var reserved, adjustments int
+	if err := db.QueryRow("SELECT reserved FROM products WHERE id = $1", p.ID).Scan(&reserved); err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow("SELECT count(*) FROM stock_movements WHERE product_id = $1 AND reason = $2", p.ID, MovementCycleCount).Scan(&adjustments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 9 || reserved != 1 || adjustments != 0 {
+		t.Errorf("Expected no adjustment with 9 free and 1 reserved units, got %d free, %d reserved and %d adjustments", q, reserved, adjustments)
+	}
+}
+
+func TestReserveProductsFrozenByCycleCount(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+
+	cc := &CycleCount{WarehouseID: w.ID, FreezeReservations: true}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ReserveProducts(db, []string{p.Code})
+	if !errors.Is(err, ErrProductFrozen) {
+		t.Errorf("Expected ErrProductFrozen, but got %v", err)
+	}
+
+	if err := CancelCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Errorf("Expected reservation to succeed after cancel, but got %v", err)
+	}
+}
+
+func TestGetCycleCountBlindHidesExpected(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	createTestProduct(t, db, w.ID, 5)
+
+	cc := &CycleCount{WarehouseID: w.ID, Blind: true}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetCycleCount(db, cc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(got.Lines))
+	}
+	if got.Lines[0].ExpectedQuantity != nil {
+		t.Errorf("Expected quantity to be hidden in blind count, got %d", *got.Lines[0].ExpectedQuantity)
+	}
+}
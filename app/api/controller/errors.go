@@ -0,0 +1,39 @@
+package controller
+
+import "errors"
+
+var (
+	ErrEmptyProductCodes = errors.New("empty product codes")
+	ErrOutOfStock        = errors.New("product is out of stock")
+	ErrNotFound          = errors.New("not found")
+	ErrProductFrozen     = errors.New("product is frozen by an open cycle count")
+	ErrInvalidStatus     = errors.New("operation is not allowed in the current status")
+	ErrNegativeStock     = errors.New("stock quantity cannot become negative")
+	ErrInvalidQuantity   = errors.New("quantity must be positive")
+	ErrInvalidLocation   = errors.New("invalid location")
+	ErrInvalidLot        = errors.New("invalid lot")
+	ErrInvalidReceipt    = errors.New("invalid receipt")
+	ErrSerialsMismatch   = errors.New("serial numbers do not match the received quantity")
+	ErrSerialUnavailable = errors.New("serial number is not in the required status")
+	ErrDuplicateSerial   = errors.New("serial number is already registered")
+	ErrInvalidBundle     = errors.New("invalid bundle")
+	ErrInvalidCatalog    = errors.New("invalid catalog data")
+	ErrInvalidCode       = errors.New("invalid product code")
+	ErrDuplicateCode     = errors.New("product code is already used")
+	ErrTooManyLabels     = errors.New("too many labels in one batch")
+	ErrInvalidImport     = errors.New("invalid import file")
+	ErrVersionMismatch   = errors.New("resource version does not match")
+	ErrInvalidWebhook    = errors.New("invalid webhook subscription")
+	ErrInvalidOrderEvent = errors.New("invalid order event")
+	ErrInvalidThreshold  = errors.New("invalid stock threshold")
+	ErrInvalidReport     = errors.New("invalid report parameters")
+	ErrInvalidChannel    = errors.New("invalid sales channel")
+	ErrInvalidCapacity   = errors.New("invalid capacity or dimensions")
+	ErrCapacityExceeded  = errors.New("warehouse capacity would be exceeded")
+	ErrInvalidGeoPoint   = errors.New("invalid warehouse location or coordinates")
+	ErrInvalidCalendar   = errors.New("invalid warehouse calendar")
+	ErrInvalidRegion     = errors.New("invalid or duplicate region")
+	ErrLotExpiryMismatch = errors.New("lot is already registered with another expiry date")
+	ErrNotReserved       = errors.New("no reserved units to release")
+	ErrWarehouseClosed   = errors.New("warehouse is not available now")
+)
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"database/sql"
+)
+
+// StockExportColumns — названия столбцов выгрузки остатков в порядке полей StockExportRow.Values
+var StockExportColumns = []string{
+	"warehouse_id", "warehouse_name", "product_id", "code", "name", "size", "style",
+	"quantity", "reserved", "expired", "available", "in_bins", "serial_tracked", "barcodes",
+}
+
+// StockExportRow — строка выгрузки остатков. Quantity — свободный остаток товара, Reserved —
+// зарезервированные, но еще не отобранные единицы, Expired — часть свободного остатка в просроченных
+// партиях, Available — доступное для резерва без канала количество: свободный остаток без просроченных
+// партий и единиц, закрепленных за каналами продаж.
+type StockExportRow struct {
+	WarehouseID   int    `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	ProductID     int    `json:"product_id"`
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Size          string `json:"size"`
+	Style         string `json:"style,omitempty"`
+	Quantity      int    `json:"quantity"`
+	Reserved      int    `json:"reserved"`
+	Expired       int    `json:"expired"`
+	Available     int    `json:"available"`
+	InBins        int    `json:"in_bins"`
+	SerialTracked bool   `json:"serial_tracked"`
+	Barcodes      string `json:"barcodes,omitempty"`
+}
+
+// Values возвращает значения строки в порядке StockExportColumns
+func (r StockExportRow) Values() []interface{} {
+	return []interface{}{
+		r.WarehouseID, r.WarehouseName, r.ProductID, r.Code, r.Name, r.Size, r.Style,
+		r.Quantity, r.Reserved, r.Expired, r.Available, r.InBins, r.SerialTracked, r.Barcodes,
+	}
+}
+
+//	@Summary		Export stock.
+//	@Description	Stream product and stock data of one warehouse or of all warehouses when warehouse_id is omitted.
+//	@Description	The format is chosen by the format parameter or the Accept header: CSV (default), XLSX or NDJSON.
+//	@Description	Bundles are virtual products without own stock and are not exported.
+//	@Description	The available column excludes expired lots and units ring-fenced for sales channels, as for reservations without a channel.
+//	@Tags			products
+//	@Produce		text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+//	@Param			warehouse_id	query		int				false	"Warehouse ID"
+//	@Param			format			query		string			false	"Export format: csv, xlsx or ndjson"
+//	@Success		200				{file}		file
+//	@Failure		400				{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404				{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		406				{object}	ErrorResponse	"Unsupported format"
+//	@Router			/export/stock [get]
+//
+func ExportStock(db *sql.DB, warehouseID int, write func(StockExportRow) error) error {
+	rows, err := db.Query(`SELECT w.id, COALESCE(w.name, ''), p.id, p.code, COALESCE(p.name, ''), COALESCE(p.size, ''),
+			COALESCE(s.code, ''), p.quantity, p.reserved,
+			`+expiredLotsSQL+`,
+			EXISTS (SELECT 1 FROM channel_allocations a WHERE a.product_id = p.id),
+			COALESCE((SELECT SUM(b.quantity + b.reserved) FROM bin_stock b WHERE b.product_id = p.id), 0),
+			p.serial_tracked,
+			COALESCE((SELECT string_agg(b.barcode, ';' ORDER BY b.barcode) FROM product_barcodes b WHERE b.product_id = p.id), '')
+		FROM products p
+		JOIN warehouse w ON w.id = p.warehouse_id
+		LEFT JOIN styles s ON s.id = p.style_id
+		WHERE NOT p.is_bundle AND ($1 = 0 OR p.warehouse_id = $1)
+		ORDER BY p.warehouse_id, p.code`, warehouseID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r StockExportRow
+		var fenced bool
+		err := rows.Scan(&r.WarehouseID, &r.WarehouseName, &r.ProductID, &r.Code, &r.Name, &r.Size,
+			&r.Style, &r.Quantity, &r.Reserved, &r.Expired, &fenced, &r.InBins, &r.SerialTracked, &r.Barcodes)
+		if err != nil {
+			return err
+		}
+
+		// закрепления за каналами читаются только у закрепленных товаров, как при поиске ближних складов
+		shared := r.Quantity
+		if fenced {
+			if shared, err = sharedStock(db, r.ProductID, r.Quantity); err != nil {
+				return err
+			}
+		}
+		r.Available = max(shared-r.Expired, 0)
+
+		if err := write(r); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
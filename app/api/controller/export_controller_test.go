@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestExportStockStreamsWarehouseProducts(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	first := createTestProduct(t, db, w.ID, 4)
+	second := createTestProduct(t, db, w.ID, 0)
+	createTestProduct(t, db, createTestWarehouse(t, db).ID, 1)
+
+	// одна единица зарезервирована, еще одна закреплена за каналом
+	ch := &SalesChannel{Name: "channel-" + first.Code}
+	if err := CreateSalesChannel(db, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChannelAllocation(db, ch.ID, first.Code, ChannelAllocation{Kind: AllocationFixed, Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{first.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported []StockExportRow
+	err := ExportStock(db, w.ID, func(r StockExportRow) error {
+		exported = append(exported, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported) != 2 {
+		t.Fatalf("Expected 2 exported rows, got %d", len(exported))
+	}
+	for _, r := range exported {
+		if r.WarehouseID != w.ID || r.WarehouseName != w.Name {
+			t.Errorf("Unexpected warehouse in row %+v", r)
+		}
+		if r.Code == first.Code && (r.Quantity != 3 || r.Reserved != 1 || r.Available != 2) {
+			t.Errorf("Expected 3 free, 1 reserved and 2 available units of %s, got %+v", first.Code, r)
+		}
+		if r.Code == second.Code && r.Quantity != 0 {
+			t.Errorf("Expected no stock of %s, got %d", second.Code, r.Quantity)
+		}
+	}
+
+	if len(exported[0].Values()) != len(StockExportColumns) {
+		t.Errorf("Row values do not match export columns")
+	}
+}
+
+func TestGetWarehouseNotFound(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := GetWarehouse(db, -1); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, but got %v", err)
+	}
+}
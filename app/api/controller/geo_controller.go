@@ -0,0 +1,448 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/geo"
+)
+
+const defaultNearestLimit = 10
+
+// WarehouseLocation — адрес и координаты склада. Координаты задаются вместе или не задаются вовсе.
+type WarehouseLocation struct {
+	Address   *string  `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// NearestQuery — корзина и точка доставки. Items — коды товаров по одному на единицу, как в резерве.
+// Location обязательна: без нее запрос отклоняется, а не ищет склады от точки (0, 0).
+type NearestQuery struct {
+	Items    []string   `json:"items"`
+	Location *geo.Point `json:"location"`
+	Limit    int        `json:"limit"`
+}
+
+// WarehouseMatch — склад, который может собрать всю корзину, и товары, которыми он ее соберет.
+// У склада без координат не заданы Latitude, Longitude и DistanceKM.
+type WarehouseMatch struct {
+	WarehouseID int          `json:"warehouse_id"`
+	Name        string       `json:"name"`
+	Address     *string      `json:"address,omitempty"`
+	Latitude    *float64     `json:"latitude,omitempty"`
+	Longitude   *float64     `json:"longitude,omitempty"`
+	DistanceKM  *float64     `json:"distance_km,omitempty"`
+	Items       []BasketItem `json:"items"`
+}
+
+// BasketItem — сколько единиц кода из корзины берется из товара ProductCode
+type BasketItem struct {
+	Code        string `json:"code"`
+	ProductCode string `json:"product_code"`
+	Quantity    int    `json:"quantity"`
+}
+
+// NearestAllocation — единицы кода из корзины, зарезервированные на складе. DistanceKM не задано
+// для склада без координат.
+type NearestAllocation struct {
+	Code        string   `json:"code"`
+	ProductCode string   `json:"product_code"`
+	WarehouseID int      `json:"warehouse_id"`
+	DistanceKM  *float64 `json:"distance_km,omitempty"`
+	Quantity    int      `json:"quantity"`
+}
+
+// equivalent — товар, которым можно собрать код корзины: сам товар или тот же размер той же модели на другом складе.
+// quantity — сколько единиц доступно резерву без канала: без просроченных партий и закрепленных за каналами.
+type equivalent struct {
+	productID   int
+	code        string
+	warehouseID int
+	quantity    int
+}
+
+// locatedWarehouse — доступный склад и расстояние до него; point равно nil у склада без координат
+type locatedWarehouse struct {
+	id         int
+	name       string
+	address    *string
+	point      *geo.Point
+	distanceKM float64
+}
+
+// distance возвращает округленное расстояние до склада или nil, если у склада нет координат
+func (w locatedWarehouse) distance() *float64 {
+	if w.point == nil {
+		return nil
+	}
+	km := roundKM(w.distanceKM)
+	return &km
+}
+
+func (l WarehouseLocation) validate() error {
+	if (l.Latitude == nil) != (l.Longitude == nil) {
+		return ErrInvalidGeoPoint
+	}
+	if l.Latitude != nil && !(geo.Point{Latitude: *l.Latitude, Longitude: *l.Longitude}).Valid() {
+		return ErrInvalidGeoPoint
+	}
+	return nil
+}
+
+//	@Summary		Set warehouse location.
+//	@Description	Set the address and coordinates of a warehouse. Available warehouses without coordinates take part in nearest-warehouse lookups and distance-based reservations after all warehouses with coordinates.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Warehouse ID"
+//	@Param			location	body		WarehouseLocation	true	"Address and coordinates"
+//	@Success		200			{object}	WarehouseLocation
+//	@Failure		400			{object}	ErrorResponse		"Invalid coordinates"
+//	@Failure		404			{object}	ErrorResponse		"Warehouse not found"
+//	@Failure		500			{object}	ErrorResponse		"Internal server error"
+//	@Router			/warehouses/{id}/location [put]
+//
+func SetWarehouseLocation(db *sql.DB, warehouseID int, l WarehouseLocation) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("UPDATE warehouse SET address = $1, latitude = $2, longitude = $3 WHERE id = $4",
+		l.Address, l.Latitude, l.Longitude, warehouseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Find the nearest warehouses for a basket.
+//	@Description	List warehouses available now by their flag and calendar that can fulfil the whole basket from their own stock, nearest to the location first; warehouses without coordinates follow without a distance. Only stock outside expired lots and channel allocations counts. A code can be fulfilled by the same size of the same style stored in another warehouse; bundle codes are expanded into components.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			query	body		NearestQuery	true	"Basket and customer location"
+//	@Success		200		{array}		WarehouseMatch
+//	@Failure		400		{object}	ErrorResponse	"Invalid basket or coordinates"
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/nearest [post]
+//
+func FindNearestWarehouses(db *sql.DB, query NearestQuery) ([]WarehouseMatch, error) {
+	if len(query.Items) == 0 {
+		return nil, ErrEmptyProductCodes
+	}
+	if query.Location == nil || !query.Location.Valid() {
+		return nil, ErrInvalidGeoPoint
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultNearestLimit
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, demand, err := basketDemand(tx, query.Items)
+	if err != nil {
+		return nil, err
+	}
+	equivalents, err := basketEquivalents(tx, codes)
+	if err != nil {
+		return nil, err
+	}
+	warehouses, err := locatedWarehouses(tx, *query.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []WarehouseMatch{}
+	for _, w := range warehouses {
+		items, ok := fulfilFromWarehouse(codes, demand, equivalents, w.id)
+		if !ok {
+			continue
+		}
+		m := WarehouseMatch{
+			WarehouseID: w.id,
+			Name:        w.name,
+			Address:     w.address,
+			DistanceKM:  w.distance(),
+			Items:       items,
+		}
+		if w.point != nil {
+			m.Latitude, m.Longitude = &w.point.Latitude, &w.point.Longitude
+		}
+		matches = append(matches, m)
+		if len(matches) == query.Limit {
+			break
+		}
+	}
+
+	return matches, nil
+}
+
+// ReserveProductsNearest резервирует корзину по расстоянию до точки доставки. Если корзину целиком
+// может собрать один склад, резерв берется с ближайшего такого склада; иначе каждая единица резервируется
+// на ближайшем складе, где она есть. Учитываются доступные сейчас склады; склады без координат — после
+// всех складов с координатами.
+func ReserveProductsNearest(db *sql.DB, productCodes []string, location geo.Point) ([]NearestAllocation, error) {
+	if len(productCodes) == 0 {
+		return nil, ErrEmptyProductCodes
+	}
+	if !location.Valid() {
+		return nil, ErrInvalidGeoPoint
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, demand, err := basketDemand(tx, productCodes)
+	if err != nil {
+		return nil, err
+	}
+	equivalents, err := basketEquivalents(tx, codes)
+	if err != nil {
+		return nil, err
+	}
+	warehouses, err := locatedWarehouses(tx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// склад, собирающий всю корзину, идет первым, чтобы не дробить отправку
+	for i, w := range warehouses {
+		if _, ok := fulfilFromWarehouse(codes, demand, equivalents, w.id); ok {
+			preferred := w
+			copy(warehouses[1:i+1], warehouses[:i])
+			warehouses[0] = preferred
+			break
+		}
+	}
+
+	ids := make([]int, len(warehouses))
+	distances := make(map[int]*float64, len(warehouses))
+	for i, w := range warehouses {
+		ids[i] = w.id
+		distances[w.id] = w.distance()
+	}
+
+	allocations := []NearestAllocation{}
+	for _, code := range codes {
+		for n := 0; n < demand[code]; n++ {
+			productCode, warehouseID, err := reserveFirstUnit(tx, equivalents[code], ids)
+			if err != nil {
+				return nil, err
+			}
+			allocations = addAllocation(allocations, NearestAllocation{
+				Code:        code,
+				ProductCode: productCode,
+				WarehouseID: warehouseID,
+				DistanceKM:  distances[warehouseID],
+				Quantity:    1,
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+// reserveFirstUnit резервирует единицу на первом по порядку складе, где ее удается зарезервировать,
+// и возвращает код зарезервированного товара и склад
+func reserveFirstUnit(tx *sql.Tx, candidates []equivalent, warehouseIDs []int) (string, int, error) {
+	for _, warehouseID := range warehouseIDs {
+		for i := range candidates {
+			e := &candidates[i]
+			if e.warehouseID != warehouseID || e.quantity < 1 {
+				continue
+			}
+
+			p, err := reserveUnit(tx, e.code, "")
+			if errors.Is(err, ErrOutOfStock) || errors.Is(err, ErrProductFrozen) || errors.Is(err, ErrWarehouseClosed) {
+				e.quantity = 0
+				continue
+			}
+			if err != nil {
+				return "", 0, err
+			}
+			if err := reserveAnySerial(tx, p); err != nil {
+				return "", 0, err
+			}
+			e.quantity--
+
+			return e.code, warehouseID, nil
+		}
+	}
+
+	return "", 0, ErrOutOfStock
+}
+
+func addAllocation(allocations []NearestAllocation, a NearestAllocation) []NearestAllocation {
+	for i := range allocations {
+		if allocations[i].Code == a.Code && allocations[i].ProductCode == a.ProductCode {
+			allocations[i].Quantity++
+			return allocations
+		}
+	}
+	return append(allocations, a)
+}
+
+// basketDemand раскладывает корзину на компоненты и возвращает коды в порядке появления и нужное количество каждого
+func basketDemand(tx *sql.Tx, items []string) ([]string, map[string]int, error) {
+	var codes []string
+	demand := make(map[string]int)
+	for _, item := range items {
+		expanded, err := expandCode(tx, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, code := range expanded {
+			if demand[code] == 0 {
+				codes = append(codes, code)
+			}
+			demand[code]++
+		}
+	}
+
+	return codes, demand, nil
+}
+
+func basketEquivalents(q queryer, codes []string) (map[string][]equivalent, error) {
+	equivalents := make(map[string][]equivalent, len(codes))
+	for _, code := range codes {
+		rows, err := q.Query(`SELECT p.id, p.code, COALESCE(p.warehouse_id, 0), p.quantity, `+expiredLotsSQL+`
+			FROM products c JOIN products p ON p.id = c.id OR (p.style_id = c.style_id AND p.size_id = c.size_id)
+			WHERE c.code = $1 AND NOT p.is_bundle
+			ORDER BY p.id`, code)
+		if err != nil {
+			return nil, err
+		}
+
+		var expired []int
+		for rows.Next() {
+			var e equivalent
+			var n int
+			if err := rows.Scan(&e.productID, &e.code, &e.warehouseID, &e.quantity, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			equivalents[code] = append(equivalents[code], e)
+			expired = append(expired, n)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// закрепление за каналами считается от всего остатка, как при резерве
+		for i := range equivalents[code] {
+			e := &equivalents[code][i]
+			shared, err := sharedStock(q, e.productID, e.quantity)
+			if err != nil {
+				return nil, err
+			}
+			e.quantity = max(shared-expired[i], 0)
+		}
+	}
+
+	return equivalents, nil
+}
+
+// locatedWarehouses возвращает склады, доступные сейчас по календарю, по возрастанию расстояния до точки.
+// Склады без координат идут после всех складов с координатами в порядке ID.
+func locatedWarehouses(q queryer, origin geo.Point) ([]locatedWarehouse, error) {
+	rows, err := q.Query(`SELECT id, COALESCE(name, ''), address, latitude, longitude FROM warehouse
+		WHERE (is_available OR calendar_closed) ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []locatedWarehouse
+	for rows.Next() {
+		var w locatedWarehouse
+		var latitude, longitude sql.NullFloat64
+		if err := rows.Scan(&w.id, &w.name, &w.address, &latitude, &longitude); err != nil {
+			return nil, err
+		}
+		if latitude.Valid && longitude.Valid {
+			w.point = &geo.Point{Latitude: latitude.Float64, Longitude: longitude.Float64}
+			w.distanceKM = geo.DistanceKM(origin, *w.point)
+		}
+		candidates = append(candidates, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	now := time.Now()
+	var warehouses []locatedWarehouse
+	for _, w := range candidates {
+		// выключенные вручную склады отсеяны запросом, здесь остается проверить календарь
+		a, err := warehouseAvailability(q, w.id, true, false, now)
+		if err != nil {
+			return nil, err
+		}
+		if a.IsAvailable {
+			warehouses = append(warehouses, w)
+		}
+	}
+
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		if (warehouses[i].point == nil) != (warehouses[j].point == nil) {
+			return warehouses[j].point == nil
+		}
+		return warehouses[i].distanceKM < warehouses[j].distanceKM
+	})
+
+	return warehouses, nil
+}
+
+// fulfilFromWarehouse подбирает товары склада под всю корзину; ok равно false, если чего-то не хватает
+func fulfilFromWarehouse(codes []string, demand map[string]int, equivalents map[string][]equivalent, warehouseID int) ([]BasketItem, bool) {
+	used := make(map[int]int)
+	var items []BasketItem
+	for _, code := range codes {
+		need := demand[code]
+		for _, e := range equivalents[code] {
+			if e.warehouseID != warehouseID || need == 0 {
+				continue
+			}
+			take := e.quantity - used[e.productID]
+			if take > need {
+				take = need
+			}
+			if take <= 0 {
+				continue
+			}
+			used[e.productID] += take
+			need -= take
+			items = append(items, BasketItem{Code: code, ProductCode: e.code, Quantity: take})
+		}
+		if need > 0 {
+			return nil, false
+		}
+	}
+
+	return items, true
+}
+
+func roundKM(km float64) float64 {
+	return math.Round(km*1000) / 1000
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/geo"
+)
+
+func TestNearestWarehousesAndAllocation(t *testing.T) {
+	db := openTestDB(t)
+	s := createTestStyle(t, db)
+
+	moscow := createTestWarehouse(t, db)
+	if err := SetWarehouseLocation(db, moscow.ID, WarehouseLocation{Latitude: floatPtr(55.7558), Longitude: floatPtr(37.6173)}); err != nil {
+		t.Fatal(err)
+	}
+	petersburg := createTestWarehouse(t, db)
+	if err := SetWarehouseLocation(db, petersburg.ID, WarehouseLocation{Latitude: floatPtr(59.9343), Longitude: floatPtr(30.3351)}); err != nil {
+		t.Fatal(err)
+	}
+
+	mMoscow := createTestProduct(t, db, moscow.ID, 1)
+	mPetersburg := createTestProduct(t, db, petersburg.ID, 3)
+	lPetersburg := createTestProduct(t, db, petersburg.ID, 1)
+	for code, size := range map[string]string{mMoscow.Code: "M", mPetersburg.Code: "M", lPetersburg.Code: "L"} {
+		if err := AddStyleVariant(db, s.Code, StyleVariant{Code: code, Size: size}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	customer := geo.Point{Latitude: 55.75, Longitude: 37.6}
+
+	matches, err := FindNearestWarehouses(db, NearestQuery{Items: []string{mPetersburg.Code}, Location: &customer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].WarehouseID != moscow.ID || matches[1].WarehouseID != petersburg.ID {
+		t.Fatalf("Expected Moscow then Saint Petersburg, got %+v", matches)
+	}
+	if matches[0].Items[0].ProductCode != mMoscow.Code || matches[0].DistanceKM == nil || *matches[0].DistanceKM > 5 {
+		t.Errorf("Expected the Moscow variant of the same size nearby, got %+v", matches[0])
+	}
+
+	matches, err = FindNearestWarehouses(db, NearestQuery{Items: []string{mMoscow.Code, lPetersburg.Code}, Location: &customer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].WarehouseID != petersburg.ID {
+		t.Fatalf("Expected only Saint Petersburg to fulfil the basket, got %+v", matches)
+	}
+
+	// склад, собирающий корзину целиком, важнее ближайшего
+	allocations, err := ReserveProductsNearest(db, []string{mMoscow.Code, lPetersburg.Code}, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range allocations {
+		if a.WarehouseID != petersburg.ID {
+			t.Errorf("Expected whole basket from Saint Petersburg, got %+v", allocations)
+		}
+	}
+	if q := productQuantity(t, db, mPetersburg.ID); q != 2 {
+		t.Errorf("Expected Saint Petersburg M quantity to be 2, got %d", q)
+	}
+
+	allocations, err = ReserveProductsNearest(db, []string{mMoscow.Code, mMoscow.Code, mMoscow.Code}, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 2 || allocations[0].ProductCode != mMoscow.Code || allocations[0].Quantity != 1 ||
+		allocations[1].ProductCode != mPetersburg.Code || allocations[1].Quantity != 2 {
+		t.Errorf("Expected 1 unit from Moscow and 2 from Saint Petersburg, got %+v", allocations)
+	}
+
+	if _, err := ReserveProductsNearest(db, []string{mMoscow.Code}, customer); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock, got %v", err)
+	}
+}
+
+func TestNearestWarehousesCountReservableStock(t *testing.T) {
+	db := openTestDB(t)
+	s := createTestStyle(t, db)
+
+	fenced := createTestWarehouse(t, db)
+	if err := SetWarehouseLocation(db, fenced.ID, WarehouseLocation{Latitude: floatPtr(55.7558), Longitude: floatPtr(37.6173)}); err != nil {
+		t.Fatal(err)
+	}
+	expired := createTestWarehouse(t, db)
+	if err := SetWarehouseLocation(db, expired.ID, WarehouseLocation{Latitude: floatPtr(59.9343), Longitude: floatPtr(30.3351)}); err != nil {
+		t.Fatal(err)
+	}
+	unlocated := createTestWarehouse(t, db)
+
+	pFenced := createTestProduct(t, db, fenced.ID, 2)
+	pExpired := createTestProduct(t, db, expired.ID, 0)
+	pUnlocated := createTestProduct(t, db, unlocated.ID, 1)
+	for _, code := range []string{pFenced.Code, pExpired.Code, pUnlocated.Code} {
+		if err := AddStyleVariant(db, s.Code, StyleVariant{Code: code, Size: "M"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch := &SalesChannel{Name: "channel-" + pFenced.Code}
+	if err := CreateSalesChannel(db, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetChannelAllocation(db, ch.ID, pFenced.Code, ChannelAllocation{Kind: AllocationFixed, Value: 2}); err != nil {
+		t.Fatal(err)
+	}
+	lot := &StockLot{Code: pExpired.Code, LotNumber: "L-OLD", ExpiryDate: time.Now().AddDate(0, 0, -1).Format(dateLayout), Quantity: 1}
+	if err := ReceiveLot(db, lot); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FindNearestWarehouses(db, NearestQuery{Items: []string{pFenced.Code}}); !errors.Is(err, ErrInvalidGeoPoint) {
+		t.Errorf("Expected ErrInvalidGeoPoint without a location, got %v", err)
+	}
+
+	// ближние склады держат только закрепленный за каналом и просроченный остаток
+	customer := geo.Point{Latitude: 55.75, Longitude: 37.6}
+	matches, err := FindNearestWarehouses(db, NearestQuery{Items: []string{pFenced.Code}, Location: &customer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].WarehouseID != unlocated.ID || matches[0].DistanceKM != nil {
+		t.Fatalf("Expected only the warehouse without coordinates, got %+v", matches)
+	}
+
+	allocations, err := ReserveProductsNearest(db, []string{pFenced.Code}, customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 1 || allocations[0].ProductCode != pUnlocated.Code || allocations[0].DistanceKM != nil {
+		t.Errorf("Expected the unit from the warehouse without coordinates, got %+v", allocations)
+	}
+}
+
+func TestSetWarehouseLocationValidation(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	if err := SetWarehouseLocation(db, w.ID, WarehouseLocation{Latitude: floatPtr(10)}); !errors.Is(err, ErrInvalidGeoPoint) {
+		t.Errorf("Expected ErrInvalidGeoPoint for missing longitude, got %v", err)
+	}
+	if err := SetWarehouseLocation(db, w.ID, WarehouseLocation{Latitude: floatPtr(95), Longitude: floatPtr(0)}); !errors.Is(err, ErrInvalidGeoPoint) {
+		t.Errorf("Expected ErrInvalidGeoPoint for out of range latitude, got %v", err)
+	}
+}
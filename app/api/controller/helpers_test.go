@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+
+	_ "github.com/lib/pq"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("postgres", "host=localhost port=5432 user=root password=secret dbname=lamoda_db sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func createTestWarehouse(t *testing.T, db *sql.DB) *Warehouse {
+	t.Helper()
+
+	w := &Warehouse{
+		Name:        utils.RandomString(6),
+		IsAvailable: true,
+	}
+	if err := CreateWarehouse(db, w); err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func createTestProduct(t *testing.T, db *sql.DB, warehouseID, quantity int) *Product {
+	t.Helper()
+
+	p := &Product{
+		Name:        utils.RandomString(6),
+		Size:        utils.RandomString(6),
+		Code:        utils.RandomString(10),
+		Quantity:    quantity,
+		WarehouseID: warehouseID,
+	}
+	if err := CreateProduct(db, p); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func productQuantity(t *testing.T, db *sql.DB, id int) int {
+	t.Helper()
+
+	var quantity int
+	if err := db.QueryRow("SELECT quantity FROM products WHERE id = $1", id).Scan(&quantity); err != nil {
+		t.Fatal(err)
+	}
+
+	return quantity
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// idempotencyRetention — сколько хранится первый ответ на запрос с ключом идемпотентности.
+// idempotencyLease — сколько ключ остается за выполняющимся запросом: если процесс упал,
+// не успев сохранить ответ или освободить ключ, по истечении аренды ключ может занять повторный запрос.
+var (
+	idempotencyRetention = 24 * time.Hour
+	idempotencyLease     = time.Minute
+)
+
+// SetIdempotencyPolicy задает срок хранения ответов и срок аренды ключа выполняющимся запросом
+func SetIdempotencyPolicy(retention, lease time.Duration) {
+	idempotencyRetention, idempotencyLease = retention, lease
+}
+
+// IdempotentResponse — сохраненный ответ на первый запрос с ключом идемпотентности.
+// Пока запрос выполняется, StatusCode равен нулю.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// BeginIdempotentRequest занимает ключ за запросом с хешем requestHash. Если ключ свободен,
+// срок хранения его ответа истек или истекла аренда незавершенного запроса, возвращает nil;
+// иначе — ранее сохраненную запись.
+func BeginIdempotentRequest(db *sql.DB, key, requestHash string) (*IdempotentResponse, error) {
+	res, err := db.Exec(`INSERT INTO idempotency_keys(key, request_hash) VALUES($1, $2)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = NULL,
+			content_type = NULL, response = NULL, created_at = now()
+		WHERE idempotency_keys.created_at < now() - $3 * INTERVAL '1 second'
+			OR idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < now() - $4 * INTERVAL '1 second'`,
+		key, requestHash, idempotencyRetention.Seconds(), idempotencyLease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	var r IdempotentResponse
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	err = db.QueryRow("SELECT request_hash, status_code, content_type, response FROM idempotency_keys WHERE key = $1", key).
+		Scan(&r.RequestHash, &statusCode, &contentType, &r.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// ключ успели удалить между запросами — пробуем занять его заново
+		return BeginIdempotentRequest(db, key, requestHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.StatusCode, r.ContentType = int(statusCode.Int64), contentType.String
+
+	return &r, nil
+}
+
+// CompleteIdempotentRequest сохраняет ответ на запрос, занявший ключ. Если после истечения аренды
+// ключ занял другой запрос или ответ уже сохранен, ничего не меняет.
+func CompleteIdempotentRequest(db *sql.DB, key, requestHash string, statusCode int, contentType string, body []byte) error {
+	_, err := db.Exec(`UPDATE idempotency_keys SET status_code = $1, content_type = $2, response = $3
+		WHERE key = $4 AND request_hash = $5 AND status_code IS NULL`,
+		statusCode, contentType, body, key, requestHash)
+	return err
+}
+
+// ReleaseIdempotencyKey освобождает ключ незавершенного запроса, чтобы его можно было повторить,
+// например после ошибки сервера или паники в обработчике
+func ReleaseIdempotencyKey(db *sql.DB, key, requestHash string) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE key = $1 AND request_hash = $2 AND status_code IS NULL", key, requestHash)
+	return err
+}
+
+// PurgeIdempotencyKeys удаляет ключи, срок хранения ответов которых истек
+func PurgeIdempotencyKeys(db *sql.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM idempotency_keys WHERE created_at < now() - $1 * INTERVAL '1 second'", idempotencyRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
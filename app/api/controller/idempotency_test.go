@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestIdempotentRequestIsStoredAndReplayed(t *testing.T) {
+	db := openTestDB(t)
+	key := utils.RandomString(16)
+
+	stored, err := BeginIdempotentRequest(db, key, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != nil {
+		t.Fatalf("Expected a new key, got %+v", stored)
+	}
+
+	stored, err = BeginIdempotentRequest(db, key, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.StatusCode != 0 {
+		t.Fatalf("Expected an in-progress record, got %+v", stored)
+	}
+
+	if err := CompleteIdempotentRequest(db, key, "hash-1", 200, "application/json", []byte(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err = BeginIdempotentRequest(db, key, "hash-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.RequestHash != "hash-1" || stored.StatusCode != 200 || string(stored.Body) != `{"ok":true}` {
+		t.Errorf("Unexpected stored response %+v", stored)
+	}
+
+	// сохраненный ответ не освобождается
+	if err := ReleaseIdempotencyKey(db, key, "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := BeginIdempotentRequest(db, key, "hash-1"); err != nil || stored == nil || stored.StatusCode != 200 {
+		t.Errorf("Expected the stored response to survive a release, got %+v, %v", stored, err)
+	}
+}
+
+func TestIdempotencyKeyIsReleasedAndTakenOver(t *testing.T) {
+	db := openTestDB(t)
+	key := utils.RandomString(16)
+
+	if stored, err := BeginIdempotentRequest(db, key, "hash-1"); err != nil || stored != nil {
+		t.Fatalf("Expected a new key, got %+v, %v", stored, err)
+	}
+	if err := ReleaseIdempotencyKey(db, key, "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := BeginIdempotentRequest(db, key, "hash-2"); err != nil || stored != nil {
+		t.Fatalf("Expected released key to be free, got %+v, %v", stored, err)
+	}
+
+	// аренда зависшего запроса истекла: ключ занимает повтор, а поздний ответ первого запроса не сохраняется
+	retention, lease := idempotencyRetention, idempotencyLease
+	SetIdempotencyPolicy(retention, -time.Second)
+	defer SetIdempotencyPolicy(retention, lease)
+
+	if stored, err := BeginIdempotentRequest(db, key, "hash-3"); err != nil || stored != nil {
+		t.Fatalf("Expected an expired lease to be taken over, got %+v, %v", stored, err)
+	}
+	if err := CompleteIdempotentRequest(db, key, "hash-2", 500, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompleteIdempotentRequest(db, key, "hash-3", 201, "application/json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	SetIdempotencyPolicy(retention, lease)
+	stored, err := BeginIdempotentRequest(db, key, "hash-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.RequestHash != "hash-3" || stored.StatusCode != 201 {
+		t.Errorf("Expected the response of the request holding the key, got %+v", stored)
+	}
+}
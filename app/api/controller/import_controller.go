@@ -0,0 +1,436 @@
+package controller
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/xlsx"
+	"github.com/lib/pq"
+)
+
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatXLSX = "xlsx"
+)
+
+// importColumns — обязательные столбцы файла импорта; столбец size может отсутствовать
+var importColumns = []string{"name", "code", "quantity", "warehouse_id"}
+
+// ImportRow — проверенная строка файла импорта. Line — номер строки в файле, заголовок — строка 1.
+type ImportRow struct {
+	Line        int
+	Name        string
+	Size        string
+	Code        string
+	Quantity    int
+	WarehouseID int
+}
+
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportOptions задает режим импорта. При ChunkSize > 0 строки записываются частями
+// в отдельных транзакциях, а прерванный импорт продолжается с FromLine.
+type ImportOptions struct {
+	DryRun    bool
+	ChunkSize int
+	FromLine  int
+}
+
+// ImportReport — результат импорта. LastLine — последняя записанная строка файла,
+// с которой можно продолжить импорт после сбоя.
+type ImportReport struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Created  int              `json:"created"`
+	Updated  int              `json:"updated"`
+	LastLine int              `json:"last_line,omitempty"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// readImportFile читает товары из файла CSV или XLSX с заголовком name, size, code, quantity, warehouse_id.
+// Строки с ошибками не попадают в результат и возвращаются отдельным списком.
+func readImportFile(r io.Reader, format string) ([]ImportRow, []ImportRowError, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// lines хранит номера строк файла: пустые строки CSV и XLSX пропускаются при чтении
+	var records [][]string
+	var lines []int
+	switch format {
+	case ImportFormatCSV:
+		cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			line, _ := cr.FieldPos(0)
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+	case ImportFormatXLSX:
+		sheet, err := xlsx.ReadRows(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		for _, row := range sheet {
+			records = append(records, row.Cells)
+			lines = append(lines, row.Number)
+		}
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidImport, format)
+	}
+
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, name)
+		}
+	}
+
+	var rows []ImportRow
+	var rowErrors []ImportRowError
+	seen := make(map[string]int)
+	for i, record := range records[1:] {
+		field := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := ImportRow{Line: lines[i+1], Name: field("name"), Size: field("size")}
+		rowError := func(message string) {
+			rowErrors = append(rowErrors, ImportRowError{Line: row.Line, Code: field("code"), Message: message})
+		}
+
+		code, _, err := validateCode(field("code"))
+		if err != nil {
+			rowError(err.Error())
+			continue
+		}
+		row.Code = code
+
+		if row.Name == "" {
+			rowError("name is required")
+			continue
+		}
+		if row.Quantity, err = strconv.Atoi(field("quantity")); err != nil || row.Quantity < 0 {
+			rowError("quantity must be a non-negative integer")
+			continue
+		}
+		if row.WarehouseID, err = strconv.Atoi(field("warehouse_id")); err != nil {
+			rowError("warehouse_id must be an integer")
+			continue
+		}
+		if line, ok := seen[row.Code]; ok {
+			rowError(fmt.Sprintf("duplicate code, first seen on line %d", line))
+			continue
+		}
+		seen[row.Code] = row.Line
+
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+//	@Summary		Import products.
+//	@Description	Bulk create or update products by code from a CSV or XLSX file with the header name, size, code, quantity, warehouse_id.
+//	@Description	Every row is validated before anything is written; dry_run only returns the report.
+//	@Description	With chunk_size the rows are written in separate transactions and a failed import can be resumed from last_line + 1 via from_line.
+//	@Tags			products
+//	@Accept			text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,multipart/form-data
+//	@Produce		json
+//	@Param			file		formData	file			false	"CSV or XLSX file (or the raw request body)"
+//	@Param			format		query		string			false	"File format: csv or xlsx"
+//	@Param			dry_run		query		bool			false	"Validate only"
+//	@Param			chunk_size	query		int				false	"Rows per transaction"
+//	@Param			from_line	query		int				false	"First file line to import"
+//	@Success		200			{object}	ImportReport
+//	@Failure		400			{object}	ImportReport	"Rows with errors"
+//	@Failure		409			{object}	ImportReport	"Warehouse capacity exceeded"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/products/import [post]
+//
+func ImportProducts(db *sql.DB, r io.Reader, format string, opts ImportOptions) (*ImportReport, error) {
+	rows, rowErrors, err := readImportFile(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.FromLine > 0 {
+		rows = rows[sort.Search(len(rows), func(i int) bool { return rows[i].Line >= opts.FromLine }):]
+		rowErrors = rowErrors[sort.Search(len(rowErrors), func(i int) bool { return rowErrors[i].Line >= opts.FromLine }):]
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Rows: len(rows) + len(rowErrors), Errors: rowErrors}
+	if len(rows) == 0 {
+		return report, nil
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 || chunkSize > len(rows) {
+		chunkSize = len(rows)
+	}
+
+	// при ошибках в файле, пробном запуске или записи частями все строки проверяются до записи
+	if opts.DryRun || len(rowErrors) > 0 || chunkSize < len(rows) {
+		if err := importChunk(db, rows, true, report); err != nil {
+			return report, err
+		}
+		sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+		if opts.DryRun || len(report.Errors) > 0 {
+			return report, nil
+		}
+		report.Created, report.Updated = 0, 0
+	}
+
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := importChunk(db, rows[start:end], false, report); err != nil || len(report.Errors) > 0 {
+			return report, err
+		}
+		report.LastLine = rows[end-1].Line
+	}
+
+	return report, nil
+}
+
+// importChunk загружает строки во временную таблицу командой COPY, проверяет их
+// по данным базы и, если ошибок нет и это не пробный запуск, записывает в products
+func importChunk(db *sql.DB, rows []ImportRow, dryRun bool, report *ImportReport) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := stageImportRows(tx, rows); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rowErrors, err := checkImportRows(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(rowErrors) > 0 {
+		report.Errors = append(report.Errors, rowErrors...)
+		return tx.Rollback()
+	}
+
+	var created, updated int
+	err = tx.QueryRow(`SELECT COUNT(*) FILTER (WHERE p.id IS NULL), COUNT(p.id)
+		FROM import_products i LEFT JOIN products p ON p.code = i.code`).Scan(&created, &updated)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	report.Created += created
+	report.Updated += updated
+
+	if dryRun {
+		return tx.Rollback()
+	}
+
+	reference := fmt.Sprintf("import:%d-%d", rows[0].Line, rows[len(rows)-1].Line)
+	if err := writeImportRows(tx, reference); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func stageImportRows(tx *sql.Tx, rows []ImportRow) error {
+	_, err := tx.Exec(`CREATE TEMP TABLE import_products (
+		line INTEGER, name TEXT, size TEXT, code TEXT, quantity INTEGER, warehouse_id INTEGER
+	) ON COMMIT DROP`)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("import_products", "line", "name", "size", "code", "quantity", "warehouse_id"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range rows {
+		if _, err := stmt.Exec(r.Line, r.Name, r.Size, r.Code, r.Quantity, r.WarehouseID); err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.Exec()
+	return err
+}
+
+// checkImportRows проверяет загруженные строки по данным базы: склад должен существовать,
+// код не может принадлежать другому складу, набору или быть дополнительным штрихкодом,
+// а остаток товаров с серийным или партионным учетом и замороженных инвентаризацией не меняется.
+func checkImportRows(tx *sql.Tx) ([]ImportRowError, error) {
+	rows, err := tx.Query(`SELECT line, code, message FROM (
+			SELECT i.line, i.code, CASE
+				WHEN w.id IS NULL THEN 'warehouse does not exist'
+				WHEN p.id IS NULL AND b.barcode IS NOT NULL THEN 'code is already used as a barcode'
+				WHEN p.warehouse_id <> i.warehouse_id THEN 'product belongs to another warehouse'
+				WHEN p.is_bundle THEN 'bundle products cannot be imported'
+				WHEN p.quantity <> i.quantity AND (p.serial_tracked OR EXISTS (
+					SELECT 1 FROM stock_lots l WHERE l.product_id = p.id
+				)) THEN 'quantity of serial or lot tracked products is changed by receipts'
+				WHEN p.quantity <> i.quantity AND EXISTS (
+					SELECT 1 FROM cycle_count_lines cl JOIN cycle_counts c ON c.id = cl.cycle_count_id
+					WHERE cl.product_id = p.id AND c.status = $1 AND c.freeze_reservations
+				) THEN $2
+			END AS message
+			FROM import_products i
+			LEFT JOIN warehouse w ON w.id = i.warehouse_id
+			LEFT JOIN products p ON p.code = i.code
+			LEFT JOIN product_barcodes b ON b.barcode = i.code
+		) checked
+		WHERE message IS NOT NULL ORDER BY line`, CycleCountOpen, ErrProductFrozen.Error())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rowErrors []ImportRowError
+	for rows.Next() {
+		var e ImportRowError
+		if err := rows.Scan(&e.Line, &e.Code, &e.Message); err != nil {
+			return nil, err
+		}
+		rowErrors = append(rowErrors, e)
+	}
+
+	return rowErrors, rows.Err()
+}
+
+// stockChangedPayload строит в SQL тело события stock.changed с теми же полями, что и StockChangedEvent
+const stockChangedPayload = `jsonb_build_object('product_id', %[1]s.id, 'code', %[1]s.code, 'warehouse_id', %[1]s.warehouse_id,
+	'delta', %[2]s, 'quantity', %[3]s, 'reason', $1::TEXT, 'reference', $2::TEXT)`
+
+// writeImportRows обновляет существующие товары и создает новые, записывая движения
+// на разницу остатка и события об изменении остатка в outbox, проверяет вместимость складов,
+// остаток которых вырос, пересчитывает оповещения об остатках и отдает прибывший остаток листу ожидания
+func writeImportRows(tx *sql.Tx, reference string) error {
+	// склады блокируются до товаров, как при проведении поставки
+	warehouseIDs, err := queryIDs(tx, `SELECT DISTINCT COALESCE(p.warehouse_id, i.warehouse_id)
+		FROM import_products i LEFT JOIN products p ON p.code = i.code ORDER BY 1`)
+	if err != nil {
+		return err
+	}
+	capacities := make(map[int]*WarehouseCapacity, len(warehouseIDs))
+	for _, id := range warehouseIDs {
+		if capacities[id], err = warehouseCapacity(tx, id, true); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`SELECT p.id FROM products p JOIN import_products i ON i.code = p.code ORDER BY p.id FOR UPDATE OF p`)
+	if err != nil {
+		return err
+	}
+
+	grown, err := queryIDs(tx, `SELECT DISTINCT COALESCE(p.warehouse_id, i.warehouse_id)
+		FROM import_products i LEFT JOIN products p ON p.code = i.code
+		WHERE i.quantity > COALESCE(p.quantity, 0) ORDER BY 1`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`WITH changed AS (
+			SELECT p.id, p.code, p.warehouse_id, i.quantity - p.quantity AS delta, i.quantity
+			FROM products p JOIN import_products i ON i.code = p.code
+			WHERE i.quantity <> p.quantity
+		), movements AS (
+			INSERT INTO stock_movements(product_id, quantity_delta, reason, reference)
+			SELECT id, delta, $1, $2 FROM changed
+		)
+		INSERT INTO outbox_events(event_type, aggregate_key, payload)
+		SELECT $3, 'product:' || c.id, `+fmt.Sprintf(stockChangedPayload, "c", "c.delta", "c.quantity")+`
+		FROM changed c ORDER BY c.id`, MovementImport, reference, EventStockChanged)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE products p SET name = i.name, size = i.size, quantity = i.quantity
+		FROM import_products i WHERE p.code = i.code`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`WITH created AS (
+			INSERT INTO products(name, size, code, quantity, warehouse_id)
+			SELECT i.name, i.size, i.code, i.quantity, i.warehouse_id FROM import_products i
+			WHERE NOT EXISTS (SELECT 1 FROM products p WHERE p.code = i.code)
+			RETURNING id, code, warehouse_id, quantity
+		), movements AS (
+			INSERT INTO stock_movements(product_id, quantity_delta, reason, reference)
+			SELECT id, quantity, $1, $2 FROM created WHERE quantity <> 0
+		)
+		INSERT INTO outbox_events(event_type, aggregate_key, payload)
+		SELECT $3, 'product:' || c.id, `+fmt.Sprintf(stockChangedPayload, "c", "c.quantity", "c.quantity")+`
+		FROM created c WHERE c.quantity <> 0 ORDER BY c.id`, MovementImport, reference, EventStockChanged)
+	if err != nil {
+		return duplicateCodeError(err)
+	}
+
+	for _, id := range grown {
+		if err := checkStockCapacity(tx, id, capacities[id], reference); err != nil {
+			return err
+		}
+	}
+
+	productIDs, err := queryIDs(tx, "SELECT p.id FROM products p JOIN import_products i ON i.code = p.code ORDER BY p.id")
+	if err != nil {
+		return err
+	}
+
+	// импорт задает остаток целиком, поэтому ячейки, в которых теперь лежит больше, подрезаются
+	for _, id := range productIDs {
+		if err := syncBinStock(tx, id, 0, MovementImport); err != nil {
+			return err
+		}
+	}
+
+	if err := evaluateStockAlerts(tx, productIDs); err != nil {
+		return err
+	}
+
+	return fulfillBackorders(tx, productIDs)
+}
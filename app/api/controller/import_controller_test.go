@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestReadImportFileReportsRowErrors(t *testing.T) {
+	file := "code,name,size,quantity,warehouse_id\n" +
+		"sku-001,Sneakers,42,5,1\n" +
+		",No code,M,1,1\n" +
+		"SKU-002,T-shirt,M,-1,1\n" +
+		"\n" +
+		"SKU-001,Duplicate,43,1,1\n"
+
+	rows, rowErrors, err := readImportFile(strings.NewReader(file), ImportFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Code != "SKU-001" || rows[0].Line != 2 {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+	if len(rowErrors) != 3 || rowErrors[2].Line != 6 {
+		t.Errorf("Unexpected row errors %+v", rowErrors)
+	}
+
+	if _, _, err := readImportFile(strings.NewReader("code,name\n"), ImportFormatCSV); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("Expected ErrInvalidImport for missing columns, but got %v", err)
+	}
+}
+
+func TestImportProductsUpsertsByCode(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	existing := createTestProduct(t, db, w.ID, 3)
+	newCode := strings.ToUpper(utils.RandomString(10))
+
+	file := fmt.Sprintf("name,size,code,quantity,warehouse_id\nRenamed,L,%s,7,%d\nNew,M,%s,2,%d\n",
+		existing.Code, w.ID, newCode, w.ID)
+
+	report, err := ImportProducts(db, strings.NewReader(file), ImportFormatCSV, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 1 || len(report.Errors) != 0 {
+		t.Errorf("Unexpected dry-run report %+v", report)
+	}
+	if q := productQuantity(t, db, existing.ID); q != 3 {
+		t.Errorf("Expected dry run not to change stock, got %d", q)
+	}
+
+	report, err = ImportProducts(db, strings.NewReader(file), ImportFormatCSV, ImportOptions{ChunkSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 1 || report.LastLine != 3 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if q := productQuantity(t, db, existing.ID); q != 7 {
+		t.Errorf("Expected product quantity to be 7, but got %d", q)
+	}
+
+	p, err := GetProductByCode(db, newCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quantity != 2 || p.WarehouseID != w.ID {
+		t.Errorf("Unexpected imported product %+v", p)
+	}
+}
+
+func TestImportProductsRejectsUnknownWarehouse(t *testing.T) {
+	db := openTestDB(t)
+	code := strings.ToUpper(utils.RandomString(10))
+
+	file := fmt.Sprintf("name,size,code,quantity,warehouse_id\nGhost,M,%s,1,-1\n", code)
+	report, err := ImportProducts(db, strings.NewReader(file), ImportFormatCSV, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != 2 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	if _, err := GetProductByCode(db, code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected product not to be created, but got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/label"
+	"github.com/lib/pq"
+)
+
+// maxLabels ограничивает количество этикеток в одном пакете печати
+const maxLabels = 1000
+
+//	@Summary		Product labels.
+//	@Description	Render labels with the product name, size and a barcode of its code: EAN-13 for valid EAN codes, Code 128 otherwise.
+//	@Description	Format is pdf (default), png or zpl.
+//	@Tags			labels
+//	@Produce		application/pdf,image/png,text/plain
+//	@Param			code	path		string			true	"Product code or barcode"
+//	@Param			copies	query		int				false	"Number of copies"
+//	@Param			format	query		string			false	"Label format: pdf, png or zpl"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	ErrorResponse	"Invalid format or number of copies"
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Router			/labels/products/{code} [get]
+//
+func GetProductLabels(db *sql.DB, code string, copies int) ([]label.Label, error) {
+	if copies <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if copies > maxLabels {
+		return nil, ErrTooManyLabels
+	}
+
+	p, err := GetProductByCode(db, code)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]label.Label, copies)
+	for i := range labels {
+		labels[i] = productLabel(p.Name, p.Size, p.Code, "")
+	}
+
+	return labels, nil
+}
+
+//	@Summary		Bin label.
+//	@Description	Render a label for a warehouse location with a Code 128 barcode of its full path.
+//	@Tags			labels
+//	@Produce		application/pdf,image/png,text/plain
+//	@Param			id		path		int				true	"Location ID"
+//	@Param			format	query		string			false	"Label format: pdf, png or zpl"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	ErrorResponse	"Invalid format"
+//	@Failure		404		{object}	ErrorResponse	"Location not found"
+//	@Router			/labels/bins/{id} [get]
+//
+func GetBinLabel(db *sql.DB, locationID int) ([]label.Label, error) {
+	var l Location
+	err := db.QueryRow(locationPathsQuery+`
+		SELECT l.warehouse_id, l.kind, tree.path FROM locations l JOIN tree ON tree.id = l.id
+		WHERE l.id = $1`, locationID,
+	).Scan(&l.WarehouseID, &l.Kind, &l.Path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []label.Label{{
+		Title:    l.Path,
+		Subtitle: fmt.Sprintf("Warehouse %d, %s", l.WarehouseID, l.Kind),
+		Code:     l.Path,
+	}}, nil
+}
+
+//	@Summary		Receipt labels.
+//	@Description	Render product labels for every unit of an inbound receipt. Serial-tracked units get the serial number on their label.
+//	@Tags			labels
+//	@Produce		application/pdf,image/png,text/plain
+//	@Param			id		path		int				true	"Receipt ID"
+//	@Param			format	query		string			false	"Label format: pdf, png or zpl"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	ErrorResponse	"Invalid format or too many labels"
+//	@Failure		404		{object}	ErrorResponse	"Receipt not found"
+//	@Router			/labels/receipts/{id} [get]
+//
+func GetReceiptLabels(db *sql.DB, receiptID int) ([]label.Label, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM receipts WHERE id = $1)", receiptID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := db.Query(`SELECT p.name, p.size, p.code, l.quantity, l.serials
+		FROM receipt_lines l JOIN products p ON p.id = l.product_id
+		WHERE l.receipt_id = $1 ORDER BY l.id`, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []label.Label
+	for rows.Next() {
+		var name, size, code string
+		var quantity int
+		var serials []string
+		if err := rows.Scan(&name, &size, &code, &quantity, pq.Array(&serials)); err != nil {
+			return nil, err
+		}
+		if len(labels)+quantity > maxLabels {
+			return nil, ErrTooManyLabels
+		}
+
+		for i := 0; i < quantity; i++ {
+			var serial string
+			if i < len(serials) {
+				serial = serials[i]
+			}
+			labels = append(labels, productLabel(name, size, code, serial))
+		}
+	}
+
+	return labels, rows.Err()
+}
+
+func productLabel(name, size, code, serial string) label.Label {
+	subtitle := []string{"Size " + size}
+	if serial != "" {
+		subtitle = append(subtitle, "S/N "+serial)
+	}
+
+	return label.Label{Title: name, Subtitle: strings.Join(subtitle, ", "), Code: code}
+}
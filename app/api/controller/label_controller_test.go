@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestGetProductLabels(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	labels, err := GetProductLabels(db, p.Code, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels[0].Code != p.Code || labels[0].Title != p.Name {
+		t.Errorf("Unexpected labels %+v", labels)
+	}
+
+	if _, err := GetProductLabels(db, p.Code, maxLabels+1); !errors.Is(err, ErrTooManyLabels) {
+		t.Errorf("Expected ErrTooManyLabels, but got %v", err)
+	}
+}
+
+func TestGetReceiptLabelsPrintsEveryUnit(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	s := &Product{Name: utils.RandomString(6), Size: "M", Code: utils.RandomString(10), WarehouseID: w.ID, SerialTracked: true}
+	if err := CreateProduct(db, s); err != nil {
+		t.Fatal(err)
+	}
+	serial := utils.RandomString(12)
+
+	r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{
+		{Code: p.Code, Quantity: 2},
+		{Code: s.Code, Quantity: 1, Serials: []string{serial}},
+	}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+
+	labels, err := GetReceiptLabels(db, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 {
+		t.Fatalf("Expected 3 labels, got %d", len(labels))
+	}
+	if !strings.Contains(labels[2].Subtitle, serial) {
+		t.Errorf("Expected serial on the label, got %q", labels[2].Subtitle)
+	}
+
+	if _, err := GetReceiptLabels(db, -1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, but got %v", err)
+	}
+}
@@ -0,0 +1,562 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+const (
+	LocationZone  = "zone"
+	LocationAisle = "aisle"
+	LocationBin   = "bin"
+)
+
+// parentKinds задает допустимый тип родителя для каждого уровня иерархии
+var parentKinds = map[string]string{
+	LocationZone:  "",
+	LocationAisle: LocationZone,
+	LocationBin:   LocationAisle,
+}
+
+type Location struct {
+	ID          int    `json:"id"`
+	WarehouseID int    `json:"warehouse_id"`
+	ParentID    *int   `json:"parent_id,omitempty"`
+	Kind        string `json:"kind"`
+	Code        string `json:"code"`
+	Path        string `json:"path,omitempty"`
+}
+
+// BinBalance показывает количество товара в конкретной ячейке. Quantity — свободные единицы,
+// Reserved — зарезервированные, но еще не отобранные.
+type BinBalance struct {
+	LocationID int    `json:"location_id"`
+	Path       string `json:"path"`
+	Quantity   int    `json:"quantity"`
+	Reserved   int    `json:"reserved"`
+}
+
+type BinStockRequest struct {
+	Code         string `json:"code"`
+	Quantity     int    `json:"quantity"`
+	ToLocationID int    `json:"to_location_id,omitempty"`
+}
+
+type PickListLine struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Size        string `json:"size"`
+	WarehouseID int    `json:"warehouse_id"`
+	LocationID  *int   `json:"location_id,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Quantity    int    `json:"quantity"`
+}
+
+// locationPathsQuery строит полный путь ячейки вида "A/01/03" для всех локаций склада
+const locationPathsQuery = `WITH RECURSIVE tree AS (
+	SELECT id, code::TEXT AS path FROM locations WHERE parent_id IS NULL
+	UNION ALL
+	SELECT l.id, tree.path || '/' || l.code FROM locations l JOIN tree ON l.parent_id = tree.id
+)`
+
+//	@Summary		Create a location.
+//	@Description	Create a zone, aisle or bin inside a warehouse. Aisles belong to zones and bins belong to aisles.
+//	@Tags			locations
+//	@Accept			json
+//	@Produce		json
+//	@Param			location	body		Location		true	"Location information"
+//	@Success		201			{object}	Location
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/locations [post]
+//
+func CreateLocation(db *sql.DB, l *Location) error {
+	wantParent, ok := parentKinds[l.Kind]
+	if !ok || l.Code == "" {
+		return ErrInvalidLocation
+	}
+
+	if wantParent == "" {
+		if l.ParentID != nil {
+			return ErrInvalidLocation
+		}
+	} else {
+		if l.ParentID == nil {
+			return ErrInvalidLocation
+		}
+
+		var parentKind string
+		var parentWarehouseID int
+		err := db.QueryRow("SELECT kind, warehouse_id FROM locations WHERE id = $1", *l.ParentID).Scan(&parentKind, &parentWarehouseID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidLocation
+		}
+		if err != nil {
+			return err
+		}
+		if parentKind != wantParent || parentWarehouseID != l.WarehouseID {
+			return ErrInvalidLocation
+		}
+	}
+
+	return db.QueryRow(
+		"INSERT INTO locations(warehouse_id, parent_id, kind, code) VALUES($1, $2, $3, $4) RETURNING id",
+		l.WarehouseID, l.ParentID, l.Kind, l.Code,
+	).Scan(&l.ID)
+}
+
+//	@Summary		List warehouse locations.
+//	@Description	List all zones, aisles and bins of a warehouse with their full paths.
+//	@Tags			locations
+//	@Produce		json
+//	@Param			id	path		int				true	"Warehouse ID"
+//	@Success		200	{array}		Location
+//	@Failure		400	{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/locations [get]
+//
+func GetLocations(db *sql.DB, warehouseID int) ([]Location, error) {
+	rows, err := db.Query(locationPathsQuery+`
+		SELECT l.id, l.warehouse_id, l.parent_id, l.kind, l.code, tree.path
+		FROM locations l JOIN tree ON tree.id = l.id
+		WHERE l.warehouse_id = $1 ORDER BY tree.path`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []Location
+	for rows.Next() {
+		var l Location
+		if err := rows.Scan(&l.ID, &l.WarehouseID, &l.ParentID, &l.Kind, &l.Code, &l.Path); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+
+	return locations, rows.Err()
+}
+
+//	@Summary		Receive stock into a bin.
+//	@Description	Put received units of a product into a bin. The warehouse total of the product grows by the same quantity. Serial-tracked products are received only through receipts with serial numbers.
+//	@Tags			locations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Bin ID"
+//	@Param			stock	body		BinStockRequest	true	"Product code and quantity"
+//	@Success		204		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Failure		409		{object}	ErrorResponse	"Warehouse capacity exceeded"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/locations/{id}/receive [post]
+//
+func ReceiveToBin(db *sql.DB, locationID int, code string, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	warehouseID, capacity, err := lockProductCapacity(tx, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	productID, err := lockProductInBin(tx, locationID, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkDirectReceipt(tx, productID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := addBinStock(tx, locationID, productID, quantity); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE products SET quantity = quantity + $1 WHERE id = $2", quantity, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	reference := "location:" + strconv.Itoa(locationID)
+	if err := checkStockCapacity(tx, warehouseID, capacity, reference); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordMovement(tx, productID, quantity, MovementReceipt, reference); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Move stock between bins.
+//	@Description	Move units of a product from one bin to another bin of the same warehouse.
+//	@Tags			locations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Source bin ID"
+//	@Param			stock	body		BinStockRequest	true	"Product code, quantity and target bin"
+//	@Success		204		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		409		{object}	ErrorResponse	"Not enough stock in the source bin"
+//	@Router			/locations/{id}/move [post]
+//
+func MoveBinStock(db *sql.DB, fromLocationID, toLocationID int, code string, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	productID, err := lockProductInBin(tx, fromLocationID, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := lockProductInBin(tx, toLocationID, code); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := addBinStock(tx, fromLocationID, productID, -quantity); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := addBinStock(tx, toLocationID, productID, quantity); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Pick stock from a bin.
+//	@Description	Take reserved units of a product out of a bin when the order is picked. Only units reserved from the bin can be picked; picked units leave the warehouse.
+//	@Tags			locations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Bin ID"
+//	@Param			stock	body		BinStockRequest	true	"Product code and quantity"
+//	@Success		204		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		409		{object}	ErrorResponse	"Not enough reserved stock in the bin"
+//	@Router			/locations/{id}/pick [post]
+//
+func PickFromBin(db *sql.DB, locationID int, code string, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	productID, err := lockProductInBin(tx, locationID, code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := tx.Exec("UPDATE bin_stock SET reserved = reserved - $1 WHERE location_id = $2 AND product_id = $3 AND reserved >= $1",
+		quantity, locationID, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return ErrNegativeStock
+	}
+
+	_, err = tx.Exec("UPDATE products SET reserved = GREATEST(reserved - $1, 0) WHERE id = $2", quantity, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Build a pick list.
+//	@Description	Build a pick list for product codes with the bins to pick them from.
+//	@Description	A code repeated N times requests N units. Units reserved in bins are picked first, then free units in bins; units that are not placed in any bin are returned without a location.
+//	@Tags			locations
+//	@Accept			json
+//	@Produce		json
+//	@Param			productCodes	body		[]string		true	"Product codes"
+//	@Success		200				{array}		PickListLine
+//	@Failure		400				{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404				{object}	ErrorResponse	"Product not found"
+//	@Router			/pick-list [post]
+//
+func GetPickList(db *sql.DB, productCodes []string) ([]PickListLine, error) {
+	if len(productCodes) == 0 {
+		return nil, ErrEmptyProductCodes
+	}
+
+	var order []string
+	requested := make(map[string]int)
+	for _, code := range productCodes {
+		if requested[code] == 0 {
+			order = append(order, code)
+		}
+		requested[code]++
+	}
+
+	var lines []PickListLine
+	for _, code := range order {
+		var p Product
+		err := db.QueryRow("SELECT id, name, size, warehouse_id FROM products WHERE code = $1", code).Scan(&p.ID, &p.Name, &p.Size, &p.WarehouseID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		bins, err := getBinBalances(db, p.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// сначала отбираются зарезервированные единицы, затем свободные из тех же ячеек
+		remaining := requested[code]
+		picked := make(map[int]int)
+		for _, reserved := range []bool{true, false} {
+			for _, b := range bins {
+				if remaining == 0 {
+					break
+				}
+				take := b.Quantity
+				if reserved {
+					take = b.Reserved
+				}
+				if take > remaining {
+					take = remaining
+				}
+				if take == 0 {
+					continue
+				}
+				remaining -= take
+
+				if i, ok := picked[b.LocationID]; ok {
+					lines[i].Quantity += take
+					continue
+				}
+				picked[b.LocationID] = len(lines)
+				locationID := b.LocationID
+				lines = append(lines, PickListLine{
+					Code:        code,
+					Name:        p.Name,
+					Size:        p.Size,
+					WarehouseID: p.WarehouseID,
+					LocationID:  &locationID,
+					Path:        b.Path,
+					Quantity:    take,
+				})
+			}
+		}
+
+		if remaining > 0 {
+			lines = append(lines, PickListLine{
+				Code:        code,
+				Name:        p.Name,
+				Size:        p.Size,
+				WarehouseID: p.WarehouseID,
+				Quantity:    remaining,
+			})
+		}
+	}
+
+	return lines, nil
+}
+
+// getBinBalances возвращает ненулевые остатки товара по ячейкам в порядке путей
+func getBinBalances(q queryer, productID int) ([]BinBalance, error) {
+	rows, err := q.Query(locationPathsQuery+`
+		SELECT s.location_id, tree.path, s.quantity, s.reserved
+		FROM bin_stock s JOIN tree ON tree.id = s.location_id
+		WHERE s.product_id = $1 AND (s.quantity > 0 OR s.reserved > 0) ORDER BY tree.path`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []BinBalance
+	for rows.Next() {
+		var b BinBalance
+		if err := rows.Scan(&b.LocationID, &b.Path, &b.Quantity, &b.Reserved); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
+
+// getWarehouseBinBalances возвращает остатки по ячейкам для всех товаров склада
+func getWarehouseBinBalances(q queryer, warehouseID int) (map[int][]BinBalance, error) {
+	rows, err := q.Query(locationPathsQuery+`
+		SELECT s.product_id, s.location_id, tree.path, s.quantity, s.reserved
+		FROM bin_stock s JOIN tree ON tree.id = s.location_id JOIN locations l ON l.id = s.location_id
+		WHERE l.warehouse_id = $1 AND (s.quantity > 0 OR s.reserved > 0) ORDER BY tree.path`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[int][]BinBalance)
+	for rows.Next() {
+		var productID int
+		var b BinBalance
+		if err := rows.Scan(&productID, &b.LocationID, &b.Path, &b.Quantity, &b.Reserved); err != nil {
+			return nil, err
+		}
+		balances[productID] = append(balances[productID], b)
+	}
+
+	return balances, rows.Err()
+}
+
+// lockProductInBin блокирует товар по коду и проверяет, что ячейка принадлежит складу товара
+func lockProductInBin(tx *sql.Tx, locationID int, code string) (int, error) {
+	var productID, warehouseID int
+	err := tx.QueryRow("SELECT id, warehouse_id FROM products WHERE code = $1 FOR UPDATE", code).Scan(&productID, &warehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var kind string
+	var locationWarehouseID int
+	err = tx.QueryRow("SELECT kind, warehouse_id FROM locations WHERE id = $1", locationID).Scan(&kind, &locationWarehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidLocation
+	}
+	if err != nil {
+		return 0, err
+	}
+	if kind != LocationBin || locationWarehouseID != warehouseID {
+		return 0, ErrInvalidLocation
+	}
+
+	return productID, nil
+}
+
+// addBinStock изменяет остаток товара в ячейке, не допуская отрицательных значений
+func addBinStock(tx *sql.Tx, locationID, productID, delta int) error {
+	if delta >= 0 {
+		_, err := tx.Exec(`INSERT INTO bin_stock(location_id, product_id, quantity) VALUES($1, $2, $3)
+			ON CONFLICT (location_id, product_id) DO UPDATE SET quantity = bin_stock.quantity + $3`,
+			locationID, productID, delta)
+		return err
+	}
+
+	res, err := tx.Exec("UPDATE bin_stock SET quantity = quantity + $1 WHERE location_id = $2 AND product_id = $3 AND quantity >= $4",
+		delta, locationID, productID, -delta)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNegativeStock
+	}
+
+	return nil
+}
+
+// shipBinUnit забирает из ячейки зарезервированную единицу товара. Резерв переводит в
+// зарезервированные единицы ячеек по порядку путей, поэтому он мог прийтись на другую ячейку:
+// тогда из этой ячейки уходит свободная единица, а единица резерва другой ячейки становится свободной.
+func shipBinUnit(tx *sql.Tx, locationID, productID int) error {
+	res, err := tx.Exec("UPDATE bin_stock SET reserved = reserved - 1 WHERE location_id = $1 AND product_id = $2 AND reserved >= 1",
+		locationID, productID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	if err := addBinStock(tx, locationID, productID, -1); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE bin_stock SET quantity = quantity + 1, reserved = reserved - 1
+		WHERE (location_id, product_id) = (SELECT location_id, product_id FROM bin_stock
+			WHERE product_id = $1 AND reserved >= 1 ORDER BY location_id LIMIT 1)`, productID)
+	return err
+}
+
+// syncBinStock приводит остатки ячеек к остатку товара после движения. Свободных единиц в ячейках
+// не может быть больше свободного остатка товара: резерв переводит недостающие единицы ячеек
+// в зарезервированные, возврат резерва переводит их обратно, а прочие списания убирают лишние
+// единицы из ячеек по порядку путей. Поступления без ячейки остаются неразмещенными.
+func syncBinStock(q queryer, productID, delta int, reason string) error {
+	bins, err := getBinBalances(q, productID)
+	if err != nil || len(bins) == 0 {
+		return err
+	}
+
+	if reason == MovementRelease {
+		back := delta
+		for _, b := range bins {
+			n := min(back, b.Reserved)
+			if n == 0 {
+				continue
+			}
+			_, err := q.Exec("UPDATE bin_stock SET quantity = quantity + $1, reserved = reserved - $1 WHERE location_id = $2 AND product_id = $3",
+				n, b.LocationID, productID)
+			if err != nil {
+				return err
+			}
+			back -= n
+		}
+		return nil
+	}
+
+	var available int
+	if err := q.QueryRow("SELECT quantity FROM products WHERE id = $1", productID).Scan(&available); err != nil {
+		return err
+	}
+	excess := -available
+	for _, b := range bins {
+		excess += b.Quantity
+	}
+
+	update := "UPDATE bin_stock SET quantity = quantity - $1 WHERE location_id = $2 AND product_id = $3"
+	if reason == MovementReserve {
+		update = "UPDATE bin_stock SET quantity = quantity - $1, reserved = reserved + $1 WHERE location_id = $2 AND product_id = $3"
+	}
+	for _, b := range bins {
+		n := min(excess, b.Quantity)
+		if n <= 0 {
+			continue
+		}
+		if _, err := q.Exec(update, n, b.LocationID, productID); err != nil {
+			return err
+		}
+		excess -= n
+	}
+
+	return nil
+}
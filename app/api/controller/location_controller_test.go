@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func createTestBin(t *testing.T, db *sql.DB, warehouseID int) *Location {
+	t.Helper()
+
+	zone := &Location{WarehouseID: warehouseID, Kind: LocationZone, Code: utils.RandomString(4)}
+	if err := CreateLocation(db, zone); err != nil {
+		t.Fatal(err)
+	}
+	aisle := &Location{WarehouseID: warehouseID, ParentID: &zone.ID, Kind: LocationAisle, Code: "01"}
+	if err := CreateLocation(db, aisle); err != nil {
+		t.Fatal(err)
+	}
+	bin := &Location{WarehouseID: warehouseID, ParentID: &aisle.ID, Kind: LocationBin, Code: utils.RandomString(4)}
+	if err := CreateLocation(db, bin); err != nil {
+		t.Fatal(err)
+	}
+
+	return bin
+}
+
+func TestCreateLocationRejectsWrongParent(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	zone := &Location{WarehouseID: w.ID, Kind: LocationZone, Code: "A"}
+	if err := CreateLocation(db, zone); err != nil {
+		t.Fatal(err)
+	}
+
+	bin := &Location{WarehouseID: w.ID, ParentID: &zone.ID, Kind: LocationBin, Code: "01"}
+	if err := CreateLocation(db, bin); !errors.Is(err, ErrInvalidLocation) {
+		t.Errorf("Expected ErrInvalidLocation for bin under zone, but got %v", err)
+	}
+}
+
+func TestReceiveToBinRollsUpToRemainingProducts(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	bin := createTestBin(t, db, w.ID)
+
+	if err := ReceiveToBin(db, bin.ID, p.Code, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	products, err := GetRemainingProducts(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Quantity != 4 {
+		t.Fatalf("Expected one product with quantity 4, got %+v", products)
+	}
+	if len(products[0].Bins) != 1 || products[0].Bins[0].LocationID != bin.ID || products[0].Bins[0].Quantity != 4 {
+		t.Errorf("Expected 4 units in bin %d, got %+v", bin.ID, products[0].Bins)
+	}
+}
+
+func TestGetPickListIncludesBins(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	bin := createTestBin(t, db, w.ID)
+
+	if err := ReceiveToBin(db, bin.ID, p.Code, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := GetPickList(db, []string{p.Code, p.Code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 pick list lines, got %+v", lines)
+	}
+	if lines[0].LocationID == nil || *lines[0].LocationID != bin.ID || lines[0].Quantity != 1 {
+		t.Errorf("Expected first line to pick 1 unit from bin %d, got %+v", bin.ID, lines[0])
+	}
+	if lines[1].LocationID != nil || lines[1].Quantity != 1 {
+		t.Errorf("Expected second line without location, got %+v", lines[1])
+	}
+
+	if err := PickFromBin(db, bin.ID, p.Code, 2); !errors.Is(err, ErrNegativeStock) {
+		t.Errorf("Expected ErrNegativeStock when picking more than the bin holds, but got %v", err)
+	}
+}
+
+func TestBinStockFollowsMovements(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	bin := createTestBin(t, db, w.ID)
+
+	if err := ReceiveToBin(db, bin.ID, p.Code, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveProducts(db, []string{p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	bins, err := getBinBalances(db, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 1 || bins[0].Quantity != 2 || bins[0].Reserved != 1 {
+		t.Fatalf("Expected 2 free and 1 reserved unit in the bin, got %+v", bins)
+	}
+
+	if err := PickFromBin(db, bin.ID, p.Code, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := &CycleCount{WarehouseID: w.ID}
+	if err := CreateCycleCount(db, cc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCycleCountEntries(db, cc.ID, []CycleCountEntry{{Code: p.Code, CountedQuantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApproveCycleCount(db, cc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// недостача по пересчету списывается и из ячейки
+	bins, err = getBinBalances(db, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 1 || bins[0].Quantity != 1 || bins[0].Reserved != 0 {
+		t.Errorf("Expected 1 free unit left in the bin, got %+v", bins)
+	}
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// expiredLotsSQL считает единицы товара p в просроченных партиях. Они лежат на складе,
+// но не доступны для резерва и не показываются в остатках.
+const expiredLotsSQL = `COALESCE((SELECT SUM(l.quantity) FROM stock_lots l WHERE l.product_id = p.id AND l.expiry_date < CURRENT_DATE), 0)`
+
+// availableSQL — остаток товара p без просроченных партий
+const availableSQL = `(p.quantity - ` + expiredLotsSQL + `)`
+
+// StockLot описывает партию товара с номером и сроком годности.
+// Quantity — доступное для резерва количество, Reserved — зарезервированное из партии.
+type StockLot struct {
+	ID         int    `json:"id"`
+	Code       string `json:"code"`
+	LotNumber  string `json:"lot_number"`
+	ExpiryDate string `json:"expiry_date,omitempty"`
+	Quantity   int    `json:"quantity"`
+	Reserved   int    `json:"reserved"`
+	Expired    bool   `json:"expired"`
+}
+
+//	@Summary		Receive a lot.
+//	@Description	Receive units of a product as a lot with an optional expiry date (YYYY-MM-DD).
+//	@Description	Receiving an existing lot number adds to its quantity; its expiry date may be omitted but must not differ.
+//	@Description	Serial-tracked products are received only through receipts with serial numbers.
+//	@Tags			lots
+//	@Accept			json
+//	@Produce		json
+//	@Param			lot	body		StockLot		true	"Lot information"
+//	@Success		201	{object}	StockLot
+//	@Failure		400	{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404	{object}	ErrorResponse	"Product not found"
+//	@Failure		409	{object}	ErrorResponse	"Lot is registered with another expiry date or warehouse capacity exceeded"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/lots [post]
+//
+func ReceiveLot(db *sql.DB, l *StockLot) error {
+	if l.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	warehouseID, capacity, err := lockProductCapacity(tx, l.Code)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var productID int
+	err = tx.QueryRow("SELECT id FROM products WHERE code = $1 FOR UPDATE", l.Code).Scan(&productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return ErrNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkDirectReceipt(tx, productID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	received := l.Quantity
+	if err := addLotStock(tx, productID, l, received); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE products SET quantity = quantity + $1 WHERE id = $2", received, productID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	reference := "lot:" + l.LotNumber
+	if err := checkStockCapacity(tx, warehouseID, capacity, reference); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordMovement(tx, productID, received, MovementReceipt, reference); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		List product lots.
+//	@Description	List lots of a product in first-expired-first-out order.
+//	@Tags			lots
+//	@Produce		json
+//	@Param			code	path		string			true	"Product code"
+//	@Success		200		{array}		StockLot
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/products/{code}/lots [get]
+//
+func GetProductLots(db *sql.DB, code string) ([]StockLot, error) {
+	return queryLots(db, `WHERE p.code = $1`, code)
+}
+
+//	@Summary		Lots expiring soon.
+//	@Description	List lots with stock that expire within the given number of days, including already expired ones.
+//	@Tags			lots
+//	@Produce		json
+//	@Param			days	query		int				true	"Number of days"
+//	@Success		200		{array}		StockLot
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/lots/expiring [get]
+//
+func GetExpiringLots(db *sql.DB, days int) ([]StockLot, error) {
+	return queryLots(db, `WHERE l.expiry_date <= CURRENT_DATE + $1::INTEGER AND l.quantity + l.reserved > 0`, days)
+}
+
+func queryLots(db *sql.DB, where string, args ...interface{}) ([]StockLot, error) {
+	rows, err := db.Query(`SELECT l.id, p.code, l.lot_number, l.expiry_date, l.quantity, l.reserved,
+			COALESCE(l.expiry_date < CURRENT_DATE, FALSE)
+		FROM stock_lots l JOIN products p ON p.id = l.product_id `+where+`
+		ORDER BY l.expiry_date NULLS LAST, l.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []StockLot
+	for rows.Next() {
+		var l StockLot
+		var expiry sql.NullTime
+		if err := rows.Scan(&l.ID, &l.Code, &l.LotNumber, &expiry, &l.Quantity, &l.Reserved, &l.Expired); err != nil {
+			return nil, err
+		}
+		l.ExpiryDate = formatDate(expiry)
+		lots = append(lots, l)
+	}
+
+	return lots, rows.Err()
+}
+
+// addLotStock добавляет количество в партию, создавая ее при необходимости, и заполняет l актуальными данными.
+// Существующую партию нельзя пополнить с другим сроком годности.
+func addLotStock(tx *sql.Tx, productID int, l *StockLot, quantity int) error {
+	if l.LotNumber == "" {
+		return ErrInvalidLot
+	}
+
+	var expiry *time.Time
+	if l.ExpiryDate != "" {
+		t, err := time.Parse(dateLayout, l.ExpiryDate)
+		if err != nil {
+			return ErrInvalidLot
+		}
+		expiry = &t
+	}
+
+	var storedExpiry sql.NullTime
+	err := tx.QueryRow(`INSERT INTO stock_lots(product_id, lot_number, expiry_date, quantity) VALUES($1, $2, $3, $4)
+		ON CONFLICT (product_id, lot_number) DO UPDATE SET quantity = stock_lots.quantity + EXCLUDED.quantity
+		WHERE EXCLUDED.expiry_date IS NULL OR EXCLUDED.expiry_date IS NOT DISTINCT FROM stock_lots.expiry_date
+		RETURNING id, expiry_date, quantity, reserved, COALESCE(expiry_date < CURRENT_DATE, FALSE)`,
+		productID, l.LotNumber, expiry, quantity,
+	).Scan(&l.ID, &storedExpiry, &l.Quantity, &l.Reserved, &l.Expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrLotExpiryMismatch
+	}
+	if err != nil {
+		return err
+	}
+	l.ExpiryDate = formatDate(storedExpiry)
+
+	return nil
+}
+
+// reserveFromLot резервирует одну единицу из партии с ближайшим сроком годности (FEFO).
+// Если годных партий не осталось, единица берется из количества вне партий; для товаров без партий
+// ничего не делает.
+func reserveFromLot(tx *sql.Tx, productID int) error {
+	res, err := tx.Exec(`UPDATE stock_lots SET quantity = quantity - 1, reserved = reserved + 1
+		WHERE id = (
+			SELECT id FROM stock_lots
+			WHERE product_id = $1 AND quantity > 0 AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)
+			ORDER BY expiry_date NULLS LAST, id
+			LIMIT 1 FOR UPDATE
+		)`, productID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	var untracked int
+	err = tx.QueryRow(`SELECT p.quantity - COALESCE((SELECT SUM(l.quantity) FROM stock_lots l WHERE l.product_id = p.id), 0)
+		FROM products p WHERE p.id = $1`, productID).Scan(&untracked)
+	if err != nil {
+		return err
+	}
+	if untracked < 1 {
+		return ErrOutOfStock
+	}
+
+	return nil
+}
+
+// releaseToLot возвращает одну зарезервированную единицу в партию с ближайшим сроком годности
+func releaseToLot(tx *sql.Tx, productID int) error {
+	_, err := tx.Exec(`UPDATE stock_lots SET quantity = quantity + 1, reserved = reserved - 1
+		WHERE id = (
+			SELECT id FROM stock_lots
+			WHERE product_id = $1 AND reserved > 0
+			ORDER BY expiry_date NULLS LAST, id
+			LIMIT 1 FOR UPDATE
+		)`, productID)
+	return err
+}
+
+func formatDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(dateLayout)
+}
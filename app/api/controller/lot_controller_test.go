@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReserveProductsUsesFirstExpiredLot(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	late := &StockLot{Code: p.Code, LotNumber: "L-LATE", ExpiryDate: time.Now().AddDate(0, 2, 0).Format(dateLayout), Quantity: 1}
+	early := &StockLot{Code: p.Code, LotNumber: "L-EARLY", ExpiryDate: time.Now().AddDate(0, 1, 0).Format(dateLayout), Quantity: 1}
+	for _, l := range []*StockLot{late, early} {
+		if err := ReceiveLot(db, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	lots, err := GetProductLots(db, p.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lots) != 2 || lots[0].LotNumber != "L-EARLY" || lots[0].Reserved != 1 || lots[1].Reserved != 0 {
+		t.Errorf("Expected the early lot to be reserved, got %+v", lots)
+	}
+}
+
+func TestReserveProductsSkipsExpiredLots(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	expired := &StockLot{Code: p.Code, LotNumber: "L-OLD", ExpiryDate: time.Now().AddDate(0, 0, -1).Format(dateLayout), Quantity: 3}
+	if err := ReceiveLot(db, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ReserveProducts(db, []string{p.Code})
+	if !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock for expired lot, but got %v", err)
+	}
+
+	products, err := GetRemainingProducts(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Quantity != 0 {
+		t.Errorf("Expected expired lot to be excluded from remaining stock, got %+v", products)
+	}
+
+	lots, err := GetExpiringLots(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, l := range lots {
+		if l.ID == expired.ID {
+			found = l.Expired
+		}
+	}
+	if !found {
+		t.Errorf("Expected expired lot %d in the expiring report", expired.ID)
+	}
+}
+
+func TestReserveProductsFallsBackToUntrackedStock(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+
+	lot := &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 1}
+	if err := ReceiveLot(db, lot); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{p.Code, p.Code}); err != nil {
+		t.Fatalf("Expected the unit outside lots to be reserved after the lot, got %v", err)
+	}
+	if err := ReserveProducts(db, []string{p.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock, but got %v", err)
+	}
+}
+
+func TestReceiveLotRejectsAnotherExpiry(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	expiry := time.Now().AddDate(0, 1, 0)
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", ExpiryDate: expiry.Format(dateLayout), Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &StockLot{Code: p.Code, LotNumber: "L-1", ExpiryDate: expiry.AddDate(0, 1, 0).Format(dateLayout), Quantity: 1}
+	if err := ReceiveLot(db, other); !errors.Is(err, ErrLotExpiryMismatch) {
+		t.Errorf("Expected ErrLotExpiryMismatch, but got %v", err)
+	}
+
+	same := &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 2}
+	if err := ReceiveLot(db, same); err != nil {
+		t.Fatal(err)
+	}
+	if same.Quantity != 3 || same.ExpiryDate != expiry.Format(dateLayout) {
+		t.Errorf("Expected the lot to keep its expiry and hold 3 units, got %+v", same)
+	}
+	if q := productQuantity(t, db, p.ID); q != 3 {
+		t.Errorf("Expected product quantity 3, got %d", q)
+	}
+}
@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/broker"
+	"github.com/lib/pq"
+)
+
+// Типы событий заказа, которые публикует оформление заказа
+const (
+	OrderCreated   = "order.created"
+	OrderConfirmed = "order.confirmed"
+	OrderCancelled = "order.cancelled"
+)
+
+const (
+	OrderStatusReserved  = "reserved"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusCancelled = "cancelled"
+)
+
+// EventOrderRejected публикуется в outbox, когда событие заказа не удалось применить
+const EventOrderRejected = "order.rejected"
+
+// orderConsumerName — имя потребителя в таблице обработанных сообщений
+const orderConsumerName = "orders"
+
+// orderMaxAttempts и orderRetryDelay задают повторы обработки при временных ошибках.
+// Задержка удваивается после каждой попытки.
+var (
+	orderMaxAttempts = 5
+	orderRetryDelay  = time.Second
+)
+
+// orderDedupRetention — сколько хранятся отметки об обработанных сообщениях. Срок должен быть
+// не меньше срока хранения сообщений в брокере, иначе повторная доставка применится второй раз.
+var orderDedupRetention = 7 * 24 * time.Hour
+
+// SetOrderRetryPolicy задает число попыток обработки события заказа и задержку перед первым повтором
+func SetOrderRetryPolicy(maxAttempts int, delay time.Duration) {
+	orderMaxAttempts = maxAttempts
+	orderRetryDelay = delay
+}
+
+// SetOrderDedupRetention задает срок хранения отметок об обработанных сообщениях
+func SetOrderDedupRetention(d time.Duration) {
+	orderDedupRetention = d
+}
+
+// PurgeConsumedMessages удаляет отметки об обработанных сообщениях, срок хранения которых истек
+func PurgeConsumedMessages(db *sql.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM consumed_messages WHERE consumed_at < now() - $1 * INTERVAL '1 second'",
+		orderDedupRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// OrderEvent — событие заказа из брокера. Items — коды товаров, по одному на единицу.
+// EventID служит ключом идемпотентности; если его нет, используется позиция сообщения в брокере.
+type OrderEvent struct {
+	EventID string   `json:"event_id"`
+	OrderID string   `json:"order_id"`
+	Type    string   `json:"type"`
+	Items   []string `json:"items"`
+}
+
+type Order struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Items     []string  `json:"items"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrderEventFailure — событие заказа, отложенное в dead-letter
+type OrderEventFailure struct {
+	ID        int64     `json:"id"`
+	MessageID string    `json:"message_id"`
+	OrderID   *string   `json:"order_id,omitempty"`
+	Payload   string    `json:"payload"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// ConsumeOrderBatch читает пачку событий заказов, применяет их и фиксирует смещения.
+// Временные ошибки повторяются с экспоненциальной задержкой; события, которые нельзя применить,
+// и события, исчерпавшие попытки, откладываются в dead-letter. Если событие не удалось ни применить,
+// ни отложить, возвращается ошибка, и его смещение остается незафиксированным.
+func ConsumeOrderBatch(ctx context.Context, db *sql.DB, consumer broker.Consumer) (int, error) {
+	batch, err := consumer.Fetch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, m := range batch {
+		if err := handleOrderMessage(ctx, db, m); err != nil {
+			return i, err
+		}
+		if err := consumer.Commit(ctx, m); err != nil {
+			return i, err
+		}
+	}
+
+	return len(batch), nil
+}
+
+func handleOrderMessage(ctx context.Context, db *sql.DB, m broker.Message) error {
+	delay := orderRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := ProcessOrderMessage(db, m)
+		if err == nil {
+			return nil
+		}
+		if isOrderRejection(err) || attempt >= orderMaxAttempts {
+			return recordOrderFailure(db, m, err, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isOrderRejection отличает события, которые не применятся и при повторе, от временных ошибок
+func isOrderRejection(err error) bool {
+	for _, rejection := range []error{
+		ErrInvalidOrderEvent, ErrNotFound, ErrOutOfStock, ErrInvalidStatus, ErrProductFrozen,
+		ErrEmptyProductCodes, ErrSerialUnavailable, ErrWarehouseClosed,
+	} {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseOrderEvent(m broker.Message) (*OrderEvent, string, error) {
+	var e OrderEvent
+	if err := json.Unmarshal(m.Value, &e); err != nil {
+		return nil, m.ID(), fmt.Errorf("%w: %v", ErrInvalidOrderEvent, err)
+	}
+
+	messageID := e.EventID
+	if messageID == "" {
+		messageID = m.ID()
+	}
+	if e.OrderID == "" {
+		return &e, messageID, fmt.Errorf("%w: missing order_id", ErrInvalidOrderEvent)
+	}
+
+	return &e, messageID, nil
+}
+
+// ProcessOrderMessage применяет событие заказа ровно один раз: отметка об обработке сообщения
+// пишется в той же транзакции, что и резерв, подтверждение или отмена
+func ProcessOrderMessage(db *sql.DB, m broker.Message) error {
+	e, messageID, err := parseOrderEvent(m)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO consumed_messages(consumer, message_id) VALUES($1, $2) ON CONFLICT DO NOTHING`,
+		orderConsumerName, messageID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if err := applyOrderEvent(tx, e); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func applyOrderEvent(tx *sql.Tx, e *OrderEvent) error {
+	var status string
+	var items []string
+	err := tx.QueryRow("SELECT status, items FROM orders WHERE id = $1 FOR UPDATE", e.OrderID).Scan(&status, pq.Array(&items))
+	if errors.Is(err, sql.ErrNoRows) {
+		status = ""
+	} else if err != nil {
+		return err
+	}
+	reference := "order:" + e.OrderID
+
+	switch e.Type {
+	case OrderCreated:
+		// повтор создания и создание после отмены, пришедшей раньше, ничего не меняют
+		if status != "" {
+			return nil
+		}
+		if len(e.Items) == 0 {
+			return ErrEmptyProductCodes
+		}
+		if err := reserveCodes(tx, e.Items, reference); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO orders(id, status, items) VALUES($1, $2, $3)", e.OrderID, OrderStatusReserved, pq.Array(e.Items))
+		return err
+
+	case OrderConfirmed:
+		switch status {
+		case OrderStatusConfirmed:
+			return nil
+		case "":
+			return ErrNotFound
+		case OrderStatusCancelled:
+			return ErrInvalidStatus
+		}
+		return setOrderStatus(tx, e.OrderID, OrderStatusConfirmed)
+
+	case OrderCancelled:
+		switch status {
+		case OrderStatusCancelled:
+			return nil
+		case OrderStatusConfirmed:
+			return ErrInvalidStatus
+		case "":
+			// отмена пришла раньше создания: запоминаем ее, чтобы не резервировать товар позже
+			if e.Items == nil {
+				e.Items = []string{}
+			}
+			_, err = tx.Exec("INSERT INTO orders(id, status, items) VALUES($1, $2, $3)", e.OrderID, OrderStatusCancelled, pq.Array(e.Items))
+			return err
+		}
+		if err := releaseCodes(tx, items, reference); err != nil {
+			return err
+		}
+		return setOrderStatus(tx, e.OrderID, OrderStatusCancelled)
+	}
+
+	return fmt.Errorf("%w: unknown type %q", ErrInvalidOrderEvent, e.Type)
+}
+
+func setOrderStatus(tx *sql.Tx, id, status string) error {
+	_, err := tx.Exec("UPDATE orders SET status = $2, updated_at = now() WHERE id = $1", id, status)
+	return err
+}
+
+// recordOrderFailure откладывает событие в dead-letter, отмечает его обработанным
+// и сообщает об отказе событием order.rejected
+func recordOrderFailure(db *sql.DB, m broker.Message, cause error, attempts int) error {
+	e, messageID, _ := parseOrderEvent(m)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO consumed_messages(consumer, message_id) VALUES($1, $2) ON CONFLICT DO NOTHING`,
+		orderConsumerName, messageID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	var orderID sql.NullString
+	if e != nil && e.OrderID != "" {
+		orderID = sql.NullString{String: e.OrderID, Valid: true}
+	}
+	_, err = tx.Exec(`INSERT INTO order_event_failures(message_id, order_id, payload, error, attempts) VALUES($1, $2, $3, $4, $5)`,
+		messageID, orderID, m.Value, cause.Error(), attempts)
+	if err != nil {
+		return err
+	}
+
+	if orderID.Valid {
+		err = enqueueEvent(tx, EventOrderRejected, "order:"+orderID.String, map[string]string{
+			"order_id": orderID.String,
+			"type":     e.Type,
+			"error":    cause.Error(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Get an order.
+//	@Description	Get the reservation state of an order received from the order event stream.
+//	@Tags			orders
+//	@Produce		json
+//	@Param			id	path		string			true	"Order ID"
+//	@Success		200	{object}	Order
+//	@Failure		404	{object}	ErrorResponse	"Order not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/orders/{id} [get]
+//
+func GetOrder(db *sql.DB, id string) (*Order, error) {
+	var o Order
+	err := db.QueryRow("SELECT id, status, items, created_at, updated_at FROM orders WHERE id = $1", id).
+		Scan(&o.ID, &o.Status, pq.Array(&o.Items), &o.CreatedAt, &o.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+//	@Summary		List failed order events.
+//	@Description	List order events that were rejected or ran out of retries, newest first.
+//	@Tags			orders
+//	@Produce		json
+//	@Param			limit	query		int				false	"Maximum number of events"	default(50)
+//	@Success		200		{array}		OrderEventFailure
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/order-event-failures [get]
+//
+func GetOrderEventFailures(db *sql.DB, limit int) ([]OrderEventFailure, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.Query(`SELECT id, message_id, order_id, payload, error, attempts, failed_at
+		FROM order_event_failures ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := []OrderEventFailure{}
+	for rows.Next() {
+		var f OrderEventFailure
+		var orderID sql.NullString
+		var payload []byte
+		if err := rows.Scan(&f.ID, &f.MessageID, &orderID, &payload, &f.Error, &f.Attempts, &f.FailedAt); err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			f.OrderID = &orderID.String
+		}
+		f.Payload = string(payload)
+		failures = append(failures, f)
+	}
+
+	return failures, rows.Err()
+}
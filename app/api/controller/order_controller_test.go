@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/broker"
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+// produceOrderEvent публикует событие заказа в топик. Тесты используют случайные топики, потому что
+// сообщения без event_id отмечаются обработанными по ID вида "топик:раздел:смещение".
+func produceOrderEvent(t *testing.T, b *broker.Memory, topic string, e OrderEvent) {
+	t.Helper()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Produce(topic, []byte(e.OrderID), data)
+}
+
+func TestConsumeOrderEvents(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 3)
+	orderID := utils.RandomString(12)
+
+	topic := "orders-" + utils.RandomString(12)
+	b := broker.NewMemory()
+	produceOrderEvent(t, b, topic, OrderEvent{EventID: orderID + "-1", OrderID: orderID, Type: OrderCreated, Items: []string{p.Code, p.Code}})
+	// повтор того же события не должен резервировать товар второй раз
+	produceOrderEvent(t, b, topic, OrderEvent{EventID: orderID + "-1", OrderID: orderID, Type: OrderCreated, Items: []string{p.Code, p.Code}})
+	produceOrderEvent(t, b, topic, OrderEvent{EventID: orderID + "-2", OrderID: orderID, Type: OrderCancelled})
+
+	consumer := b.Consumer(topic, "test", 10*time.Millisecond)
+	n, err := ConsumeOrderBatch(context.Background(), db, consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || b.Committed(topic, "test") != 3 {
+		t.Errorf("Expected 3 messages processed and committed, got %d", n)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 3 {
+		t.Errorf("Expected quantity 3 after reserve and cancel, got %d", q)
+	}
+	o, err := GetOrder(db, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != OrderStatusCancelled || len(o.Items) != 2 {
+		t.Errorf("Expected a cancelled order with 2 items, got %+v", o)
+	}
+}
+
+func TestConsumeOrderCancelledBeforeCreated(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 2)
+	orderID := utils.RandomString(12)
+
+	topic := "orders-" + utils.RandomString(12)
+	b := broker.NewMemory()
+	produceOrderEvent(t, b, topic, OrderEvent{EventID: orderID + "-2", OrderID: orderID, Type: OrderCancelled})
+	produceOrderEvent(t, b, topic, OrderEvent{EventID: orderID + "-1", OrderID: orderID, Type: OrderCreated, Items: []string{p.Code}})
+
+	if _, err := ConsumeOrderBatch(context.Background(), db, b.Consumer(topic, "test", 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 2 {
+		t.Errorf("Expected a creation after cancellation not to reserve, got quantity %d", q)
+	}
+	o, err := GetOrder(db, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != OrderStatusCancelled {
+		t.Errorf("Expected the order to stay cancelled, got %+v", o)
+	}
+}
+
+func TestConsumeOrderEventsDeadLettersRejections(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+	orderID := utils.RandomString(12)
+
+	topic := "orders-" + utils.RandomString(12)
+	b := broker.NewMemory()
+	b.Produce(topic, nil, []byte("not json"))
+	produceOrderEvent(t, b, topic, OrderEvent{OrderID: orderID, Type: OrderCreated, Items: []string{p.Code, p.Code}})
+
+	n, err := ConsumeOrderBatch(context.Background(), db, b.Consumer(topic, "test", 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected both messages to be committed, got %d", n)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 1 {
+		t.Errorf("Expected a rejected order to leave quantity 1, got %d", q)
+	}
+	if _, err := GetOrder(db, orderID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the rejected order not to be stored, got %v", err)
+	}
+
+	failures, err := GetOrderEventFailures(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range failures {
+		if f.OrderID != nil && *f.OrderID == orderID && f.Attempts == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the out-of-stock order event in the dead-letter list")
+	}
+}
@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+	"github.com/lib/pq"
+)
+
+const (
+	EventStockChanged     = "stock.changed"
+	EventWarehouseOnline  = "warehouse.online"
+	EventWarehouseOffline = "warehouse.offline"
+)
+
+// outboxLockID — ключ advisory-блокировки, под которой работает только одна пересылка outbox,
+// чтобы события одного товара не доставлялись параллельно и не в том порядке
+const outboxLockID = 38001
+
+// outboxRetryDelay — задержка перед повтором после первой неудачной доставки получателю;
+// далее она растет квадратично, но не больше outboxMaxRetryDelay. Пока ключ ждет повтора,
+// его события не занимают место в пачке и не задерживают другие ключи.
+var (
+	outboxRetryDelay    = time.Second
+	outboxMaxRetryDelay = 5 * time.Minute
+	outboxRetention     = 7 * 24 * time.Hour
+)
+
+// SetOutboxRetention задает, сколько хранятся опубликованные события outbox
+// (по ним клиенты потока остатков догоняют пропущенное)
+func SetOutboxRetention(d time.Duration) {
+	outboxRetention = d
+}
+
+// OutboxPublisher — получатель событий outbox с собственным прогрессом доставки.
+// Name сохраняется в базе, поэтому не должен меняться между перезапусками.
+type OutboxPublisher struct {
+	Name      string
+	Publisher events.Publisher
+}
+
+// StockChangedEvent — изменение остатка товара. Quantity — остаток после изменения.
+type StockChangedEvent struct {
+	ProductID   int    `json:"product_id"`
+	Code        string `json:"code"`
+	WarehouseID int    `json:"warehouse_id"`
+	Delta       int    `json:"delta"`
+	Quantity    int    `json:"quantity"`
+	Reason      string `json:"reason"`
+	Reference   string `json:"reference,omitempty"`
+}
+
+// WarehouseStatusEvent — склад стал доступен или недоступен. Reason — причина закрытия по календарю,
+// если склад переключил планировщик.
+type WarehouseStatusEvent struct {
+	WarehouseID int    `json:"warehouse_id"`
+	Name        string `json:"name"`
+	IsAvailable bool   `json:"is_available"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// productEventKey — ключ упорядочивания событий товара
+func productEventKey(productID int) string {
+	return "product:" + strconv.Itoa(productID)
+}
+
+// enqueueEvent записывает событие в outbox в той же транзакции, что и изменение данных
+func enqueueEvent(q queryer, eventType, key string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec("INSERT INTO outbox_events(event_type, aggregate_key, payload) VALUES($1, $2, $3)", eventType, key, data)
+	return err
+}
+
+// enqueueStockChanged записывает в outbox изменение остатка товара с текущим остатком после изменения
+func enqueueStockChanged(q queryer, productID, delta int, reason, reference string) error {
+	e := StockChangedEvent{ProductID: productID, Delta: delta, Reason: reason, Reference: reference}
+	err := q.QueryRow("SELECT code, warehouse_id, quantity FROM products WHERE id = $1", productID).
+		Scan(&e.Code, &e.WarehouseID, &e.Quantity)
+	if err != nil {
+		return err
+	}
+
+	return enqueueEvent(q, EventStockChanged, productEventKey(productID), e)
+}
+
+// enqueueWarehouseStatus записывает в outbox смену доступности склада
+func enqueueWarehouseStatus(q queryer, w *Warehouse, reason string) error {
+	eventType := EventWarehouseOffline
+	if w.IsAvailable {
+		eventType = EventWarehouseOnline
+	}
+
+	return enqueueEvent(q, eventType, "warehouse:"+strconv.Itoa(w.ID), WarehouseStatusEvent{
+		WarehouseID: w.ID,
+		Name:        w.Name,
+		IsAvailable: w.IsAvailable,
+		Reason:      reason,
+	})
+}
+
+// RelayOutbox доставляет неопубликованные события outbox каждому получателю, до limit событий
+// на получателя, и возвращает наибольшее число событий, доставленных одному получателю.
+// Событие отмечается опубликованным, когда его приняли все получатели (at-least-once); получатель,
+// уже принявший событие, не получает его повторно из-за ошибки другого. После ошибки остальные
+// события того же ключа откладываются до повтора, чтобы сохранить порядок.
+// Доставка идет вне транзакции под сессионной advisory-блокировкой; если пересылку уже выполняет
+// другой экземпляр, возвращается 0.
+func RelayOutbox(ctx context.Context, db *sql.DB, limit int, publishers []OutboxPublisher) (int, error) {
+	if len(publishers) == 0 {
+		return 0, nil
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", outboxLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer unlockOutbox(conn)
+
+	relayed := 0
+	names := make([]string, len(publishers))
+	for i, p := range publishers {
+		names[i] = p.Name
+		n, err := relayToPublisher(ctx, conn, limit, p)
+		if err != nil {
+			return relayed, err
+		}
+		relayed = max(relayed, n)
+	}
+
+	// позиция в потоке назначается в порядке ID под блокировкой пересылки, поэтому
+	// события становятся видны клиентам потока только с растущими позициями
+	_, err = conn.ExecContext(ctx, `UPDATE outbox_events e SET published_at = now(), stream_position = done.position
+		FROM (
+			SELECT id, nextval('outbox_stream_position_seq') AS position FROM (
+				SELECT event_id AS id FROM outbox_publications
+				WHERE publisher = ANY($1) AND published_at IS NOT NULL
+				GROUP BY event_id HAVING COUNT(*) = cardinality($1::TEXT[])
+				ORDER BY event_id
+			) ready
+		) done
+		WHERE e.id = done.id AND e.published_at IS NULL`, pq.Array(names))
+	if err != nil {
+		return relayed, err
+	}
+
+	_, err = conn.ExecContext(ctx, `DELETE FROM outbox_publications d USING outbox_events e
+		WHERE e.id = d.event_id AND e.published_at IS NOT NULL`)
+	return relayed, err
+}
+
+// relayToPublisher доставляет получателю очередную пачку событий, которые он еще не принял
+func relayToPublisher(ctx context.Context, conn *sql.Conn, limit int, p OutboxPublisher) (int, error) {
+	batch, err := pendingOutboxEvents(ctx, conn, p.Name, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	failedKeys := make(map[string]bool)
+	for _, e := range batch {
+		if failedKeys[e.Key] || ctx.Err() != nil {
+			continue
+		}
+
+		if err := p.Publisher.Publish(ctx, e); err != nil {
+			failedKeys[e.Key] = true
+			_, err = conn.ExecContext(ctx, `INSERT INTO outbox_publications(publisher, event_id, attempts, last_error, retry_at)
+				VALUES($1, $2, 1, $3, now() + $4 * INTERVAL '1 second')
+				ON CONFLICT (publisher, event_id) DO UPDATE SET attempts = outbox_publications.attempts + 1,
+					last_error = EXCLUDED.last_error,
+					retry_at = now() + LEAST($4 * (outbox_publications.attempts + 1) ^ 2, $5) * INTERVAL '1 second'`,
+				p.Name, e.ID, err.Error(), outboxRetryDelay.Seconds(), outboxMaxRetryDelay.Seconds())
+			if err != nil {
+				return published, err
+			}
+			_, err = conn.ExecContext(ctx, "UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2",
+				p.Name+": "+err.Error(), e.ID)
+			if err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		_, err := conn.ExecContext(ctx, `INSERT INTO outbox_publications(publisher, event_id, attempts, published_at) VALUES($1, $2, 1, now())
+			ON CONFLICT (publisher, event_id) DO UPDATE SET attempts = outbox_publications.attempts + 1,
+				last_error = NULL, retry_at = NULL, published_at = now()`, p.Name, e.ID)
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// unlockOutbox снимает блокировку пересылки. Если снять ее не удалось, соединение закрывается,
+// чтобы блокировка не осталась на соединении в пуле.
+func unlockOutbox(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", outboxLockID); err != nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+}
+
+// PurgeOutboxEvents удаляет опубликованные события, срок хранения которых истек
+func PurgeOutboxEvents(db *sql.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM outbox_events WHERE published_at < now() - $1 * INTERVAL '1 second'", outboxRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// pendingOutboxEvents возвращает события, которые получатель еще не принял, кроме ключей,
+// ожидающих повтора после ошибки этого получателя
+func pendingOutboxEvents(ctx context.Context, conn *sql.Conn, publisher string, limit int) ([]events.Event, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT e.id, e.event_type, e.aggregate_key, e.payload, e.created_at FROM outbox_events e
+		WHERE e.published_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox_publications d
+				WHERE d.publisher = $1 AND d.event_id = e.id AND d.published_at IS NOT NULL
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox_publications d JOIN outbox_events f ON f.id = d.event_id
+				WHERE d.publisher = $1 AND d.published_at IS NULL AND d.retry_at > now()
+					AND f.aggregate_key = e.aggregate_key AND f.published_at IS NULL AND f.id <= e.id
+			)
+		ORDER BY e.id LIMIT $2`, publisher, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []events.Event
+	for rows.Next() {
+		var e events.Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Key, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		batch = append(batch, e)
+	}
+
+	return batch, rows.Err()
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+)
+
+// setOutboxRetryDelay меняет задержку повтора доставки outbox на время теста
+func setOutboxRetryDelay(t *testing.T, d time.Duration) {
+	t.Helper()
+
+	prev := outboxRetryDelay
+	outboxRetryDelay = d
+	t.Cleanup(func() { outboxRetryDelay = prev })
+}
+
+func TestRelayOutboxPublishesStockChanges(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 2)
+	setOutboxRetryDelay(t, 0)
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	failing := events.PublisherFunc(func(ctx context.Context, e events.Event) error {
+		return errors.New("unavailable")
+	})
+	if _, err := RelayOutbox(context.Background(), db, 1000, []OutboxPublisher{{Name: "test", Publisher: failing}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reasons []string
+	collect := events.PublisherFunc(func(ctx context.Context, e events.Event) error {
+		if e.Key != productEventKey(p.ID) {
+			return nil
+		}
+		var sc StockChangedEvent
+		if err := json.Unmarshal(e.Payload, &sc); err != nil {
+			return err
+		}
+		reasons = append(reasons, sc.Reason)
+		if sc.Reason == MovementReserve && sc.Quantity != 1 {
+			t.Errorf("Expected quantity 1 after reservation, got %d", sc.Quantity)
+		}
+		return nil
+	})
+	for {
+		n, err := RelayOutbox(context.Background(), db, 1000, []OutboxPublisher{{Name: "test", Publisher: collect}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	if len(reasons) != 2 || reasons[0] != MovementInitial || reasons[1] != MovementReserve {
+		t.Errorf("Expected initial and reserve events in order, got %v", reasons)
+	}
+
+	var pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM outbox_events WHERE aggregate_key = $1 AND published_at IS NULL", productEventKey(p.ID)).Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("Expected all events to be published, %d pending", pending)
+	}
+}
+
+func TestRelayOutboxTracksPublishersSeparately(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	blocked := createTestProduct(t, db, w.ID, 1)
+	other := createTestProduct(t, db, w.ID, 1)
+	setOutboxRetryDelay(t, 0)
+
+	delivered := map[string]map[string]int{"ok": {}, "flaky": {}}
+	down := true
+	publisher := func(name string) events.Publisher {
+		return events.PublisherFunc(func(ctx context.Context, e events.Event) error {
+			if e.Key != productEventKey(blocked.ID) && e.Key != productEventKey(other.ID) {
+				return nil
+			}
+			if name == "flaky" && down && e.Key == productEventKey(blocked.ID) {
+				return errors.New("unavailable")
+			}
+			delivered[name][e.Key]++
+			return nil
+		})
+	}
+	publishers := []OutboxPublisher{{Name: "ok", Publisher: publisher("ok")}, {Name: "flaky", Publisher: publisher("flaky")}}
+
+	relay := func() {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			if _, err := RelayOutbox(context.Background(), db, 1000, publishers); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	relay()
+	// ошибка одного ключа не задерживает другие, а успешный получатель не получает событие повторно
+	if delivered["flaky"][productEventKey(other.ID)] != 1 || delivered["flaky"][productEventKey(blocked.ID)] != 0 {
+		t.Errorf("Expected the flaky publisher to receive only the other key, got %v", delivered["flaky"])
+	}
+	if delivered["ok"][productEventKey(blocked.ID)] != 1 || delivered["ok"][productEventKey(other.ID)] != 1 {
+		t.Errorf("Expected each event exactly once for the healthy publisher, got %v", delivered["ok"])
+	}
+
+	var pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM outbox_events WHERE aggregate_key = $1 AND published_at IS NULL", productEventKey(blocked.ID)).Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 {
+		t.Errorf("Expected the blocked event to stay pending, got %d", pending)
+	}
+
+	down = false
+	relay()
+	if delivered["flaky"][productEventKey(blocked.ID)] != 1 || delivered["ok"][productEventKey(blocked.ID)] != 1 {
+		t.Errorf("Expected the retry to reach only the flaky publisher, got %v", delivered)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM outbox_events WHERE aggregate_key = $1 AND published_at IS NULL", productEventKey(blocked.ID)).Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("Expected the event to be published once every publisher accepted it, %d pending", pending)
+	}
+}
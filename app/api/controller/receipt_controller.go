@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ReceiptOpen     = "open"
+	ReceiptReceived = "received"
+)
+
+// Receipt — входящая поставка на склад. Пока поставка открыта, товар ожидается,
+// после проведения количество зачисляется в остатки, ячейки, партии и серийные номера.
+// CapacityExceeded отмечает поставку, принятую сверх вместимости склада с политикой warn.
+type Receipt struct {
+	ID               int           `json:"id"`
+	WarehouseID      int           `json:"warehouse_id"`
+	Reference        string        `json:"reference,omitempty"`
+	Status           string        `json:"status"`
+	CreatedAt        time.Time     `json:"created_at"`
+	ReceivedAt       *time.Time    `json:"received_at,omitempty"`
+	CapacityExceeded bool          `json:"capacity_exceeded,omitempty"`
+	Lines            []ReceiptLine `json:"lines"`
+}
+
+type ReceiptLine struct {
+	ID         int      `json:"id"`
+	Code       string   `json:"code"`
+	Quantity   int      `json:"quantity"`
+	LocationID *int     `json:"location_id,omitempty"`
+	LotNumber  string   `json:"lot_number,omitempty"`
+	ExpiryDate string   `json:"expiry_date,omitempty"`
+	Serials    []string `json:"serials,omitempty"`
+	UnitCost   *float64 `json:"unit_cost,omitempty"`
+}
+
+//	@Summary		Create an inbound receipt.
+//	@Description	Register an expected inbound receipt for a warehouse. Serial-tracked products must list one serial per unit. Line unit costs are recorded with the stock movements and used for inventory valuation.
+//	@Tags			receipts
+//	@Accept			json
+//	@Produce		json
+//	@Param			receipt	body		Receipt			true	"Receipt information"
+//	@Success		201		{object}	Receipt
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Product not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/receipts [post]
+//
+func CreateReceipt(db *sql.DB, r *Receipt) error {
+	if len(r.Lines) == 0 {
+		return ErrInvalidReceipt
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO receipts(warehouse_id, reference) VALUES($1, NULLIF($2, '')) RETURNING id, status, created_at",
+		r.WarehouseID, r.Reference,
+	).Scan(&r.ID, &r.Status, &r.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range r.Lines {
+		l := &r.Lines[i]
+		if err := validateReceiptLine(tx, r.WarehouseID, l); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err := tx.QueryRow(`INSERT INTO receipt_lines(receipt_id, product_id, quantity, location_id, lot_number, expiry_date, serials, unit_cost)
+			SELECT $1, id, $3, $4, NULLIF($5, ''), NULLIF($6, '')::DATE, $7, $8 FROM products WHERE code = $2
+			RETURNING id`,
+			r.ID, l.Code, l.Quantity, l.LocationID, l.LotNumber, l.ExpiryDate, pq.Array(l.Serials), l.UnitCost,
+		).Scan(&l.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Get an inbound receipt.
+//	@Description	Get an inbound receipt with its lines.
+//	@Tags			receipts
+//	@Produce		json
+//	@Param			id	path		int				true	"Receipt ID"
+//	@Success		200	{object}	Receipt
+//	@Failure		404	{object}	ErrorResponse	"Receipt not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/receipts/{id} [get]
+//
+func GetReceipt(db *sql.DB, id int) (*Receipt, error) {
+	var r Receipt
+	var reference sql.NullString
+	err := db.QueryRow(
+		"SELECT id, warehouse_id, reference, status, created_at, received_at, capacity_exceeded FROM receipts WHERE id = $1", id,
+	).Scan(&r.ID, &r.WarehouseID, &reference, &r.Status, &r.CreatedAt, &r.ReceivedAt, &r.CapacityExceeded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Reference = reference.String
+
+	r.Lines, err = getReceiptLines(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+//	@Summary		Post an inbound receipt.
+//	@Description	Receive all lines of an open receipt: stock, bins, lots and serial numbers are updated in one transaction. A receipt that would exceed the warehouse capacity is rejected, or accepted with capacity_exceeded set when the warehouse policy is warn.
+//	@Tags			receipts
+//	@Produce		json
+//	@Param			id	path		int				true	"Receipt ID"
+//	@Success		200	{object}	Receipt
+//	@Failure		404	{object}	ErrorResponse	"Receipt not found"
+//	@Failure		409	{object}	ErrorResponse	"Receipt is already received or exceeds warehouse capacity"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/receipts/{id}/receive [post]
+//
+func PostReceipt(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var status string
+	var warehouseID int
+	err = tx.QueryRow("SELECT status, warehouse_id FROM receipts WHERE id = $1 FOR UPDATE", id).Scan(&status, &warehouseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return ErrNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status != ReceiptOpen {
+		tx.Rollback()
+		return ErrInvalidStatus
+	}
+
+	if err := checkReceiptCapacity(tx, id, warehouseID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	lines, err := getReceiptLines(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	reference := "receipt:" + strconv.Itoa(id)
+	for _, l := range lines {
+		if err := receiveLine(tx, l, reference); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE receipts SET status = $1, received_at = now() WHERE id = $2", ReceiptReceived, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveLine зачисляет строку поставки в остатки товара, ячейку, партию и серийные номера
+func receiveLine(tx *sql.Tx, l ReceiptLine, reference string) error {
+	var productID, warehouseID int
+	err := tx.QueryRow("SELECT id, warehouse_id FROM products WHERE code = $1 FOR UPDATE", l.Code).Scan(&productID, &warehouseID)
+	if err != nil {
+		return err
+	}
+
+	if l.LocationID != nil {
+		if _, err := lockProductInBin(tx, *l.LocationID, l.Code); err != nil {
+			return err
+		}
+		if err := addBinStock(tx, *l.LocationID, productID, l.Quantity); err != nil {
+			return err
+		}
+	}
+
+	if l.LotNumber != "" {
+		lot := StockLot{LotNumber: l.LotNumber, ExpiryDate: l.ExpiryDate}
+		if err := addLotStock(tx, productID, &lot, l.Quantity); err != nil {
+			return err
+		}
+	}
+
+	if err := registerSerials(tx, productID, warehouseID, l.Serials, l.LocationID, reference); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE products SET quantity = quantity + $1 WHERE id = $2", l.Quantity, productID)
+	if err != nil {
+		return err
+	}
+
+	return recordCostedMovement(tx, productID, l.Quantity, MovementReceipt, reference, l.UnitCost)
+}
+
+func validateReceiptLine(tx *sql.Tx, warehouseID int, l *ReceiptLine) error {
+	if l.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	if l.UnitCost != nil && *l.UnitCost < 0 {
+		return ErrInvalidReceipt
+	}
+	if l.ExpiryDate != "" {
+		if _, err := time.Parse(dateLayout, l.ExpiryDate); err != nil {
+			return ErrInvalidReceipt
+		}
+	}
+
+	var productWarehouseID int
+	var serialTracked, isBundle bool
+	err := tx.QueryRow("SELECT warehouse_id, serial_tracked, is_bundle FROM products WHERE code = $1", l.Code).Scan(&productWarehouseID, &serialTracked, &isBundle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if productWarehouseID != warehouseID || isBundle {
+		return ErrInvalidReceipt
+	}
+
+	if serialTracked && len(l.Serials) != l.Quantity {
+		return ErrSerialsMismatch
+	}
+	if !serialTracked && len(l.Serials) > 0 {
+		return ErrSerialsMismatch
+	}
+
+	return nil
+}
+
+func getReceiptLines(q queryer, receiptID int) ([]ReceiptLine, error) {
+	rows, err := q.Query(`SELECT l.id, p.code, l.quantity, l.location_id, COALESCE(l.lot_number, ''), l.expiry_date, l.serials,
+			l.unit_cost
+		FROM receipt_lines l JOIN products p ON p.id = l.product_id
+		WHERE l.receipt_id = $1 ORDER BY l.id`, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []ReceiptLine
+	for rows.Next() {
+		var l ReceiptLine
+		var expiry sql.NullTime
+		if err := rows.Scan(&l.ID, &l.Code, &l.Quantity, &l.LocationID, &l.LotNumber, &expiry, pq.Array(&l.Serials),
+			&l.UnitCost); err != nil {
+			return nil, err
+		}
+		l.ExpiryDate = formatDate(expiry)
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
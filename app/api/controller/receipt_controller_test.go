@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPostReceiptUpdatesStockBinsAndLots(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	bin := createTestBin(t, db, w.ID)
+
+	r := &Receipt{
+		WarehouseID: w.ID,
+		Lines: []ReceiptLine{{
+			Code:       p.Code,
+			Quantity:   5,
+			LocationID: &bin.ID,
+			LotNumber:  "R-1",
+			ExpiryDate: time.Now().AddDate(1, 0, 0).Format(dateLayout),
+		}},
+	}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 0 {
+		t.Errorf("Expected open receipt not to change stock, got %d", q)
+	}
+
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 5 {
+		t.Errorf("Expected product quantity to be 5, but got %d", q)
+	}
+
+	lots, err := GetProductLots(db, p.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lots) != 1 || lots[0].Quantity != 5 {
+		t.Errorf("Expected one lot with 5 units, got %+v", lots)
+	}
+
+	if err := PostReceipt(db, r.ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus when posting twice, but got %v", err)
+	}
+}
+
+func TestCreateReceiptRejectsForeignWarehouseProduct(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	other := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, other.ID, 0)
+
+	r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 1}}}
+	if err := CreateReceipt(db, r); !errors.Is(err, ErrInvalidReceipt) {
+		t.Errorf("Expected ErrInvalidReceipt, but got %v", err)
+	}
+}
@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// regionTreeSQL выбирает регион $1 и все вложенные в него регионы. UNION отбрасывает уже
+// найденные регионы, поэтому обход конечен, даже если в иерархии оказался цикл.
+const regionTreeSQL = `WITH RECURSIVE tree AS (
+		SELECT id FROM regions WHERE id = $1
+		UNION
+		SELECT r.id FROM regions r JOIN tree t ON r.parent_id = t.id
+	)`
+
+// regionTreeLockID — ключ advisory-блокировки, под которой меняются родители регионов: проверка
+// на цикл и перенос региона не должны пересекаться с другим переносом
+const regionTreeLockID = 38002
+
+// Region — узел иерархии складов. Регион без родителя — сеть; WarehouseIDs — склады, привязанные
+// непосредственно к региону, без складов вложенных регионов.
+type Region struct {
+	ID           int       `json:"id"`
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	ParentID     *int      `json:"parent_id"`
+	WarehouseIDs []int     `json:"warehouse_ids"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WarehouseRegion — регион, к которому привязан склад; null — склад вне регионов
+type WarehouseRegion struct {
+	RegionID *int `json:"region_id"`
+}
+
+// RegionStock — остатки региона вместе с вложенными регионами без просроченных партий и комплектов
+type RegionStock struct {
+	RegionID   int                    `json:"region_id"`
+	Quantity   int                    `json:"quantity"`
+	Warehouses []RegionWarehouseStock `json:"warehouses"`
+	Items      []RegionItemStock      `json:"items"`
+}
+
+// RegionWarehouseStock — остаток одного склада региона
+type RegionWarehouseStock struct {
+	WarehouseID int    `json:"warehouse_id"`
+	Name        string `json:"name"`
+	RegionID    int    `json:"region_id"`
+	Quantity    int    `json:"quantity"`
+}
+
+// RegionItemStock — суммарный остаток взаимозаменяемых товаров региона: одного размера одной модели
+// или, для товаров вне каталога, одного кода
+type RegionItemStock struct {
+	StyleCode string   `json:"style_code,omitempty"`
+	Size      string   `json:"size,omitempty"`
+	Codes     []string `json:"codes"`
+	Quantity  int      `json:"quantity"`
+}
+
+// RegionAllocation — единицы кода из корзины, зарезервированные на складе региона
+type RegionAllocation struct {
+	Code        string `json:"code"`
+	ProductCode string `json:"product_code"`
+	WarehouseID int    `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+}
+
+func (r *Region) validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Code == "" || r.Name == "" {
+		return ErrInvalidRegion
+	}
+	return nil
+}
+
+//	@Summary		Create a region.
+//	@Description	Create a region of warehouses. A region without a parent is a network; regions can be nested to any depth.
+//	@Tags			regions
+//	@Accept			json
+//	@Produce		json
+//	@Param			region	body		Region			true	"Region"
+//	@Success		201		{object}	Region
+//	@Failure		400		{object}	ErrorResponse	"Invalid or duplicate region"
+//	@Failure		404		{object}	ErrorResponse	"Parent region not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/regions [post]
+//
+func CreateRegion(db *sql.DB, r *Region) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	err := db.QueryRow("INSERT INTO regions(code, name, parent_id) VALUES($1, $2, $3) RETURNING id, created_at",
+		r.Code, r.Name, r.ParentID).Scan(&r.ID, &r.CreatedAt)
+	if err := regionWriteError(err); err != nil {
+		return err
+	}
+	r.WarehouseIDs = []int{}
+
+	return nil
+}
+
+//	@Summary		Update a region.
+//	@Description	Rename a region or move it under another parent. A region cannot be moved under itself or one of its nested regions.
+//	@Tags			regions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Region ID"
+//	@Param			region	body		Region			true	"Region"
+//	@Success		200		{object}	Region
+//	@Failure		400		{object}	ErrorResponse	"Invalid region or parent"
+//	@Failure		404		{object}	ErrorResponse	"Region not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/regions/{id} [put]
+//
+func UpdateRegion(db *sql.DB, r *Region) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if r.ParentID != nil {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", regionTreeLockID); err != nil {
+			return err
+		}
+
+		var cycle bool
+		err := tx.QueryRow(regionTreeSQL+" SELECT EXISTS (SELECT 1 FROM tree WHERE id = $2)", r.ID, *r.ParentID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrInvalidRegion
+		}
+	}
+
+	err = tx.QueryRow("UPDATE regions SET code = $1, name = $2, parent_id = $3 WHERE id = $4 RETURNING created_at",
+		r.Code, r.Name, r.ParentID, r.ID).Scan(&r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err := regionWriteError(err); err != nil {
+		return err
+	}
+
+	if r.WarehouseIDs, err = queryIDs(tx, "SELECT id FROM warehouse WHERE region_id = $1 ORDER BY id", r.ID); err != nil {
+		return err
+	}
+	if r.WarehouseIDs == nil {
+		r.WarehouseIDs = []int{}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		List regions.
+//	@Description	List all regions with their parents and the warehouses assigned directly to them.
+//	@Tags			regions
+//	@Produce		json
+//	@Success		200	{array}		Region
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/regions [get]
+//
+func GetRegions(db *sql.DB) ([]Region, error) {
+	rows, err := db.Query(`SELECT r.id, r.code, r.name, r.parent_id, r.created_at,
+			COALESCE(array_agg(w.id ORDER BY w.id) FILTER (WHERE w.id IS NOT NULL), '{}')
+		FROM regions r LEFT JOIN warehouse w ON w.region_id = r.id
+		GROUP BY r.id ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	regions := []Region{}
+	for rows.Next() {
+		var r Region
+		var parentID sql.NullInt64
+		var warehouseIDs pq.Int64Array
+		if err := rows.Scan(&r.ID, &r.Code, &r.Name, &parentID, &r.CreatedAt, &warehouseIDs); err != nil {
+			return nil, err
+		}
+		r.ParentID = nullIntPtr(parentID)
+		r.WarehouseIDs = make([]int, len(warehouseIDs))
+		for i, id := range warehouseIDs {
+			r.WarehouseIDs[i] = int(id)
+		}
+		regions = append(regions, r)
+	}
+
+	return regions, rows.Err()
+}
+
+//	@Summary		Assign a warehouse to a region.
+//	@Description	Assign a warehouse to a region or, with a null region_id, remove it from its region.
+//	@Tags			regions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Warehouse ID"
+//	@Param			region	body		WarehouseRegion	true	"Region"
+//	@Success		200		{object}	Warehouse
+//	@Failure		404		{object}	ErrorResponse	"Warehouse or region not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/region [put]
+//
+func SetWarehouseRegion(db *sql.DB, warehouseID int, regionID *int) error {
+	res, err := db.Exec("UPDATE warehouse SET region_id = $1 WHERE id = $2", regionID, warehouseID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Get region stock.
+//	@Description	Get the remaining stock of a region and its nested regions, per warehouse and per item. Items of the same size of the same style are summed across warehouses; products outside the catalog are summed by code. Expired lots and bundles are not counted.
+//	@Tags			regions
+//	@Produce		json
+//	@Param			id	path		int				true	"Region ID"
+//	@Success		200	{object}	RegionStock
+//	@Failure		404	{object}	ErrorResponse	"Region not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/regions/{id}/remaining-products [get]
+//
+func GetRegionStock(db *sql.DB, regionID int) (*RegionStock, error) {
+	if err := checkRegionExists(db, regionID); err != nil {
+		return nil, err
+	}
+
+	stock := RegionStock{RegionID: regionID, Warehouses: []RegionWarehouseStock{}, Items: []RegionItemStock{}}
+
+	rows, err := db.Query(regionTreeSQL+`, stock AS (
+			SELECT p.id, p.code, p.warehouse_id, p.style_id, p.size_id, `+availableSQL+` AS quantity
+			FROM products p JOIN warehouse w ON w.id = p.warehouse_id
+			WHERE w.region_id IN (SELECT id FROM tree) AND NOT p.is_bundle
+		)
+		SELECT w.id, COALESCE(w.name, ''), w.region_id, COALESCE(SUM(s.quantity), 0)
+		FROM warehouse w LEFT JOIN stock s ON s.warehouse_id = w.id
+		WHERE w.region_id IN (SELECT id FROM tree)
+		GROUP BY w.id ORDER BY w.id`, regionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w RegionWarehouseStock
+		if err := rows.Scan(&w.WarehouseID, &w.Name, &w.RegionID, &w.Quantity); err != nil {
+			return nil, err
+		}
+		stock.Quantity += w.Quantity
+		stock.Warehouses = append(stock.Warehouses, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = db.Query(regionTreeSQL+`
+		SELECT COALESCE(st.code, ''), COALESCE(sz.label, ''), array_agg(p.code ORDER BY p.code), SUM(`+availableSQL+`)
+		FROM products p
+		JOIN warehouse w ON w.id = p.warehouse_id
+		LEFT JOIN styles st ON st.id = p.style_id
+		LEFT JOIN size_grid sz ON sz.id = p.size_id
+		WHERE w.region_id IN (SELECT id FROM tree) AND NOT p.is_bundle
+		GROUP BY st.code, sz.label, CASE WHEN p.style_id IS NULL THEN p.code END
+		ORDER BY 1, 2, 3`, regionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item RegionItemStock
+		var codes pq.StringArray
+		if err := rows.Scan(&item.StyleCode, &item.Size, &codes, &item.Quantity); err != nil {
+			return nil, err
+		}
+		item.Codes = codes
+		stock.Items = append(stock.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &stock, nil
+}
+
+// ReserveProductsInRegion резервирует корзину на складах региона и вложенных регионов. Код можно
+// собрать тем же размером той же модели с любого склада региона; если корзину целиком может собрать
+// один склад, резерв берется с него. Учитываются только доступные сейчас склады.
+func ReserveProductsInRegion(db *sql.DB, regionID int, productCodes []string) ([]RegionAllocation, error) {
+	if len(productCodes) == 0 {
+		return nil, ErrEmptyProductCodes
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkRegionExists(tx, regionID); err != nil {
+		return nil, err
+	}
+
+	codes, demand, err := basketDemand(tx, productCodes)
+	if err != nil {
+		return nil, err
+	}
+	equivalents, err := basketEquivalents(tx, codes)
+	if err != nil {
+		return nil, err
+	}
+	warehouseIDs, err := regionWarehouses(tx, regionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// склад, собирающий всю корзину, идет первым, чтобы не дробить отправку
+	for i, id := range warehouseIDs {
+		if _, ok := fulfilFromWarehouse(codes, demand, equivalents, id); ok {
+			copy(warehouseIDs[1:i+1], warehouseIDs[:i])
+			warehouseIDs[0] = id
+			break
+		}
+	}
+
+	allocations := []RegionAllocation{}
+	for _, code := range codes {
+		for n := 0; n < demand[code]; n++ {
+			productCode, warehouseID, err := reserveFirstUnit(tx, equivalents[code], warehouseIDs)
+			if err != nil {
+				return nil, err
+			}
+			allocations = addRegionAllocation(allocations, RegionAllocation{
+				Code:        code,
+				ProductCode: productCode,
+				WarehouseID: warehouseID,
+				Quantity:    1,
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+func addRegionAllocation(allocations []RegionAllocation, a RegionAllocation) []RegionAllocation {
+	for i := range allocations {
+		if allocations[i].Code == a.Code && allocations[i].ProductCode == a.ProductCode {
+			allocations[i].Quantity++
+			return allocations
+		}
+	}
+	return append(allocations, a)
+}
+
+// regionWarehouses возвращает склады региона и вложенных регионов, доступные сейчас по флагу и календарю
+func regionWarehouses(q queryer, regionID int) ([]int, error) {
+	candidates, err := queryIDs(q, regionTreeSQL+` SELECT id FROM warehouse
+		WHERE region_id IN (SELECT id FROM tree) AND (is_available OR calendar_closed) ORDER BY id`, regionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var ids []int
+	for _, id := range candidates {
+		// выключенные вручную склады отсеяны запросом, здесь остается проверить календарь
+		a, err := warehouseAvailability(q, id, true, false, now)
+		if err != nil {
+			return nil, err
+		}
+		if a.IsAvailable {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func checkRegionExists(q queryer, regionID int) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM regions WHERE id = $1)", regionID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// regionWriteError переводит нарушения ограничений при записи региона в ошибки API
+func regionWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505", "23514":
+			return ErrInvalidRegion
+		case "23503":
+			return ErrNotFound
+		}
+	}
+	return err
+}
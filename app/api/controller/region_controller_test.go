@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func TestRegionStockAndReservation(t *testing.T) {
+	db := openTestDB(t)
+	s := createTestStyle(t, db)
+
+	network := Region{Code: utils.RandomString(8), Name: "Russia"}
+	if err := CreateRegion(db, &network); err != nil {
+		t.Fatal(err)
+	}
+	north := Region{Code: utils.RandomString(8), Name: "North-West", ParentID: &network.ID}
+	if err := CreateRegion(db, &north); err != nil {
+		t.Fatal(err)
+	}
+
+	petersburg := createTestWarehouse(t, db)
+	moscow := createTestWarehouse(t, db)
+	if err := SetWarehouseRegion(db, petersburg.ID, &north.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWarehouseRegion(db, moscow.ID, &network.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	mPetersburg := createTestProduct(t, db, petersburg.ID, 2)
+	mMoscow := createTestProduct(t, db, moscow.ID, 3)
+	for _, code := range []string{mPetersburg.Code, mMoscow.Code} {
+		if err := AddStyleVariant(db, s.Code, StyleVariant{Code: code, Size: "M"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stock, err := GetRegionStock(db, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Quantity != 5 || len(stock.Warehouses) != 2 {
+		t.Fatalf("Expected 5 units in 2 warehouses of the network, got %+v", stock)
+	}
+	if len(stock.Items) != 1 || stock.Items[0].StyleCode != s.Code || stock.Items[0].Size != "M" || stock.Items[0].Quantity != 5 {
+		t.Errorf("Expected one item of size M summed across warehouses, got %+v", stock.Items)
+	}
+
+	stock, err = GetRegionStock(db, north.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Quantity != 2 || len(stock.Warehouses) != 1 || stock.Warehouses[0].WarehouseID != petersburg.ID {
+		t.Errorf("Expected only the Saint Petersburg stock in the nested region, got %+v", stock)
+	}
+
+	// код московского товара собирается тем же размером со склада региона
+	allocations, err := ReserveProductsInRegion(db, north.ID, []string{mMoscow.Code, mMoscow.Code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 1 || allocations[0].ProductCode != mPetersburg.Code || allocations[0].Quantity != 2 {
+		t.Errorf("Expected 2 units from Saint Petersburg, got %+v", allocations)
+	}
+	if q := productQuantity(t, db, mMoscow.ID); q != 3 {
+		t.Errorf("Expected Moscow stock untouched, got %d", q)
+	}
+
+	if _, err := ReserveProductsInRegion(db, north.ID, []string{mMoscow.Code}); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Expected ErrOutOfStock when the region is sold out, got %v", err)
+	}
+}
+
+func TestRegionHierarchyValidates(t *testing.T) {
+	db := openTestDB(t)
+
+	network := Region{Code: utils.RandomString(8), Name: "Network"}
+	if err := CreateRegion(db, &network); err != nil {
+		t.Fatal(err)
+	}
+	child := Region{Code: utils.RandomString(8), Name: "Child", ParentID: &network.ID}
+	if err := CreateRegion(db, &child); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateRegion(db, &Region{Code: network.Code, Name: "Duplicate"}); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("Expected ErrInvalidRegion for a duplicate code, got %v", err)
+	}
+
+	network.ParentID = &child.ID
+	if err := UpdateRegion(db, &network); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("Expected ErrInvalidRegion when moving a region under its child, got %v", err)
+	}
+
+	missing := -1
+	if err := CreateRegion(db, &Region{Code: utils.RandomString(8), Name: "Orphan", ParentID: &missing}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing parent, got %v", err)
+	}
+	if err := SetWarehouseRegion(db, createTestWarehouse(t, db).ID, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing region, got %v", err)
+	}
+}
+
+func TestConcurrentRegionMovesCannotFormCycle(t *testing.T) {
+	db := openTestDB(t)
+
+	network := Region{Code: utils.RandomString(8), Name: "Network"}
+	if err := CreateRegion(db, &network); err != nil {
+		t.Fatal(err)
+	}
+	north := Region{Code: utils.RandomString(8), Name: "North", ParentID: &network.ID}
+	if err := CreateRegion(db, &north); err != nil {
+		t.Fatal(err)
+	}
+	south := Region{Code: utils.RandomString(8), Name: "South", ParentID: &network.ID}
+	if err := CreateRegion(db, &south); err != nil {
+		t.Fatal(err)
+	}
+
+	// встречные переносы по отдельности допустимы, но вместе образовали бы цикл
+	moves := []Region{north, south}
+	moves[0].ParentID = &south.ID
+	moves[1].ParentID = &north.ID
+
+	errs := make([]error, len(moves))
+	var wg sync.WaitGroup
+	for i := range moves {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = UpdateRegion(db, &moves[i])
+		}(i)
+	}
+	wg.Wait()
+
+	rejected := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrInvalidRegion) {
+			rejected++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rejected != 1 {
+		t.Errorf("Expected exactly one of the opposite moves to be rejected, got %v", errs)
+	}
+
+	stock, err := GetRegionStock(db, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.RegionID != network.ID {
+		t.Errorf("Expected the region tree to stay walkable, got %+v", stock)
+	}
+}
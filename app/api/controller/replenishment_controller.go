@@ -0,0 +1,116 @@
+package controller
+
+import "database/sql"
+
+const (
+	defaultReplenishmentWindow = 30
+	maxReplenishmentDays       = 365
+)
+
+// ReplenishmentOptions — параметры отчета. WindowDays — за сколько дней считается средний
+// дневной резерв (нулевой — 30 дней), CoverDays — на сколько дней спроса рассчитывается заказ;
+// при нулевом CoverDays заказ только восполняет пороги.
+type ReplenishmentOptions struct {
+	WarehouseID int
+	WindowDays  int
+	CoverDays   int
+	All         bool
+}
+
+// ReplenishmentLine — предложение по дозаказу товара
+type ReplenishmentLine struct {
+	ProductID         int     `json:"product_id"`
+	Code              string  `json:"code"`
+	Name              string  `json:"name"`
+	WarehouseID       int     `json:"warehouse_id"`
+	Available         int     `json:"available"`
+	Inbound           int     `json:"inbound"`
+	ReorderPoint      *int    `json:"reorder_point,omitempty"`
+	SafetyStock       *int    `json:"safety_stock,omitempty"`
+	AvgDailyReserved  float64 `json:"avg_daily_reserved"`
+	TargetLevel       int     `json:"target_level"`
+	SuggestedQuantity int     `json:"suggested_quantity"`
+}
+
+//	@Summary		Replenishment suggestions.
+//	@Description	Suggest reorder quantities per product. The target level is the larger of the reorder point and safety stock plus the average daily net reservations over window_days multiplied by cover_days (0 covers only the thresholds); the suggestion is the target minus available stock (without expired lots) and quantities on open receipts. Only products with a positive suggestion are returned unless all=true.
+//	@Tags			reports
+//	@Produce		json
+//	@Param			warehouse_id	query		int				false	"Warehouse ID"
+//	@Param			window_days		query		int				false	"Days of reservation history"	default(30)
+//	@Param			cover_days		query		int				false	"Days of demand to cover"		default(14)
+//	@Param			all				query		bool			false	"Include products that need no reorder"
+//	@Success		200				{array}		ReplenishmentLine
+//	@Failure		400				{object}	ErrorResponse	"Invalid parameters"
+//	@Failure		500				{object}	ErrorResponse	"Internal server error"
+//	@Router			/reports/replenishment [get]
+//
+func GetReplenishmentReport(db *sql.DB, opts ReplenishmentOptions) ([]ReplenishmentLine, error) {
+	if opts.WindowDays == 0 {
+		opts.WindowDays = defaultReplenishmentWindow
+	}
+	if opts.WindowDays < 0 || opts.WindowDays > maxReplenishmentDays || opts.CoverDays < 0 || opts.CoverDays > maxReplenishmentDays {
+		return nil, ErrInvalidReport
+	}
+
+	rows, err := db.Query(`SELECT p.id, p.code, COALESCE(p.name, ''), p.warehouse_id,
+			`+availableSQL+`,
+			COALESCE((
+				SELECT SUM(rl.quantity) FROM receipt_lines rl JOIN receipts r ON r.id = rl.receipt_id
+				WHERE rl.product_id = p.id AND r.status = $2
+			), 0),
+			COALESCE(p.reorder_point, w.default_reorder_point), COALESCE(p.safety_stock, w.default_safety_stock),
+			GREATEST(COALESCE((
+				SELECT -SUM(m.quantity_delta) FROM stock_movements m
+				WHERE m.product_id = p.id AND m.reason IN ($3, $4) AND m.created_at >= now() - $5 * INTERVAL '1 day'
+			), 0), 0)
+		FROM products p JOIN warehouse w ON w.id = p.warehouse_id
+		WHERE NOT p.is_bundle AND ($1 = 0 OR p.warehouse_id = $1)
+		ORDER BY p.warehouse_id, p.code`,
+		opts.WarehouseID, ReceiptOpen, MovementReserve, MovementRelease, opts.WindowDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []ReplenishmentLine{}
+	for rows.Next() {
+		var l ReplenishmentLine
+		var reorderPoint, safetyStock sql.NullInt64
+		var reserved int
+		if err := rows.Scan(&l.ProductID, &l.Code, &l.Name, &l.WarehouseID, &l.Available, &l.Inbound,
+			&reorderPoint, &safetyStock, &reserved); err != nil {
+			return nil, err
+		}
+		l.ReorderPoint = nullIntPtr(reorderPoint)
+		l.SafetyStock = nullIntPtr(safetyStock)
+		l.AvgDailyReserved = float64(reserved) / float64(opts.WindowDays)
+		l.TargetLevel, l.SuggestedQuantity = suggestReorder(l, reserved, opts.WindowDays, opts.CoverDays)
+
+		if l.SuggestedQuantity > 0 || opts.All {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines, rows.Err()
+}
+
+// suggestReorder возвращает целевой уровень запаса и количество к заказу с учетом ожидаемых поступлений.
+// Спрос на coverDays считается в целых числах из резерва reserved за windowDays с округлением вверх.
+func suggestReorder(l ReplenishmentLine, reserved, windowDays, coverDays int) (target, suggested int) {
+	floor := 0
+	if l.ReorderPoint != nil {
+		floor = *l.ReorderPoint
+	}
+	if l.SafetyStock != nil && *l.SafetyStock > floor {
+		floor = *l.SafetyStock
+	}
+
+	target = floor + (reserved*coverDays+windowDays-1)/windowDays
+	suggested = target - l.Available - l.Inbound
+	if suggested < 0 {
+		suggested = 0
+	}
+
+	return target, suggested
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSuggestReorder(t *testing.T) {
+	cases := []struct {
+		line          ReplenishmentLine
+		reserved      int
+		windowDays    int
+		coverDays     int
+		wantTarget    int
+		wantSuggested int
+	}{
+		{ReplenishmentLine{Available: 2, ReorderPoint: intPtr(5)}, 15, 30, 14, 12, 10},
+		{ReplenishmentLine{Available: 2, Inbound: 20, ReorderPoint: intPtr(5)}, 15, 30, 14, 12, 0},
+		{ReplenishmentLine{Available: 0, SafetyStock: intPtr(3), ReorderPoint: intPtr(1)}, 0, 30, 14, 3, 3},
+		{ReplenishmentLine{Available: 1}, 3, 30, 10, 1, 0},
+		{ReplenishmentLine{Available: 0}, 1, 30, 7, 1, 1},
+	}
+	for i, c := range cases {
+		target, suggested := suggestReorder(c.line, c.reserved, c.windowDays, c.coverDays)
+		if target != c.wantTarget || suggested != c.wantSuggested {
+			t.Errorf("case %d: got target %d, suggested %d; want %d, %d", i, target, suggested, c.wantTarget, c.wantSuggested)
+		}
+	}
+}
+
+func TestGetReplenishmentReport(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+	createTestProduct(t, db, w.ID, 10)
+
+	if err := SetProductThresholds(db, p.Code, StockThresholds{ReorderPoint: intPtr(5)}); err != nil {
+		t.Fatal(err)
+	}
+	codes := make([]string, 9)
+	for i := range codes {
+		codes[i] = p.Code
+	}
+	if err := ReserveProducts(db, codes); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseProducts(db, []string{p.Code, p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := GetReplenishmentReport(db, ReplenishmentOptions{WarehouseID: w.ID, WindowDays: 3, CoverDays: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("Expected one product to reorder, got %d", len(lines))
+	}
+
+	// 6 единиц чистого резерва за 3 дня — 2 в день, на 2 дня нужно 4 сверх точки заказа
+	l := lines[0]
+	if l.Code != p.Code || l.Available != 4 || l.AvgDailyReserved != 2 || l.TargetLevel != 9 || l.SuggestedQuantity != 5 {
+		t.Errorf("Unexpected replenishment line %+v", l)
+	}
+
+	all, err := GetReplenishmentReport(db, ReplenishmentOptions{WarehouseID: w.ID, All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected both products with all=true, got %d", len(all))
+	}
+
+	// без дней покрытия заказ только восполняет точку заказа
+	thresholds, err := GetReplenishmentReport(db, ReplenishmentOptions{WarehouseID: w.ID, WindowDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 1 || thresholds[0].TargetLevel != 5 || thresholds[0].SuggestedQuantity != 1 {
+		t.Errorf("Expected the target to be the reorder point with cover_days=0, got %+v", thresholds)
+	}
+
+	if _, err := GetReplenishmentReport(db, ReplenishmentOptions{WindowDays: -1}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport, got %v", err)
+	}
+}
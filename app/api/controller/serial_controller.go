@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SerialInStock  = "in_stock"
+	SerialReserved = "reserved"
+	SerialShipped  = "shipped"
+)
+
+// SerialUnit описывает конкретную единицу товара с серийным номером и ее историю
+type SerialUnit struct {
+	Serial      string        `json:"serial"`
+	Code        string        `json:"code"`
+	Status      string        `json:"status"`
+	WarehouseID int           `json:"warehouse_id"`
+	LocationID  *int          `json:"location_id,omitempty"`
+	Path        string        `json:"path,omitempty"`
+	History     []SerialEvent `json:"history"`
+}
+
+type SerialEvent struct {
+	Event       string    `json:"event"`
+	WarehouseID *int      `json:"warehouse_id,omitempty"`
+	LocationID  *int      `json:"location_id,omitempty"`
+	Reference   string    `json:"reference,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+//	@Summary		Reserve serial numbers.
+//	@Description	Reserve specific serial-numbered units. Each serial reserves one unit of its product.
+//	@Tags			serials
+//	@Accept			json
+//	@Produce		json
+//	@Param			serials	body		[]string		true	"Serial numbers"
+//	@Success		200		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Serial not found"
+//	@Failure		409		{object}	ErrorResponse	"Serial is not available"
+//	@Router			/reserve-serials [post]
+//
+func ReserveSerials(db *sql.DB, serials []string) error {
+	return changeSerials(db, serials, SerialInStock, SerialReserved, func(tx *sql.Tx, s *serialRow) error {
+		_, err := reserveUnit(tx, s.code, "")
+		return err
+	})
+}
+
+//	@Summary		Release serial numbers.
+//	@Description	Release reserved serial-numbered units back to stock.
+//	@Tags			serials
+//	@Accept			json
+//	@Produce		json
+//	@Param			serials	body		[]string		true	"Serial numbers"
+//	@Success		200		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Serial not found"
+//	@Failure		409		{object}	ErrorResponse	"Serial is not reserved"
+//	@Router			/release-serials [post]
+//
+func ReleaseSerials(db *sql.DB, serials []string) error {
+	return changeSerials(db, serials, SerialReserved, SerialInStock, func(tx *sql.Tx, s *serialRow) error {
+		// номер возвращается в наличие до единицы товара, чтобы ее мог сразу зарезервировать лист ожидания
+		s.status = SerialInStock
+		if err := updateSerial(tx, s); err != nil {
+			return err
+		}
+		_, err := releaseChannelUnit(tx, s.code, "", 0, false)
+		return err
+	})
+}
+
+//	@Summary		Ship serial numbers.
+//	@Description	Mark reserved serial-numbered units as shipped and take them out of their bins.
+//	@Tags			serials
+//	@Accept			json
+//	@Produce		json
+//	@Param			serials	body		[]string		true	"Serial numbers"
+//	@Success		200		{string}	string			""
+//	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404		{object}	ErrorResponse	"Serial not found"
+//	@Failure		409		{object}	ErrorResponse	"Serial is not reserved"
+//	@Router			/ship-serials [post]
+//
+func ShipSerials(db *sql.DB, serials []string) error {
+	return changeSerials(db, serials, SerialReserved, SerialShipped, func(tx *sql.Tx, s *serialRow) error {
+		// зарезервированная единица уходит со склада так же, как при отборе из ячейки
+		_, err := tx.Exec("UPDATE products SET reserved = GREATEST(reserved - 1, 0) WHERE id = $1", s.productID)
+		if err != nil || s.locationID == nil {
+			return err
+		}
+		if err := shipBinUnit(tx, *s.locationID, s.productID); err != nil {
+			return err
+		}
+		s.locationID = nil
+		return nil
+	})
+}
+
+//	@Summary		Look up a serial number.
+//	@Description	Get the current status, location and full history of a serial-numbered unit.
+//	@Tags			serials
+//	@Produce		json
+//	@Param			serial	path		string			true	"Serial number"
+//	@Success		200		{object}	SerialUnit
+//	@Failure		404		{object}	ErrorResponse	"Serial not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/serials/{serial} [get]
+//
+func GetSerial(db *sql.DB, serial string) (*SerialUnit, error) {
+	var u SerialUnit
+	var id int
+	var path sql.NullString
+	err := db.QueryRow(locationPathsQuery+`
+		SELECT s.id, s.serial, p.code, s.status, p.warehouse_id, s.location_id, tree.path
+		FROM serial_numbers s JOIN products p ON p.id = s.product_id
+		LEFT JOIN tree ON tree.id = s.location_id
+		WHERE s.serial = $1`, serial,
+	).Scan(&id, &u.Serial, &u.Code, &u.Status, &u.WarehouseID, &u.LocationID, &path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.String
+
+	rows, err := db.Query(`SELECT event, warehouse_id, location_id, COALESCE(reference, ''), created_at
+		FROM serial_events WHERE serial_id = $1 ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e SerialEvent
+		if err := rows.Scan(&e.Event, &e.WarehouseID, &e.LocationID, &e.Reference, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.History = append(u.History, e)
+	}
+
+	return &u, rows.Err()
+}
+
+type serialRow struct {
+	id          int
+	productID   int
+	warehouseID int
+	code        string
+	status      string
+	locationID  *int
+}
+
+// changeSerials переводит серийные номера из статуса from в статус to, вызывая apply для каждого
+func changeSerials(db *sql.DB, serials []string, from, to string, apply func(tx *sql.Tx, s *serialRow) error) error {
+	if len(serials) == 0 {
+		return ErrEmptyProductCodes
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		var s serialRow
+		err := tx.QueryRow(`SELECT s.id, s.product_id, p.warehouse_id, p.code, s.status, s.location_id
+			FROM serial_numbers s JOIN products p ON p.id = s.product_id
+			WHERE s.serial = $1 FOR UPDATE OF s`, serial,
+		).Scan(&s.id, &s.productID, &s.warehouseID, &s.code, &s.status, &s.locationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return ErrNotFound
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if s.status != from {
+			tx.Rollback()
+			return ErrSerialUnavailable
+		}
+
+		if err := apply(tx, &s); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if s.status == to {
+			// apply уже перевел номер
+			continue
+		}
+
+		s.status = to
+		if err := updateSerial(tx, &s); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// registerSerials заводит новые серийные номера при приемке товара
+func registerSerials(tx *sql.Tx, productID, warehouseID int, serials []string, locationID *int, reference string) error {
+	for _, serial := range serials {
+		s := serialRow{productID: productID, warehouseID: warehouseID, status: SerialInStock, locationID: locationID}
+		err := tx.QueryRow(
+			"INSERT INTO serial_numbers(product_id, serial, status, location_id) VALUES($1, $2, $3, $4) RETURNING id",
+			productID, serial, SerialInStock, locationID,
+		).Scan(&s.id)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return ErrDuplicateSerial
+			}
+			return err
+		}
+
+		if err := recordSerialEvent(tx, &s, "received", reference); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkDirectReceipt проверяет, что товар можно принять в обход приемки: единицы товара с серийным учетом
+// появляются на складе только вместе с серийными номерами через приемку, а у наборов нет собственного остатка
+func checkDirectReceipt(q queryer, productID int) error {
+	var serialTracked, isBundle bool
+	err := q.QueryRow("SELECT serial_tracked, is_bundle FROM products WHERE id = $1", productID).Scan(&serialTracked, &isBundle)
+	if err != nil {
+		return err
+	}
+	if isBundle {
+		return ErrInvalidBundle
+	}
+	if serialTracked {
+		return ErrSerialsMismatch
+	}
+
+	return nil
+}
+
+// reserveAnySerial закрепляет за резервом любой свободный серийный номер товара с серийным учетом
+func reserveAnySerial(tx *sql.Tx, p *Product) error {
+	if !p.SerialTracked {
+		return nil
+	}
+
+	s := serialRow{productID: p.ID, warehouseID: p.WarehouseID, status: SerialReserved}
+	err := tx.QueryRow(`SELECT id, location_id FROM serial_numbers
+		WHERE product_id = $1 AND status = $2 ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED`, p.ID, SerialInStock,
+	).Scan(&s.id, &s.locationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOutOfStock
+	}
+	if err != nil {
+		return err
+	}
+
+	return updateSerial(tx, &s)
+}
+
+// releaseAnySerial возвращает в наличие последний зарезервированный серийный номер товара
+func releaseAnySerial(tx *sql.Tx, p *Product) error {
+	if !p.SerialTracked {
+		return nil
+	}
+
+	s := serialRow{productID: p.ID, warehouseID: p.WarehouseID, status: SerialInStock}
+	err := tx.QueryRow(`SELECT id, location_id FROM serial_numbers
+		WHERE product_id = $1 AND status = $2 ORDER BY id DESC LIMIT 1 FOR UPDATE`, p.ID, SerialReserved,
+	).Scan(&s.id, &s.locationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return updateSerial(tx, &s)
+}
+
+func updateSerial(tx *sql.Tx, s *serialRow) error {
+	_, err := tx.Exec("UPDATE serial_numbers SET status = $1, location_id = $2 WHERE id = $3", s.status, s.locationID, s.id)
+	if err != nil {
+		return err
+	}
+
+	return recordSerialEvent(tx, s, s.status, "")
+}
+
+func recordSerialEvent(tx *sql.Tx, s *serialRow, event, reference string) error {
+	_, err := tx.Exec(
+		"INSERT INTO serial_events(serial_id, event, warehouse_id, location_id, reference) VALUES($1, $2, $3, $4, NULLIF($5, ''))",
+		s.id, event, s.warehouseID, s.locationID, reference,
+	)
+	return err
+}
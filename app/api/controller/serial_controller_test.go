@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/utils"
+)
+
+func createTestSerialProduct(t *testing.T, serials ...string) *Product {
+	t.Helper()
+
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	p := &Product{
+		Name:          utils.RandomString(6),
+		Size:          utils.RandomString(6),
+		Code:          utils.RandomString(10),
+		WarehouseID:   w.ID,
+		SerialTracked: true,
+	}
+	if err := CreateProduct(db, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(serials) == 0 {
+		return p
+	}
+
+	r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: len(serials), Serials: serials}}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestReserveSerialsPinsUnit(t *testing.T) {
+	db := openTestDB(t)
+	first, second := utils.RandomString(12), utils.RandomString(12)
+	p := createTestSerialProduct(t, first, second)
+
+	if err := ReserveSerials(db, []string{second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReserveSerials(db, []string{second}); !errors.Is(err, ErrSerialUnavailable) {
+		t.Errorf("Expected ErrSerialUnavailable for reserved serial, but got %v", err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 1 {
+		t.Errorf("Expected product quantity to be 1, but got %d", q)
+	}
+
+	if err := ShipSerials(db, []string{second}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := GetSerial(db, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Status != SerialShipped || len(u.History) != 3 {
+		t.Errorf("Expected shipped serial with 3 history events, got %+v", u)
+	}
+}
+
+func TestShipSerialsFromBin(t *testing.T) {
+	db := openTestDB(t)
+	p := createTestSerialProduct(t)
+	bin := createTestBin(t, db, p.WarehouseID)
+	first, second := utils.RandomString(12), utils.RandomString(12)
+
+	r := &Receipt{WarehouseID: p.WarehouseID, Lines: []ReceiptLine{{
+		Code: p.Code, Quantity: 2, LocationID: &bin.ID, Serials: []string{first, second},
+	}}}
+	if err := CreateReceipt(db, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := PostReceipt(db, r.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveSerials(db, []string{second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ShipSerials(db, []string{second}); err != nil {
+		t.Fatalf("Expected reserved serial to ship from its bin, but got %v", err)
+	}
+
+	var reserved int
+	if err := db.QueryRow("SELECT reserved FROM products WHERE id = $1", p.ID).Scan(&reserved); err != nil {
+		t.Fatal(err)
+	}
+	if q := productQuantity(t, db, p.ID); q != 1 || reserved != 0 {
+		t.Errorf("Expected 1 free and 0 reserved units, got %d and %d", q, reserved)
+	}
+
+	bins, err := getBinBalances(db, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 1 || bins[0].Quantity != 1 || bins[0].Reserved != 0 {
+		t.Errorf("Expected 1 free unit left in the bin, got %+v", bins)
+	}
+}
+
+func TestReserveProductsPicksAnySerial(t *testing.T) {
+	db := openTestDB(t)
+	serial := utils.RandomString(12)
+	p := createTestSerialProduct(t, serial)
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := GetSerial(db, serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Status != SerialReserved {
+		t.Errorf("Expected serial to be reserved, got %s", u.Status)
+	}
+
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := GetSerial(db, serial); u == nil || u.Status != SerialInStock {
+		t.Errorf("Expected serial to be back in stock, got %+v", u)
+	}
+}
+
+func TestCreateReceiptRequiresSerials(t *testing.T) {
+	db := openTestDB(t)
+	p := createTestSerialProduct(t)
+
+	r := &Receipt{WarehouseID: p.WarehouseID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 2, Serials: []string{utils.RandomString(12)}}}}
+	if err := CreateReceipt(db, r); !errors.Is(err, ErrSerialsMismatch) {
+		t.Errorf("Expected ErrSerialsMismatch, but got %v", err)
+	}
+}
+
+func TestDirectReceiptRejectsSerialProducts(t *testing.T) {
+	db := openTestDB(t)
+	p := createTestSerialProduct(t)
+	bin := createTestBin(t, db, p.WarehouseID)
+
+	if err := ReceiveToBin(db, bin.ID, p.Code, 1); !errors.Is(err, ErrSerialsMismatch) {
+		t.Errorf("Expected ErrSerialsMismatch for a bin receipt, but got %v", err)
+	}
+	if err := ReceiveLot(db, &StockLot{Code: p.Code, LotNumber: "L-1", Quantity: 1}); !errors.Is(err, ErrSerialsMismatch) {
+		t.Errorf("Expected ErrSerialsMismatch for a lot receipt, but got %v", err)
+	}
+
+	withStock := &Product{Code: utils.RandomString(10), WarehouseID: p.WarehouseID, Quantity: 3, SerialTracked: true}
+	if err := CreateProduct(db, withStock); !errors.Is(err, ErrSerialsMismatch) {
+		t.Errorf("Expected ErrSerialsMismatch for initial stock, but got %v", err)
+	}
+
+	if q := productQuantity(t, db, p.ID); q != 0 {
+		t.Errorf("Expected no stock without serials, got %d", q)
+	}
+}
@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AlertLowStock   = "low_stock"
+	AlertOutOfStock = "out_of_stock"
+)
+
+// События об оповещениях по остаткам
+const (
+	EventStockLow           = "stock.low"
+	EventStockOut           = "stock.out"
+	EventStockAlertResolved = "stock.alert_resolved"
+)
+
+// StockThresholds — точка заказа и страховой запас. У товара пустое значение означает
+// значение склада по умолчанию, у склада — отсутствие порога.
+type StockThresholds struct {
+	ReorderPoint *int `json:"reorder_point"`
+	SafetyStock  *int `json:"safety_stock"`
+}
+
+// StockAlert — оповещение о низком или нулевом остатке. Открытое оповещение у товара одно;
+// оно закрывается, когда остаток восстанавливается или меняется уровень.
+type StockAlert struct {
+	ID           int64      `json:"id"`
+	ProductID    int        `json:"product_id"`
+	Code         string     `json:"code"`
+	WarehouseID  int        `json:"warehouse_id"`
+	Level        string     `json:"level"`
+	Quantity     int        `json:"quantity"`
+	ReorderPoint *int       `json:"reorder_point,omitempty"`
+	SafetyStock  *int       `json:"safety_stock,omitempty"`
+	RaisedAt     time.Time  `json:"raised_at"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+}
+
+// StockAlertEvent — тело событий stock.low, stock.out и stock.alert_resolved
+type StockAlertEvent struct {
+	AlertID      int64  `json:"alert_id"`
+	ProductID    int    `json:"product_id"`
+	Code         string `json:"code"`
+	WarehouseID  int    `json:"warehouse_id"`
+	Level        string `json:"level"`
+	Quantity     int    `json:"quantity"`
+	ReorderPoint *int   `json:"reorder_point,omitempty"`
+	SafetyStock  *int   `json:"safety_stock,omitempty"`
+}
+
+func (t StockThresholds) validate() error {
+	if (t.ReorderPoint != nil && *t.ReorderPoint < 0) || (t.SafetyStock != nil && *t.SafetyStock < 0) {
+		return ErrInvalidThreshold
+	}
+	return nil
+}
+
+//	@Summary		Set product stock thresholds.
+//	@Description	Set the reorder point and safety stock of a product. Null values fall back to the warehouse defaults. Alerts are re-evaluated immediately.
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			code		path		string			true	"Product code or barcode"
+//	@Param			thresholds	body		StockThresholds	true	"Thresholds"
+//	@Success		200			{object}	StockThresholds
+//	@Failure		400			{object}	ErrorResponse	"Invalid thresholds"
+//	@Failure		404			{object}	ErrorResponse	"Product not found"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/products/{code}/thresholds [put]
+//
+func SetProductThresholds(db *sql.DB, code string, t StockThresholds) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	productCode, err := resolveCode(tx, code)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = tx.QueryRow("UPDATE products SET reorder_point = $1, safety_stock = $2 WHERE code = $3 RETURNING id",
+		t.ReorderPoint, t.SafetyStock, productCode).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if err := evaluateStockAlerts(tx, []int{id}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Set warehouse default stock thresholds.
+//	@Description	Set the default reorder point and safety stock for products of a warehouse without their own thresholds. Alerts of the warehouse are re-evaluated immediately.
+//	@Tags			alerts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Warehouse ID"
+//	@Param			thresholds	body		StockThresholds	true	"Thresholds"
+//	@Success		200			{object}	StockThresholds
+//	@Failure		400			{object}	ErrorResponse	"Invalid thresholds"
+//	@Failure		404			{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id}/thresholds [put]
+//
+func SetWarehouseThresholds(db *sql.DB, warehouseID int, t StockThresholds) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE warehouse SET default_reorder_point = $1, default_safety_stock = $2 WHERE id = $3",
+		t.ReorderPoint, t.SafetyStock, warehouseID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	productIDs, err := queryIDs(tx, "SELECT id FROM products WHERE warehouse_id = $1 ORDER BY id FOR UPDATE", warehouseID)
+	if err != nil {
+		return err
+	}
+
+	if err := evaluateStockAlerts(tx, productIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// evaluateStockAlerts сверяет доступные остатки товаров без просроченных партий с порогами
+// и открывает, меняет или закрывает оповещения.
+// Вызывается в транзакции, изменившей остаток или пороги. Товары блокируются до чтения открытых
+// оповещений, чтобы параллельные транзакции не открыли по товару два оповещения.
+func evaluateStockAlerts(q queryer, productIDs []int) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	if _, err := q.Exec("SELECT id FROM products WHERE id = ANY($1) ORDER BY id FOR UPDATE", pq.Array(productIDs)); err != nil {
+		return err
+	}
+
+	rows, err := q.Query(`SELECT p.id, p.code, COALESCE(p.warehouse_id, 0), `+availableSQL+`,
+			COALESCE(p.reorder_point, w.default_reorder_point), COALESCE(p.safety_stock, w.default_safety_stock),
+			a.id, a.level
+		FROM products p
+		LEFT JOIN warehouse w ON w.id = p.warehouse_id
+		LEFT JOIN stock_alerts a ON a.product_id = p.id AND a.resolved_at IS NULL
+		WHERE p.id = ANY($1) AND NOT p.is_bundle
+		ORDER BY p.id`, pq.Array(productIDs))
+	if err != nil {
+		return err
+	}
+
+	type evaluation struct {
+		event     StockAlertEvent
+		openID    sql.NullInt64
+		openLevel sql.NullString
+	}
+	var evaluations []evaluation
+	for rows.Next() {
+		var e evaluation
+		var reorderPoint, safetyStock sql.NullInt64
+		if err := rows.Scan(&e.event.ProductID, &e.event.Code, &e.event.WarehouseID, &e.event.Quantity,
+			&reorderPoint, &safetyStock, &e.openID, &e.openLevel); err != nil {
+			rows.Close()
+			return err
+		}
+		e.event.ReorderPoint = nullIntPtr(reorderPoint)
+		e.event.SafetyStock = nullIntPtr(safetyStock)
+		e.event.Level = stockAlertLevel(e.event.Quantity, e.event.ReorderPoint, e.event.SafetyStock)
+		evaluations = append(evaluations, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range evaluations {
+		if e.openLevel.String == e.event.Level {
+			continue
+		}
+
+		if e.openID.Valid {
+			if _, err := q.Exec("UPDATE stock_alerts SET resolved_at = now() WHERE id = $1", e.openID.Int64); err != nil {
+				return err
+			}
+			resolved := e.event
+			resolved.AlertID, resolved.Level = e.openID.Int64, e.openLevel.String
+			if err := enqueueEvent(q, EventStockAlertResolved, productEventKey(resolved.ProductID), resolved); err != nil {
+				return err
+			}
+		}
+
+		if e.event.Level == "" {
+			continue
+		}
+
+		raised := e.event
+		// оповещение, уже открытое другой транзакцией, не открывается повторно
+		err := q.QueryRow(`INSERT INTO stock_alerts(product_id, level, quantity, reorder_point, safety_stock)
+			VALUES($1, $2, $3, $4, $5)
+			ON CONFLICT (product_id) WHERE resolved_at IS NULL DO NOTHING RETURNING id`,
+			raised.ProductID, raised.Level, raised.Quantity, raised.ReorderPoint, raised.SafetyStock,
+		).Scan(&raised.AlertID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		eventType := EventStockLow
+		if raised.Level == AlertOutOfStock {
+			eventType = EventStockOut
+		}
+		if err := enqueueEvent(q, eventType, productEventKey(raised.ProductID), raised); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stockAlertLevel возвращает уровень оповещения для остатка: нулевой остаток — всегда out_of_stock,
+// низкий — не выше точки заказа или ниже страхового запаса; пустая строка — оповещение не нужно
+func stockAlertLevel(quantity int, reorderPoint, safetyStock *int) string {
+	switch {
+	case quantity <= 0:
+		return AlertOutOfStock
+	case reorderPoint != nil && quantity <= *reorderPoint,
+		safetyStock != nil && quantity < *safetyStock:
+		return AlertLowStock
+	}
+
+	return ""
+}
+
+// queryIDs возвращает идентификаторы из первого столбца запроса
+func queryIDs(q queryer, query string, args ...interface{}) ([]int, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+//	@Summary		List stock alerts.
+//	@Description	List open low-stock and out-of-stock alerts, newest first. Resolved alerts are included with include_resolved=true.
+//	@Tags			alerts
+//	@Produce		json
+//	@Param			warehouse_id		query		int				false	"Warehouse ID"
+//	@Param			level				query		string			false	"low_stock or out_of_stock"
+//	@Param			include_resolved	query		bool			false	"Include resolved alerts"
+//	@Success		200					{array}		StockAlert
+//	@Failure		400					{object}	ErrorResponse	"Invalid level"
+//	@Failure		500					{object}	ErrorResponse	"Internal server error"
+//	@Router			/stock-alerts [get]
+//
+func GetStockAlerts(db *sql.DB, warehouseID int, level string, includeResolved bool) ([]StockAlert, error) {
+	if level != "" && level != AlertLowStock && level != AlertOutOfStock {
+		return nil, ErrInvalidThreshold
+	}
+
+	rows, err := db.Query(`SELECT a.id, a.product_id, p.code, COALESCE(p.warehouse_id, 0), a.level, a.quantity,
+			a.reorder_point, a.safety_stock, a.raised_at, a.resolved_at
+		FROM stock_alerts a JOIN products p ON p.id = a.product_id
+		WHERE ($1 = 0 OR p.warehouse_id = $1) AND ($2 = '' OR a.level = $2) AND ($3 OR a.resolved_at IS NULL)
+		ORDER BY a.id DESC`, warehouseID, level, includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []StockAlert{}
+	for rows.Next() {
+		var a StockAlert
+		var reorderPoint, safetyStock sql.NullInt64
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.ProductID, &a.Code, &a.WarehouseID, &a.Level, &a.Quantity,
+			&reorderPoint, &safetyStock, &a.RaisedAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+		a.ReorderPoint = nullIntPtr(reorderPoint)
+		a.SafetyStock = nullIntPtr(safetyStock)
+		if resolvedAt.Valid {
+			a.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, a)
+	}
+
+	return alerts, rows.Err()
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestStockAlertLevel(t *testing.T) {
+	cases := []struct {
+		quantity     int
+		reorderPoint *int
+		safetyStock  *int
+		want         string
+	}{
+		{0, nil, nil, AlertOutOfStock},
+		{5, nil, nil, ""},
+		{5, intPtr(5), nil, AlertLowStock},
+		{6, intPtr(5), nil, ""},
+		{2, nil, intPtr(3), AlertLowStock},
+		{3, nil, intPtr(3), ""},
+	}
+	for _, c := range cases {
+		if got := stockAlertLevel(c.quantity, c.reorderPoint, c.safetyStock); got != c.want {
+			t.Errorf("stockAlertLevel(%d) = %q, want %q", c.quantity, got, c.want)
+		}
+	}
+}
+
+func TestStockAlertsFollowReservations(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 3)
+
+	if err := SetWarehouseThresholds(db, w.ID, StockThresholds{ReorderPoint: intPtr(2)}); err != nil {
+		t.Fatal(err)
+	}
+	assertOpenAlert := func(want string) {
+		t.Helper()
+		alerts, err := GetStockAlerts(db, w.ID, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if len(alerts) == 1 {
+			got = alerts[0].Level
+		} else if len(alerts) > 1 {
+			t.Fatalf("Expected at most one open alert, got %d", len(alerts))
+		}
+		if got != want {
+			t.Errorf("Expected open alert %q, got %q", want, got)
+		}
+	}
+
+	assertOpenAlert("")
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	assertOpenAlert(AlertLowStock)
+
+	if err := ReserveProducts(db, []string{p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	assertOpenAlert(AlertOutOfStock)
+
+	if err := ReleaseProducts(db, []string{p.Code, p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	assertOpenAlert("")
+
+	// порог товара важнее порога склада
+	if err := SetProductThresholds(db, p.Code, StockThresholds{ReorderPoint: intPtr(10)}); err != nil {
+		t.Fatal(err)
+	}
+	assertOpenAlert(AlertLowStock)
+
+	history, err := GetStockAlerts(db, w.ID, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Errorf("Expected 3 alerts in the history, got %d", len(history))
+	}
+}
+
+func TestStockAlertsIgnoreExpiredLots(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	if err := SetProductThresholds(db, p.Code, StockThresholds{ReorderPoint: intPtr(2)}); err != nil {
+		t.Fatal(err)
+	}
+	lot := &StockLot{Code: p.Code, LotNumber: "L-OLD", ExpiryDate: time.Now().AddDate(0, 0, -1).Format(dateLayout), Quantity: 3}
+	if err := ReceiveLot(db, lot); err != nil {
+		t.Fatal(err)
+	}
+
+	// просроченная партия лежит на складе, но зарезервировать ее нельзя
+	alerts, err := GetStockAlerts(db, w.ID, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Level != AlertOutOfStock {
+		t.Errorf("Expected an open out_of_stock alert for expired stock, got %+v", alerts)
+	}
+}
+
+func TestStockAlertsUnderConcurrentChanges(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 10)
+
+	// пороги и резервы меняются параллельно, но по товару открыто не больше одного оповещения
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- SetWarehouseThresholds(db, w.ID, StockThresholds{ReorderPoint: intPtr(i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			errs <- ReserveProducts(db, []string{p.Code})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts, err := GetStockAlerts(db, w.ID, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Level != AlertOutOfStock {
+		t.Errorf("Expected one out-of-stock alert, got %+v", alerts)
+	}
+}
+
+func TestSetThresholdsValidates(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	if err := SetWarehouseThresholds(db, w.ID, StockThresholds{SafetyStock: intPtr(-1)}); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("Expected ErrInvalidThreshold, got %v", err)
+	}
+	if err := SetWarehouseThresholds(db, -1, StockThresholds{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"database/sql"
+	"time"
+)
+
+// StockMovement описывает одно изменение остатка товара
+type StockMovement struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	QuantityDelta int       `json:"quantity_delta"`
+	Reason        string    `json:"reason"`
+	Reference     string    `json:"reference,omitempty"`
+	UnitCost      *float64  `json:"unit_cost,omitempty"`
+	ChannelID     *int      `json:"channel_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	MovementCycleCount = "cycle_count"
+	MovementReceipt    = "receipt"
+	MovementImport     = "import"
+	MovementReserve    = "reserve"
+	MovementRelease    = "release"
+	MovementInitial    = "initial"
+	MovementDelete     = "delete"
+)
+
+// queryer позволяет выполнять запросы как в транзакции, так и вне ее
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordMovement записывает изменение остатка в журнал движений и событие об изменении в outbox,
+// приводит к нему остатки ячеек, пересчитывает оповещения о низком остатке и отдает прибывший
+// остаток листу ожидания. Вызывается последним шагом изменения остатка в той же транзакции,
+// когда партии, ячейки и серийные номера уже приведены в соответствие.
+func recordMovement(tx *sql.Tx, productID, delta int, reason, reference string) error {
+	return recordCostedMovement(tx, productID, delta, reason, reference, nil)
+}
+
+// recordCostedMovement записывает движение с себестоимостью единицы, например поступление по цене из поставки
+func recordCostedMovement(tx *sql.Tx, productID, delta int, reason, reference string, unitCost *float64) error {
+	return insertMovement(tx, productID, delta, reason, reference, unitCost, 0)
+}
+
+// recordChannelMovement записывает резерв или возврат единицы с каналом продаж, от имени которого он сделан
+func recordChannelMovement(tx *sql.Tx, productID, delta int, reason, reference string, channelID int) error {
+	return insertMovement(tx, productID, delta, reason, reference, nil, channelID)
+}
+
+func insertMovement(tx *sql.Tx, productID, delta int, reason, reference string, unitCost *float64, channelID int) error {
+	_, err := tx.Exec(
+		"INSERT INTO stock_movements(product_id, quantity_delta, reason, reference, unit_cost, channel_id) VALUES($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, 0))",
+		productID, delta, reason, reference, unitCost, channelID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueStockChanged(tx, productID, delta, reason, reference); err != nil {
+		return err
+	}
+
+	if err := syncBinStock(tx, productID, delta, reason); err != nil {
+		return err
+	}
+
+	if err := evaluateStockAlerts(tx, []int{productID}); err != nil {
+		return err
+	}
+
+	if delta <= 0 {
+		return nil
+	}
+	return fulfillBackorders(tx, []int{productID})
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+	"github.com/lib/pq"
+)
+
+// StockStreamReplayBatch — сколько пропущенных событий читается за один запрос при возобновлении потока
+const StockStreamReplayBatch = 1000
+
+// StockStreamFilter отбирает события stock.changed по складу и кодам товаров.
+// Нулевой WarehouseID и пустой Codes означают все склады и все товары.
+type StockStreamFilter struct {
+	WarehouseID int
+	Codes       map[string]bool
+}
+
+// NewStockStreamFilter проверяет склад и приводит коды и штрихкоды к основным кодам товаров
+func NewStockStreamFilter(db *sql.DB, warehouseID int, codes []string) (*StockStreamFilter, error) {
+	f := &StockStreamFilter{WarehouseID: warehouseID}
+	if warehouseID != 0 {
+		if err := WarehouseExists(db, warehouseID); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(codes) > 0 {
+		f.Codes = make(map[string]bool, len(codes))
+		for _, code := range codes {
+			resolved, err := resolveCode(db, code)
+			if err != nil {
+				return nil, err
+			}
+			f.Codes[resolved] = true
+		}
+	}
+
+	return f, nil
+}
+
+// Match сообщает, относится ли событие к потоку
+func (f *StockStreamFilter) Match(e events.Event) bool {
+	if e.Type != EventStockChanged {
+		return false
+	}
+
+	var sc StockChangedEvent
+	if err := json.Unmarshal(e.Payload, &sc); err != nil {
+		return false
+	}
+	if f.WarehouseID != 0 && sc.WarehouseID != f.WarehouseID {
+		return false
+	}
+	if len(f.Codes) > 0 && !f.Codes[sc.Code] {
+		return false
+	}
+
+	return true
+}
+
+// GetStockEventsSince возвращает до StockStreamReplayBatch опубликованных событий потока с позицией
+// больше lastID в порядке публикации. ID событий — их позиции в потоке: позиция назначается при публикации
+// и растет в порядке, в котором события становятся видны, поэтому поздно зафиксированное событие не теряется.
+func GetStockEventsSince(db *sql.DB, lastID int64, f *StockStreamFilter) ([]events.Event, error) {
+	var codes []string
+	for code := range f.Codes {
+		codes = append(codes, code)
+	}
+
+	rows, err := db.Query(`SELECT stream_position, event_type, aggregate_key, payload, created_at FROM outbox_events
+		WHERE stream_position > $1 AND event_type = $2
+			AND ($3 = 0 OR (payload->>'warehouse_id')::INTEGER = $3)
+			AND (cardinality($4::TEXT[]) = 0 OR payload->>'code' = ANY($4))
+		ORDER BY stream_position LIMIT $5`, lastID, EventStockChanged, f.WarehouseID, pq.Array(codes), StockStreamReplayBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var replay []events.Event
+	for rows.Next() {
+		var e events.Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Key, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		replay = append(replay, e)
+	}
+
+	return replay, rows.Err()
+}
+
+// GetStockStreamPosition возвращает позицию последнего опубликованного события,
+// с которой начинается поток для нового клиента
+func GetStockStreamPosition(db *sql.DB) (int64, error) {
+	var position int64
+	err := db.QueryRow("SELECT COALESCE(MAX(stream_position), 0) FROM outbox_events").Scan(&position)
+	return position, err
+}
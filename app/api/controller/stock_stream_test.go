@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+)
+
+func TestGetStockEventsSinceFiltersAndResumes(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 3)
+	other := createTestProduct(t, db, w.ID, 3)
+
+	if err := ReserveProducts(db, []string{p.Code, other.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	relayAll(t, events.PublisherFunc(func(context.Context, events.Event) error { return nil }))
+
+	filter, err := NewStockStreamFilter(db, w.ID, []string{p.Code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own, err := GetStockEventsSince(db, 0, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range own {
+		if !filter.Match(e) {
+			t.Errorf("Event %d does not match the filter", e.ID)
+		}
+	}
+	// начальный остаток и два резерва
+	if len(own) != 3 {
+		t.Fatalf("Expected 3 events for the product, got %d", len(own))
+	}
+
+	resumed, err := GetStockEventsSince(db, own[0].ID, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed) != 2 || resumed[0].ID != own[1].ID {
+		t.Errorf("Expected to resume after event %d, got %v", own[0].ID, resumed)
+	}
+}
+
+func TestNewStockStreamFilterChecksInput(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := NewStockStreamFilter(db, -1, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown warehouse, got %v", err)
+	}
+	if _, err := NewStockStreamFilter(db, 0, []string{"UNKNOWN-CODE-404"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown code, got %v", err)
+	}
+}
+
+func TestGetStockEventsSinceKeepsLateCommits(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+	relayAll(t, events.PublisherFunc(func(context.Context, events.Event) error { return nil }))
+
+	filter, err := NewStockStreamFilter(db, w.ID, []string{p.Code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := `{"warehouse_id": ` + strconv.Itoa(w.ID) + `, "code": "` + p.Code + `"}`
+
+	// событие с меньшим ID фиксируется позже события с большим ID
+	late, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Rollback()
+	if _, err := late.Exec("INSERT INTO outbox_events(event_type, aggregate_key, payload) VALUES($1, $2, $3)",
+		EventStockChanged, productEventKey(p.ID), payload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO outbox_events(event_type, aggregate_key, payload) VALUES($1, $2, $3)",
+		EventStockChanged, productEventKey(p.ID), payload); err != nil {
+		t.Fatal(err)
+	}
+	relayAll(t, events.PublisherFunc(func(context.Context, events.Event) error { return nil }))
+
+	seen, err := GetStockEventsSince(db, 0, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Expected the initial and the committed event, got %d", len(seen))
+	}
+
+	if err := late.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	relayAll(t, events.PublisherFunc(func(context.Context, events.Event) error { return nil }))
+
+	resumed, err := GetStockEventsSince(db, seen[len(seen)-1].ID, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed) != 1 {
+		t.Errorf("Expected the late event after the last seen position, got %v", resumed)
+	}
+}
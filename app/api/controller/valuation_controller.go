@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/valuation"
+)
+
+// ValuationOptions — параметры оценки запасов. AsOf — дата в формате YYYY-MM-DD,
+// движения учитываются по конец этого дня; пустая дата — сегодня.
+type ValuationOptions struct {
+	WarehouseID int
+	AsOf        string
+	Method      string
+}
+
+// InventoryValuation — оценка запасов на дату
+type InventoryValuation struct {
+	WarehouseID   int             `json:"warehouse_id,omitempty"`
+	AsOf          string          `json:"as_of"`
+	Method        string          `json:"method"`
+	TotalQuantity int             `json:"total_quantity"`
+	TotalValue    float64         `json:"total_value"`
+	Lines         []ValuationLine `json:"lines"`
+}
+
+// ValuationLine — оценка остатка товара
+type ValuationLine struct {
+	ProductID   int     `json:"product_id"`
+	Code        string  `json:"code"`
+	Name        string  `json:"name"`
+	WarehouseID int     `json:"warehouse_id"`
+	Quantity    int     `json:"quantity"`
+	UnitCost    float64 `json:"unit_cost"`
+	Value       float64 `json:"value"`
+}
+
+//	@Summary		Inventory valuation.
+//	@Description	Value stock on hand as of the end of a date from the movement history, using FIFO layers (method=fifo) or the moving weighted average cost (method=wac). Costs come from receipt lines; stock received without a cost, such as opening stock and count gains, is valued at the current cost of the product. Reserved units stay on hand until they are picked: reservations and releases do not change the valuation, and picked units are issued from the oldest stock.
+//	@Tags			reports
+//	@Produce		json
+//	@Param			warehouse_id	query		int				false	"Warehouse ID"
+//	@Param			as_of			query		string			false	"Date in YYYY-MM-DD format, today by default"
+//	@Param			method			query		string			false	"fifo or wac"	default(fifo)
+//	@Success		200				{object}	InventoryValuation
+//	@Failure		400				{object}	ErrorResponse	"Invalid parameters"
+//	@Failure		500				{object}	ErrorResponse	"Internal server error"
+//	@Router			/reports/valuation [get]
+//
+func GetInventoryValuation(db *sql.DB, opts ValuationOptions) (*InventoryValuation, error) {
+	if opts.Method == "" {
+		opts.Method = valuation.MethodFIFO
+	}
+	if opts.Method != valuation.MethodFIFO && opts.Method != valuation.MethodWeightedAverage {
+		return nil, ErrInvalidReport
+	}
+	if opts.AsOf == "" {
+		opts.AsOf = time.Now().Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, opts.AsOf); err != nil {
+		return nil, ErrInvalidReport
+	}
+
+	rows, err := db.Query(`SELECT p.id, p.code, COALESCE(p.name, ''), p.warehouse_id, p.reserved, m.reason, m.quantity_delta, m.unit_cost
+		FROM stock_movements m JOIN products p ON p.id = m.product_id
+		WHERE NOT p.is_bundle AND ($1 = 0 OR p.warehouse_id = $1) AND m.created_at < $2::DATE + 1
+		ORDER BY p.warehouse_id, p.code, m.id`, opts.WarehouseID, opts.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &InventoryValuation{WarehouseID: opts.WarehouseID, AsOf: opts.AsOf, Method: opts.Method, Lines: []ValuationLine{}}
+	var line ValuationLine
+	var movements []valuation.Movement
+	// резерв и возврат резерва только переводят единицы между свободным и зарезервированным
+	// остатком, а отбор движений не пишет: отобранные единицы — это зарезервированные к дате,
+	// которых уже нет в текущем резерве, и они списываются в конце журнала
+	var reserved, reservedNet int
+	flush := func() error {
+		if movements == nil {
+			return nil
+		}
+		if picked := reservedNet - reserved; picked > 0 {
+			movements = append(movements, valuation.Movement{Delta: -picked})
+		}
+		r, err := valuation.Value(opts.Method, movements)
+		if err != nil {
+			return err
+		}
+		line.Quantity, line.UnitCost, line.Value = r.Quantity, r.UnitCost, r.Value
+		if line.Quantity > 0 {
+			report.Lines = append(report.Lines, line)
+			report.TotalQuantity += line.Quantity
+			report.TotalValue += line.Value
+		}
+		movements = nil
+		return nil
+	}
+
+	for rows.Next() {
+		var l ValuationLine
+		var m valuation.Movement
+		var productReserved int
+		var reason string
+		if err := rows.Scan(&l.ProductID, &l.Code, &l.Name, &l.WarehouseID, &productReserved, &reason, &m.Delta, &m.UnitCost); err != nil {
+			return nil, err
+		}
+		if l.ProductID != line.ProductID {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			line = l
+			reserved, reservedNet = productReserved, 0
+			movements = []valuation.Movement{}
+		}
+		if reason == MovementReserve || reason == MovementRelease {
+			reservedNet -= m.Delta
+			continue
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	report.TotalValue = math.Round(report.TotalValue*1e4) / 1e4
+
+	return report, nil
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/valuation"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestInventoryValuation(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+	bin := createTestBin(t, db, w.ID)
+
+	for _, unitCost := range []float64{10, 20} {
+		r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: 2, LocationID: &bin.ID, UnitCost: floatPtr(unitCost)}}}
+		if err := CreateReceipt(db, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := PostReceipt(db, r.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ReserveProducts(db, []string{p.Code, p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PickFromBin(db, bin.ID, p.Code, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]float64{
+		valuation.MethodFIFO:            20,
+		valuation.MethodWeightedAverage: 15,
+	}
+	for method, want := range cases {
+		report, err := GetInventoryValuation(db, ValuationOptions{WarehouseID: w.ID, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Lines) != 1 || report.Lines[0].Quantity != 1 || report.TotalValue != want {
+			t.Errorf("Expected %s valuation of 1 unit worth %v, got %+v", method, want, report)
+		}
+	}
+
+	report, err := GetInventoryValuation(db, ValuationOptions{WarehouseID: w.ID, AsOf: "2000-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Lines) != 0 || report.TotalValue != 0 {
+		t.Errorf("Expected empty valuation before any movements, got %+v", report)
+	}
+}
+
+func TestInventoryValuationKeepsReservedUnits(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 0)
+
+	receive := func(quantity int, unitCost float64) {
+		t.Helper()
+		r := &Receipt{WarehouseID: w.ID, Lines: []ReceiptLine{{Code: p.Code, Quantity: quantity, UnitCost: floatPtr(unitCost)}}}
+		if err := CreateReceipt(db, r); err != nil {
+			t.Fatal(err)
+		}
+		if err := PostReceipt(db, r.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	receive(2, 10)
+	receive(2, 20)
+	if err := ReserveProducts(db, []string{p.Code, p.Code, p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	receive(1, 30)
+
+	// зарезервированные единицы не отобраны и остаются в своих слоях: 10 + 10 + 20 + 20 + 30
+	report, err := GetInventoryValuation(db, ValuationOptions{WarehouseID: w.ID, Method: valuation.MethodFIFO})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Lines) != 1 || report.Lines[0].Quantity != 5 || report.TotalValue != 90 {
+		t.Errorf("Expected fifo valuation of 5 units worth 90, got %+v", report)
+	}
+}
+
+func TestInventoryValuationValidation(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := GetInventoryValuation(db, ValuationOptions{Method: "lifo"}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport for unknown method, got %v", err)
+	}
+	if _, err := GetInventoryValuation(db, ValuationOptions{AsOf: "01.01.2024"}); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport for invalid date, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateWarehouseChecksVersion(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	if w.Version != 1 {
+		t.Fatalf("Expected new warehouse to have version 1, got %d", w.Version)
+	}
+
+	update := &Warehouse{ID: w.ID, Name: w.Name + "-renamed", IsAvailable: false}
+	if err := UpdateWarehouse(db, update, []int{w.Version}); err != nil {
+		t.Fatal(err)
+	}
+	if update.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", update.Version)
+	}
+
+	if err := UpdateWarehouse(db, &Warehouse{ID: w.ID, Name: "stale"}, []int{w.Version}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for a stale version, but got %v", err)
+	}
+	if err := UpdateWarehouse(db, &Warehouse{ID: -1}, []int{1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, but got %v", err)
+	}
+}
+
+func TestProductVersionChangesWithStock(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 2)
+
+	etag, err := GetRemainingProductsETag(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := GetRemainingProductsETag(db, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == etag {
+		t.Error("Expected remaining products ETag to change after a reservation")
+	}
+
+	if err := DeleteProduct(db, p.ID, []int{p.Version}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch when deleting a stale version, but got %v", err)
+	}
+	if err := DeleteProduct(db, p.ID, []int{p.Version, p.Version + 1}); err != nil {
+		t.Errorf("Expected delete with the current version to succeed, but got %v", err)
+	}
+}
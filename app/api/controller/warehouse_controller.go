@@ -8,25 +8,41 @@ package controller
 import (
 	"database/sql"
 	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type Product struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Size        string `json:"size"`
-	Code        string `json:"code"`
-	Quantity    int    `json:"quantity"`
-	WarehouseID int    `json:"warehouse_id"`
+	ID            int          `json:"id"`
+	Name          string       `json:"name"`
+	Size          string       `json:"size"`
+	Code          string       `json:"code"`
+	Quantity      int          `json:"quantity"`
+	WarehouseID   int          `json:"warehouse_id"`
+	SerialTracked bool         `json:"serial_tracked"`
+	IsBundle      bool         `json:"is_bundle"`
+	Version       int          `json:"version"`
+	Bins          []BinBalance `json:"bins,omitempty"`
 }
 
 type Warehouse struct {
-	ID          int    `json:"id" db:"id"`
-	Name        string `json:"name" db:"name"`
-	IsAvailable bool   `json:"is_available" db:"is_available"`
+	ID           int                    `json:"id" db:"id"`
+	Name         string                 `json:"name" db:"name"`
+	IsAvailable  bool                   `json:"is_available" db:"is_available"`
+	Version      int                    `json:"version" db:"version"`
+	Address      *string                `json:"address,omitempty" db:"address"`
+	Latitude     *float64               `json:"latitude,omitempty" db:"latitude"`
+	Longitude    *float64               `json:"longitude,omitempty" db:"longitude"`
+	RegionID     *int                   `json:"region_id,omitempty" db:"region_id"`
+	Capacity     *WarehouseCapacity     `json:"capacity,omitempty" db:"-"`
+	Utilization  *WarehouseUtilization  `json:"utilization,omitempty" db:"-"`
+	Availability *WarehouseAvailability `json:"availability,omitempty" db:"-"`
 }
 
 //	@Summary		Create a new warehouse.
-//	@Description	Create a new warehouse in the database.
+//	@Description	Create a new warehouse in the database, optionally with its address and coordinates.
 //	@Tags			warehouses
 //	@Accept			json
 //	@Produce		json
@@ -37,80 +53,300 @@ type Warehouse struct {
 //	@Router			/create-warehouse [post]
 //
 func CreateWarehouse(db *sql.DB, w *Warehouse) error {
-	stmt, err := db.Prepare("INSERT INTO warehouse(name, is_available) VALUES($1, $2) RETURNING id")
+	location := WarehouseLocation{Address: w.Address, Latitude: w.Latitude, Longitude: w.Longitude}
+	if err := location.validate(); err != nil {
+		return err
+	}
+
+	stmt, err := db.Prepare("INSERT INTO warehouse(name, is_available, address, latitude, longitude) VALUES($1, $2, $3, $4, $5) RETURNING id, version")
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRow(w.Name, w.IsAvailable).Scan(&w.ID)
+	err = stmt.QueryRow(w.Name, w.IsAvailable, w.Address, w.Latitude, w.Longitude).Scan(&w.ID, &w.Version)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//	@Summary		Get a warehouse.
+//	@Description	Get a warehouse by its ID with its location, region, capacity, current utilization and availability computed from its calendar.
+//	@Tags			warehouses
+//	@Produce		json
+//	@Param			id	path		int				true	"Warehouse ID"
+//	@Success		200	{object}	Warehouse
+//	@Failure		404	{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/warehouses/{id} [get]
+//
+func GetWarehouse(db *sql.DB, id int) (*Warehouse, error) {
+	var w Warehouse
+	var name sql.NullString
+	var isAvailable sql.NullBool
+	var regionID sql.NullInt64
+	var calendarClosed bool
+	err := db.QueryRow("SELECT id, name, is_available, version, address, latitude, longitude, region_id, calendar_closed FROM warehouse WHERE id = $1", id).
+		Scan(&w.ID, &name, &isAvailable, &w.Version, &w.Address, &w.Latitude, &w.Longitude, &regionID, &calendarClosed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
+		return nil, err
+	}
+	w.Name, w.IsAvailable = name.String, isAvailable.Bool
+	w.RegionID = nullIntPtr(regionID)
+
+	if w.Capacity, err = warehouseCapacity(db, id, false); err != nil {
+		return nil, err
+	}
+	if w.Utilization, err = warehouseUtilization(db, id, w.Capacity); err != nil {
+		return nil, err
+	}
+	if w.Availability, err = warehouseAvailability(db, id, w.IsAvailable, calendarClosed, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// WarehouseExists возвращает ErrNotFound, если склада нет. В отличие от GetWarehouse не считает
+// заполненность и доступность склада.
+func WarehouseExists(db *sql.DB, id int) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM warehouse WHERE id = $1)", id).Scan(&exists); err != nil {
 		return err
 	}
+	if !exists {
+		return ErrNotFound
+	}
 
 	return nil
 }
 
+//	@Summary		Update a warehouse.
+//	@Description	Update the name and availability of a warehouse. With If-Match the update is applied only to one of the given versions.
+//	@Tags			warehouses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int				true	"Warehouse ID"
+//	@Param			If-Match	header		string			false	"Expected warehouse ETags, comma-separated"
+//	@Param			warehouse	body		Warehouse		true	"Warehouse information"
+//	@Success		200			{object}	Warehouse
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404			{object}	ErrorResponse	"Warehouse not found"
+//	@Failure		412			{object}	ErrorResponse	"Warehouse version does not match"
+//	@Router			/warehouses/{id} [put]
+//
+func UpdateWarehouse(db *sql.DB, w *Warehouse, versions []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasAvailable sql.NullBool
+	// ручное изменение доступности снимает с планировщика право включить склад при открытии
+	err = tx.QueryRow(`WITH old AS (SELECT id, is_available FROM warehouse WHERE id = $3 FOR UPDATE)
+		UPDATE warehouse w SET name = $1, is_available = $2,
+			calendar_closed = w.calendar_closed AND old.is_available IS NOT DISTINCT FROM $2 FROM old
+		WHERE w.id = old.id AND ($4::INTEGER[] IS NULL OR w.version = ANY($4))
+		RETURNING w.version, old.is_available`, w.Name, w.IsAvailable, w.ID, pq.Array(versions),
+	).Scan(&w.Version, &wasAvailable)
+	if errors.Is(err, sql.ErrNoRows) {
+		return versionConflict(tx, "warehouse", "id", w.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if wasAvailable.Bool != w.IsAvailable {
+		if err := enqueueWarehouseStatus(tx, w, ""); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// versionConflict различает отсутствующую строку и несовпадение версии после неудачного условного изменения
+func versionConflict(q queryer, table, column string, value interface{}) error {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE "+column+" = $1)", value).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	return ErrVersionMismatch
+}
+
 //	@Summary		Create a new product.
-//	@Description	Create a new product on a specified warehouse.
+//	@Description	Create a new product on a specified warehouse. The code is trimmed, upper-cased and validated as EAN-13, UPC-A, GTIN-14 or an internal SKU.
+//	@Description	Serial-tracked products are created without stock and received through receipts with serial numbers.
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
 //	@Param			product	body		Product			true	"Product information"
 //	@Success		200		{string}	string			"Product created"
 //	@Failure		400		{object}	ErrorResponse	"Invalid request format"
+//	@Failure		409		{object}	ErrorResponse	"Code is already used by a product or barcode, or warehouse capacity exceeded"
 //	@Failure		500		{object}	ErrorResponse	"Internal server error"
 //	@Router			/create-product [post]
 //
 func CreateProduct(db *sql.DB, p *Product) error {
-	stmt, err := db.Prepare("INSERT INTO products(name, size, code, quantity, warehouse_id) VALUES($1, $2, $3, $4, $5) RETURNING id")
+	code, _, err := validateCode(p.Code)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	p.Code = code
 
-	err = stmt.QueryRow(p.Name, p.Size, p.Code, p.Quantity, p.WarehouseID).Scan(&p.ID)
+	if p.SerialTracked && p.Quantity != 0 {
+		return ErrSerialsMismatch
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	var capacity *WarehouseCapacity
+	if p.Quantity > 0 {
+		if capacity, err = warehouseCapacity(tx, p.WarehouseID, true); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO products(name, size, code, quantity, warehouse_id, serial_tracked) VALUES($1, $2, $3, $4, $5, $6) RETURNING id, version",
+		p.Name, p.Size, p.Code, p.Quantity, p.WarehouseID, p.SerialTracked,
+	).Scan(&p.ID, &p.Version)
+	if err != nil {
+		tx.Rollback()
+		return duplicateCodeError(err)
+	}
+
+	if capacity != nil {
+		if err := checkStockCapacity(tx, p.WarehouseID, capacity, "product:"+strconv.Itoa(p.ID)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if p.Quantity != 0 {
+		if err := recordMovement(tx, p.ID, p.Quantity, MovementInitial, ""); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//	@Summary		Update a product.
+//	@Description	Update the name and size of a product. With If-Match the update is applied only to one of the given versions.
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Param			code		path		string			true	"Product code or barcode"
+//	@Param			If-Match	header		string			false	"Expected product ETags, comma-separated"
+//	@Param			product		body		Product			true	"Product name and size"
+//	@Success		200			{object}	Product
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		404			{object}	ErrorResponse	"Product not found"
+//	@Failure		412			{object}	ErrorResponse	"Product version does not match"
+//	@Router			/products/{code} [put]
+//
+func UpdateProduct(db *sql.DB, code string, p *Product, versions []int) error {
+	productCode, err := resolveCode(db, code)
+	if err != nil {
+		return err
+	}
+
+	err = db.QueryRow(`UPDATE products SET name = $1, size = $2 WHERE code = $3 AND ($4::INTEGER[] IS NULL OR version = ANY($4))
+		RETURNING id, code, quantity, warehouse_id, serial_tracked, is_bundle, version`,
+		p.Name, p.Size, productCode, pq.Array(versions),
+	).Scan(&p.ID, &p.Code, &p.Quantity, &p.WarehouseID, &p.SerialTracked, &p.IsBundle, &p.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return versionConflict(db, "products", "code", productCode)
+	}
+
+	return err
 }
 
 //	@Summary		Delete a product
-//	@Description	Delete a product by its ID.
+//	@Description	Delete a product by its ID. With If-Match the product is deleted only in one of the given versions.
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int				true	"Product ID"
-//	@Success		200	{string}	string			"Product deleted successfully"
-//	@Failure		400	{object}	ErrorResponse	"Invalid request format"
-//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Param			id			path		int				true	"Product ID"
+//	@Param			If-Match	header		string			false	"Expected product ETags, comma-separated"
+//	@Success		200			{string}	string			"Product deleted successfully"
+//	@Failure		400			{object}	ErrorResponse	"Invalid request format"
+//	@Failure		412			{object}	ErrorResponse	"Product version does not match"
+//	@Failure		500			{object}	ErrorResponse	"Internal server error"
 //	@Router			/delete-product/:id [delete]
 //
-func DeleteProduct(db *sql.DB, id int) error {
-	_, err := db.Exec("DELETE FROM products WHERE id = $1", id)
+func DeleteProduct(db *sql.DB, id int, versions []int) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// журнал движений удаляется вместе с товаром, поэтому событие об обнулении остатка пишется напрямую
+	e := StockChangedEvent{ProductID: id, Reason: MovementDelete}
+	err = tx.QueryRow("DELETE FROM products WHERE id = $1 AND ($2::INTEGER[] IS NULL OR version = ANY($2)) RETURNING code, warehouse_id, quantity",
+		id, pq.Array(versions)).
+		Scan(&e.Code, &e.WarehouseID, &e.Delta)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		if versions == nil {
+			return nil
+		}
+		return versionConflict(db, "products", "id", id)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	e.Delta = -e.Delta
+
+	if err := enqueueEvent(tx, EventStockChanged, productEventKey(id), e); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
 //	@Summary		Reserves products
-//	@Description	Reserves products and updates their quantities
+//	@Description	Reserves products and updates their quantities. Bundle codes reserve all of their components atomically. With backorder=true units that are out of stock are put on a FIFO waitlist instead of failing the request, and the response lists reserved codes and created backorders. With latitude and longitude the codes are allocated to the nearest available warehouses, preferring one warehouse that can fulfil the whole basket, and the same size of the same style may be reserved in place of a requested code. With region_id the codes are allocated to available warehouses of the region and its nested regions in the same way. Units are never reserved in a warehouse that is closed now by its calendar, a maintenance window or its is_available flag; with backorder=true such units are waitlisted until the warehouse opens
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
 //	@Param			productCodes	query		[]string	true	"Product codes"
+//	@Param			backorder		query		bool		false	"Waitlist units that are out of stock"
+//	@Param			reference		query		string		false	"Reference stored with backorders and their reservations"
+//	@Param			latitude		query		number		false	"Customer latitude for distance-based allocation"
+//	@Param			longitude		query		number		false	"Customer longitude for distance-based allocation"
+//	@Param			region_id		query		int			false	"Region whose warehouses, including nested regions, the codes are allocated to"
+//	@Success		200				{object}	ReservationResult	"With backorder=true"
+//	@Success		200				{array}		NearestAllocation	"With latitude and longitude"
+//	@Success		200				{array}		RegionAllocation	"With region_id"
 //	@Success		204				{string}	string		""
 //	@Failure		400				{object}	ErrorResponse
+//	@Failure		409				{object}	ErrorResponse	"Product is out of stock or its warehouse is not available now"
 //	@Failure		500				{object}	ErrorResponse
 //	@Router			/reserve-products [post]
 //
 func ReserveProducts(db *sql.DB, productCodes []string) error {
 	if len(productCodes) == 0 {
-		return errors.New("empty product codes")
+		return ErrEmptyProductCodes
 	}
 
 	tx, err := db.Begin()
@@ -118,38 +354,48 @@ func ReserveProducts(db *sql.DB, productCodes []string) error {
 		return err
 	}
 
-	for _, code := range productCodes {
-		row := tx.QueryRow("SELECT id, name, size, code, quantity FROM products WHERE code = $1 FOR UPDATE", code)
+	if err := reserveCodes(tx, productCodes, ""); err != nil {
+		tx.Rollback()
+		return err
+	}
 
-		var p Product
-		err := row.Scan(&p.ID, &p.Name, &p.Size, &p.Code, &p.Quantity)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-		if p.Quantity < 1 {
-			tx.Rollback()
-			return errors.New("product is out of stock")
-		}
+	return nil
+}
 
-		_, err = tx.Exec("UPDATE products SET quantity = quantity - 1 WHERE id = $1", p.ID)
+// reserveCodes резервирует по одной единице каждого кода в рамках транзакции, раскладывая наборы на компоненты
+func reserveCodes(tx *sql.Tx, productCodes []string, reference string) error {
+	return reserveChannelCodes(tx, productCodes, reference, 0)
+}
+
+func reserveChannelCodes(tx *sql.Tx, productCodes []string, reference string, channelID int) error {
+	for _, code := range productCodes {
+		codes, err := expandCode(tx, code)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+		for _, code := range codes {
+			p, err := reserveChannelUnit(tx, code, reference, channelID)
+			if err != nil {
+				return err
+			}
+
+			if err := reserveAnySerial(tx, p); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
 //	@Summary		Releases products
-//	@Description	Releases reserved products and updates their quantities
+//	@Description	Releases reserved products and updates their quantities. Bundle codes release all of their components
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
@@ -161,7 +407,7 @@ func ReserveProducts(db *sql.DB, productCodes []string) error {
 //
 func ReleaseProducts(db *sql.DB, productCodes []string) error {
 	if len(productCodes) == 0 {
-		return errors.New("empty product codes")
+		return ErrEmptyProductCodes
 	}
 
 	tx, err := db.Begin()
@@ -169,34 +415,40 @@ func ReleaseProducts(db *sql.DB, productCodes []string) error {
 		return err
 	}
 
-	updateStmt, err := tx.Prepare("UPDATE products SET quantity = quantity + 1 WHERE code = $1")
+	if err := releaseCodes(tx, productCodes, ""); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	defer updateStmt.Close()
 
+	return nil
+}
+
+// releaseCodes возвращает по одной единице каждого кода в рамках транзакции, раскладывая наборы на компоненты.
+// Вернувшийся остаток получает лист ожидания.
+func releaseCodes(tx *sql.Tx, productCodes []string, reference string) error {
+	return releaseChannelCodes(tx, productCodes, reference, 0)
+}
+
+func releaseChannelCodes(tx *sql.Tx, productCodes []string, reference string, channelID int) error {
 	for _, code := range productCodes {
-		var p Product
-		err := db.QueryRow("SELECT id, name, size, code, quantity FROM products WHERE code = $1", code).Scan(&p.ID, &p.Name, &p.Size, &p.Code, &p.Quantity)
+		codes, err := expandCode(tx, code)
 		if err != nil {
-			tx.Rollback()
 			return err
 		}
 
-		_, err = updateStmt.Exec(p.Code)
-		if err != nil {
-			tx.Rollback()
-			return err
+		for _, code := range codes {
+			if _, err := releaseChannelUnit(tx, code, reference, channelID, true); err != nil {
+				return err
+			}
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
 	return nil
 }
 
@@ -205,13 +457,16 @@ func ReleaseProducts(db *sql.DB, productCodes []string) error {
 // @Accept json
 // @Produce json
 // @Param warehouseID path int true "Warehouse ID"
-// @Success 200 {array} Product "Remaining products"
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {array} Product "Remaining products with their bin balances"
+// @Success 304 {string} string "Stock has not changed"
 // @Failure 400 {object} ErrorResponse "Invalid request format"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /remaining-products/{warehouseID} [get]
-// GetRemainingProducts возвращает оставшееся количество продуктов на складе
+// GetRemainingProducts возвращает оставшееся количество продуктов на складе без учета просроченных партий
 func GetRemainingProducts(db *sql.DB, warehouseID int) ([]Product, error) {
-	rows, err := db.Query("SELECT code, quantity FROM products WHERE warehouse_id = $1", warehouseID)
+	rows, err := db.Query(`SELECT p.id, p.code, `+availableSQL+`, p.is_bundle, p.version
+		FROM products p WHERE p.warehouse_id = $1`, warehouseID)
 	if err != nil {
 		return nil, err
 	}
@@ -219,7 +474,7 @@ func GetRemainingProducts(db *sql.DB, warehouseID int) ([]Product, error) {
 	var products []Product
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.Code, &p.Quantity); err != nil {
+		if err := rows.Scan(&p.ID, &p.Code, &p.Quantity, &p.IsBundle, &p.Version); err != nil {
 			return nil, err
 		}
 		p.WarehouseID = warehouseID
@@ -229,5 +484,148 @@ func GetRemainingProducts(db *sql.DB, warehouseID int) ([]Product, error) {
 		return nil, err
 	}
 
+	if err := fillBundleQuantities(db, warehouseID, products); err != nil {
+		return nil, err
+	}
+
+	bins, err := getWarehouseBinBalances(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		products[i].Bins = bins[products[i].ID]
+	}
+
 	return products, nil
 }
+
+// GetRemainingProductsETag возвращает тег остатков склада для условных запросов. Тег меняется
+// при любом изменении товаров склада (версии растут и при движении остатков) и со сменой даты,
+// от которой зависит просроченность партий.
+func GetRemainingProductsETag(db *sql.DB, warehouseID int) (string, error) {
+	var tag string
+	err := db.QueryRow(`SELECT md5(CURRENT_DATE::TEXT || ':' || COALESCE(string_agg(id || '.' || version, ',' ORDER BY id), ''))
+		FROM products WHERE warehouse_id = $1`, warehouseID).Scan(&tag)
+	return tag, err
+}
+
+// reserveUnit резервирует одну единицу товара по коду в рамках транзакции из остатка, не закрепленного за каналами.
+// reference попадает в движение остатка, например номер заказа.
+func reserveUnit(tx *sql.Tx, code, reference string) (*Product, error) {
+	return reserveChannelUnit(tx, code, reference, 0)
+}
+
+// reserveChannelUnit резервирует единицу товара от имени канала продаж; channelID = 0 — без канала.
+// Пока у товара есть ожидающие заявки листа ожидания, остаток без канала обещан им, и новый резерв
+// получает ErrOutOfStock. Закрепленный за каналом остаток лист ожидания не использует, поэтому
+// резервы канала очередь не ждут.
+func reserveChannelUnit(tx *sql.Tx, code, reference string, channelID int) (*Product, error) {
+	return takeUnit(tx, code, reference, channelID, channelID == 0)
+}
+
+// takeUnit резервирует единицу товара; queued — проверять, не ждет ли остаток лист ожидания.
+// Сам лист ожидания резервирует без проверки.
+func takeUnit(tx *sql.Tx, code, reference string, channelID int, queued bool) (*Product, error) {
+	var p Product
+	err := tx.QueryRow("SELECT id, name, size, code, quantity, warehouse_id, serial_tracked FROM products WHERE code = $1 FOR UPDATE", code).
+		Scan(&p.ID, &p.Name, &p.Size, &p.Code, &p.Quantity, &p.WarehouseID, &p.SerialTracked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotFrozen(tx, p.ID); err != nil {
+		return nil, err
+	}
+
+	if err := checkWarehouseOpen(tx, p.WarehouseID); err != nil {
+		return nil, err
+	}
+
+	if p.Quantity < 1 {
+		return nil, ErrOutOfStock
+	}
+
+	if queued {
+		var waiting bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM backorders WHERE product_id = $1 AND status = $2)",
+			p.ID, BackorderWaiting).Scan(&waiting)
+		if err != nil {
+			return nil, err
+		}
+		if waiting {
+			return nil, ErrOutOfStock
+		}
+	}
+
+	if err := takeChannelStock(tx, p.ID, p.Quantity, channelID); err != nil {
+		return nil, err
+	}
+
+	if err := reserveFromLot(tx, p.ID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE products SET quantity = quantity - 1, reserved = reserved + 1 WHERE id = $1", p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Quantity--
+
+	if err := recordChannelMovement(tx, p.ID, -1, MovementReserve, reference, channelID); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// releaseChannelUnit возвращает одну зарезервированную единицу товара по коду в рамках транзакции и снимает ее
+// с резерва канала channelID (0 — без канала). anySerial — вернуть в наличие любой зарезервированный серийный
+// номер; при возврате конкретного номера вызывающий меняет его статус до вызова. Движение записывается
+// последним, когда партии, серийные номера и резерв канала уже возвращены, потому что вернувшуюся
+// единицу сразу может зарезервировать лист ожидания.
+func releaseChannelUnit(tx *sql.Tx, code, reference string, channelID int, anySerial bool) (*Product, error) {
+	var p Product
+	var reserved int
+	err := tx.QueryRow("SELECT id, name, size, code, quantity, reserved, warehouse_id, serial_tracked FROM products WHERE code = $1 FOR UPDATE", code).
+		Scan(&p.ID, &p.Name, &p.Size, &p.Code, &p.Quantity, &reserved, &p.WarehouseID, &p.SerialTracked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotFrozen(tx, p.ID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE products SET quantity = quantity + 1, reserved = GREATEST(reserved - 1, 0) WHERE id = $1", p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Quantity++
+
+	if err := releaseToLot(tx, p.ID); err != nil {
+		return nil, err
+	}
+
+	if anySerial {
+		if err := releaseAnySerial(tx, &p); err != nil {
+			return nil, err
+		}
+	}
+
+	channelID, err = releaseChannelStock(tx, p.ID, channelID, reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordChannelMovement(tx, p.ID, 1, MovementRelease, reference, channelID); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,543 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+	"github.com/lib/pq"
+)
+
+// EventStockDepleted — остаток товара стал нулевым. Выводится из stock.changed при рассылке вебхуков.
+const EventStockDepleted = "stock.depleted"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// webhookEventTypes — события, на которые можно подписаться
+var webhookEventTypes = map[string]bool{
+	EventStockChanged:              true,
+	EventStockDepleted:             true,
+	EventWarehouseOnline:           true,
+	EventWarehouseOffline:          true,
+	EventStockLow:                  true,
+	EventStockOut:                  true,
+	EventStockAlertResolved:        true,
+	EventBackorderCreated:          true,
+	EventBackorderAllocated:        true,
+	EventBackorderFulfilled:        true,
+	EventBackorderCancelled:        true,
+	EventWarehouseCapacityExceeded: true,
+}
+
+// webhookMaxAttempts и webhookRetryDelay задают повторы доставки: задержка удваивается
+// после каждой неудачи, а после webhookMaxAttempts попыток доставка уходит в dead-letter
+var (
+	webhookMaxAttempts = 8
+	webhookRetryDelay  = 10 * time.Second
+)
+
+// webhookMaxRetryDelay ограничивает задержку между попытками
+const webhookMaxRetryDelay = time.Hour
+
+// webhookLeaseMargin — запас сверх таймаута запроса, на который откладывается доставка, взятая в работу,
+// чтобы ее не отправил другой экземпляр, пока идет запрос и пишется результат. Без таймаута у клиента
+// доставка откладывается на webhookLeaseMargin.
+const webhookLeaseMargin = time.Minute
+
+// webhookRetention — сколько хранятся доставленные и dead-letter доставки вместе с журналом попыток
+var webhookRetention = 7 * 24 * time.Hour
+
+// SetWebhookRetryPolicy задает число попыток доставки вебхука и задержку перед первым повтором
+func SetWebhookRetryPolicy(maxAttempts int, delay time.Duration) {
+	webhookMaxAttempts = maxAttempts
+	webhookRetryDelay = delay
+}
+
+// SetWebhookRetention задает срок хранения завершенных доставок вебхуков
+func SetWebhookRetention(d time.Duration) {
+	webhookRetention = d
+}
+
+// WebhookSubscription — подписка на события. Пустые WarehouseID и ProductCode означают все склады и товары.
+// Secret возвращается только при создании подписки.
+type WebhookSubscription struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	WarehouseID *int      `json:"warehouse_id,omitempty"`
+	ProductCode *string   `json:"product_code,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64            `json:"id"`
+	SubscriptionID int              `json:"subscription_id"`
+	EventID        int64            `json:"event_id"`
+	EventType      string           `json:"event_type"`
+	Payload        json.RawMessage  `json:"payload"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"next_attempt_at,omitempty"`
+	LastError      *string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DeliveredAt    *time.Time       `json:"delivered_at,omitempty"`
+	Log            []WebhookAttempt `json:"log,omitempty"`
+}
+
+// WebhookAttempt — запись журнала доставки
+type WebhookAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int      `json:"status_code,omitempty"`
+	Error       *string   `json:"error,omitempty"`
+	DurationMS  int       `json:"duration_ms"`
+}
+
+//	@Summary		Create a webhook subscription.
+//	@Description	Subscribe a URL to stock.changed, stock.depleted, stock.low, stock.out, stock.alert_resolved, backorder.*, warehouse.online, warehouse.offline and warehouse.capacity_exceeded events, optionally filtered by warehouse and product code. Deliveries are signed with HMAC-SHA256 of "<X-Signature-Timestamp>.<body>" in the X-Signature header; the secret is generated when omitted and returned only in this response.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			subscription	body		WebhookSubscription	true	"Subscription"
+//	@Success		201				{object}	WebhookSubscription
+//	@Failure		400				{object}	ErrorResponse	"Invalid subscription"
+//	@Failure		500				{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhooks [post]
+//
+func CreateWebhookSubscription(db *sql.DB, s *WebhookSubscription) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook
+	}
+	if len(s.EventTypes) == 0 {
+		return ErrInvalidWebhook
+	}
+	for _, t := range s.EventTypes {
+		if !webhookEventTypes[t] {
+			return ErrInvalidWebhook
+		}
+	}
+	if s.ProductCode != nil {
+		code, err := resolveCode(db, *s.ProductCode)
+		if err != nil {
+			return err
+		}
+		s.ProductCode = &code
+	}
+
+	if s.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		s.Secret = hex.EncodeToString(secret)
+	}
+
+	err = db.QueryRow(`INSERT INTO webhook_subscriptions(url, secret, event_types, warehouse_id, product_code)
+		VALUES($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		s.URL, s.Secret, pq.Array(s.EventTypes), s.WarehouseID, s.ProductCode,
+	).Scan(&s.ID, &s.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+//	@Summary		List webhook subscriptions.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{array}		WebhookSubscription
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhooks [get]
+//
+func GetWebhookSubscriptions(db *sql.DB) ([]WebhookSubscription, error) {
+	rows, err := db.Query(`SELECT id, url, event_types, warehouse_id, product_code, created_at
+		FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []WebhookSubscription{}
+	for rows.Next() {
+		s, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+//	@Summary		Get a webhook subscription.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path		int				true	"Subscription ID"
+//	@Success		200	{object}	WebhookSubscription
+//	@Failure		404	{object}	ErrorResponse	"Subscription not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhooks/{id} [get]
+//
+func GetWebhookSubscription(db *sql.DB, id int) (*WebhookSubscription, error) {
+	s, err := scanWebhookSubscription(db.QueryRow(`SELECT id, url, event_types, warehouse_id, product_code, created_at
+		FROM webhook_subscriptions WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	return s, err
+}
+
+func scanWebhookSubscription(row interface{ Scan(...interface{}) error }) (*WebhookSubscription, error) {
+	var s WebhookSubscription
+	var warehouseID sql.NullInt64
+	var productCode sql.NullString
+	if err := row.Scan(&s.ID, &s.URL, pq.Array(&s.EventTypes), &warehouseID, &productCode, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if warehouseID.Valid {
+		id := int(warehouseID.Int64)
+		s.WarehouseID = &id
+	}
+	if productCode.Valid {
+		s.ProductCode = &productCode.String
+	}
+
+	return &s, nil
+}
+
+//	@Summary		Delete a webhook subscription.
+//	@Description	Delete a subscription together with its pending deliveries and delivery log.
+//	@Tags			webhooks
+//	@Param			id	path	int	true	"Subscription ID"
+//	@Success		204
+//	@Failure		404	{object}	ErrorResponse	"Subscription not found"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhooks/{id} [delete]
+//
+func DeleteWebhookSubscription(db *sql.DB, id int) error {
+	res, err := db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//	@Summary		Get the delivery log of a subscription.
+//	@Description	Get the latest deliveries of a subscription with every attempt, newest first. Filter by status pending, delivered or dead.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id		path		int				true	"Subscription ID"
+//	@Param			status	query		string			false	"Delivery status"
+//	@Param			limit	query		int				false	"Maximum number of deliveries"	default(50)
+//	@Success		200		{array}		WebhookDelivery
+//	@Failure		400		{object}	ErrorResponse	"Invalid status"
+//	@Failure		404		{object}	ErrorResponse	"Subscription not found"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhooks/{id}/deliveries [get]
+//
+func GetWebhookDeliveries(db *sql.DB, subscriptionID int, status string, limit int) ([]WebhookDelivery, error) {
+	if _, err := GetWebhookSubscription(db, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return listWebhookDeliveries(db, subscriptionID, status, limit)
+}
+
+//	@Summary		List webhook deliveries.
+//	@Description	List deliveries of all subscriptions, newest first. With status=dead this is the dead-letter list of deliveries that ran out of attempts.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			status	query		string			false	"Delivery status"
+//	@Param			limit	query		int				false	"Maximum number of deliveries"	default(50)
+//	@Success		200		{array}		WebhookDelivery
+//	@Failure		400		{object}	ErrorResponse	"Invalid status"
+//	@Failure		500		{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhook-deliveries [get]
+//
+func GetAllWebhookDeliveries(db *sql.DB, status string, limit int) ([]WebhookDelivery, error) {
+	return listWebhookDeliveries(db, 0, status, limit)
+}
+
+func listWebhookDeliveries(db *sql.DB, subscriptionID int, status string, limit int) ([]WebhookDelivery, error) {
+	if status != "" && status != DeliveryPending && status != DeliveryDelivered && status != DeliveryDead {
+		return nil, ErrInvalidWebhook
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.Query(`SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+			next_attempt_at, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE ($1 = 0 OR subscription_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY id DESC LIMIT $3`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	index := make(map[int64]int)
+	var ids []int64
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		var nextAttemptAt, deliveredAt sql.NullTime
+		var lastError sql.NullString
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&nextAttemptAt, &lastError, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		if d.Status == DeliveryPending && nextAttemptAt.Valid {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		if lastError.Valid {
+			d.LastError = &lastError.String
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		index[d.ID] = len(deliveries)
+		ids = append(ids, d.ID)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	attempts, err := db.Query(`SELECT delivery_id, attempted_at, status_code, error, duration_ms
+		FROM webhook_delivery_attempts WHERE delivery_id = ANY($1) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer attempts.Close()
+
+	for attempts.Next() {
+		var deliveryID int64
+		var a WebhookAttempt
+		var statusCode sql.NullInt64
+		var attemptErr sql.NullString
+		if err := attempts.Scan(&deliveryID, &a.AttemptedAt, &statusCode, &attemptErr, &a.DurationMS); err != nil {
+			return nil, err
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			a.StatusCode = &code
+		}
+		if attemptErr.Valid {
+			a.Error = &attemptErr.String
+		}
+		d := &deliveries[index[deliveryID]]
+		d.Log = append(d.Log, a)
+	}
+
+	return deliveries, attempts.Err()
+}
+
+//	@Summary		Retry a webhook delivery.
+//	@Description	Return a dead-lettered delivery to the queue with a fresh set of attempts.
+//	@Tags			webhooks
+//	@Param			id	path	int	true	"Delivery ID"
+//	@Success		202
+//	@Failure		404	{object}	ErrorResponse	"Delivery not found"
+//	@Failure		409	{object}	ErrorResponse	"Delivery is not dead-lettered"
+//	@Failure		500	{object}	ErrorResponse	"Internal server error"
+//	@Router			/webhook-deliveries/{id}/retry [post]
+//
+func RetryWebhookDelivery(db *sql.DB, id int64) error {
+	res, err := db.Exec(`UPDATE webhook_deliveries SET status = $2, attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND status = $3`, id, DeliveryPending, DeliveryDead)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	return ErrInvalidStatus
+}
+
+// WebhookFanout возвращает получателя событий outbox, который создает доставки для подходящих подписок.
+// Повторная пересылка того же события не создает дублей.
+func WebhookFanout(db *sql.DB) events.Publisher {
+	return events.PublisherFunc(func(ctx context.Context, e events.Event) error {
+		types := []string{e.Type}
+		if e.Type == EventStockChanged {
+			var sc StockChangedEvent
+			if err := json.Unmarshal(e.Payload, &sc); err != nil {
+				return err
+			}
+			if sc.Quantity <= 0 && sc.Quantity-sc.Delta > 0 {
+				types = append(types, EventStockDepleted)
+			}
+		}
+
+		_, err := db.ExecContext(ctx, `INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, payload)
+			SELECT s.id, $1, t.type, $3 FROM webhook_subscriptions s, unnest($2::TEXT[]) t(type)
+			WHERE t.type = ANY(s.event_types)
+				AND (s.warehouse_id IS NULL OR s.warehouse_id = ($3::JSONB->>'warehouse_id')::INTEGER)
+				AND (s.product_code IS NULL OR s.product_code = $3::JSONB->>'code')
+			ORDER BY s.id, t.type
+			ON CONFLICT (subscription_id, event_id, event_type) DO NOTHING`,
+			e.ID, pq.Array(types), []byte(e.Payload))
+		return err
+	})
+}
+
+type dueDelivery struct {
+	id       int64
+	url      string
+	secret   string
+	attempts int
+	event    events.Event
+}
+
+// DeliverWebhooks отправляет до limit доставок, срок которых наступил, и возвращает число обработанных.
+// Неудачная доставка откладывается с экспоненциальной задержкой, а после последней попытки
+// переводится в dead-letter. Каждая попытка пишется в журнал доставки.
+// Доставки берутся в работу по одной, поэтому аренда не истекает, пока отправляются предыдущие.
+func DeliverWebhooks(ctx context.Context, db *sql.DB, client *http.Client, limit int) (int, error) {
+	lease := webhookLeaseMargin + client.Timeout
+
+	delivered := 0
+	for delivered < limit && ctx.Err() == nil {
+		d, err := claimWebhookDelivery(ctx, db, lease)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return delivered, err
+		}
+
+		publisher := &events.WebhookPublisher{URL: d.url, Secret: d.secret, Client: client}
+		started := time.Now()
+		statusCode, sendErr := publisher.Send(ctx, d.event, strconv.FormatInt(d.id, 10))
+		if err := recordWebhookAttempt(ctx, db, d, statusCode, sendErr, time.Since(started)); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// claimWebhookDelivery берет в работу очередную доставку, срок которой наступил, откладывая ее на lease
+func claimWebhookDelivery(ctx context.Context, db *sql.DB, lease time.Duration) (*dueDelivery, error) {
+	var d dueDelivery
+	var payload []byte
+	err := db.QueryRowContext(ctx, `WITH due AS (
+			SELECT id FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= now()
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = now() + $2 * INTERVAL '1 second'
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING d.id, s.url, s.secret, d.attempts, d.event_id, d.event_type, d.payload, d.created_at`,
+		DeliveryPending, lease.Seconds()).
+		Scan(&d.id, &d.url, &d.secret, &d.attempts, &d.event.ID, &d.event.Type, &payload, &d.event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.event.Payload = payload
+
+	return &d, nil
+}
+
+// PurgeWebhookDeliveries удаляет доставленные и dead-letter доставки, срок хранения которых истек,
+// вместе с журналом попыток
+func PurgeWebhookDeliveries(db *sql.DB) (int64, error) {
+	res, err := db.Exec(`DELETE FROM webhook_deliveries
+		WHERE status <> $1 AND COALESCE(delivered_at, next_attempt_at) < now() - $2 * INTERVAL '1 second'`,
+		DeliveryPending, webhookRetention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func recordWebhookAttempt(ctx context.Context, db *sql.DB, d *dueDelivery, statusCode int, sendErr error, duration time.Duration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var code sql.NullInt64
+	if statusCode != 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+	var message sql.NullString
+	if sendErr != nil {
+		message = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+
+	_, err = tx.Exec(`INSERT INTO webhook_delivery_attempts(delivery_id, status_code, error, duration_ms) VALUES($1, $2, $3, $4)`,
+		d.id, code, message, duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	attempts := d.attempts + 1
+	switch {
+	case sendErr == nil:
+		_, err = tx.Exec(`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_error = NULL, delivered_at = now()
+			WHERE id = $1`, d.id, DeliveryDelivered, attempts)
+	case attempts >= webhookMaxAttempts:
+		_, err = tx.Exec(`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_error = $4 WHERE id = $1`,
+			d.id, DeliveryDead, attempts, message)
+	default:
+		_, err = tx.Exec(`UPDATE webhook_deliveries SET attempts = $2, last_error = $3,
+			next_attempt_at = now() + $4 * INTERVAL '1 second' WHERE id = $1`,
+			d.id, attempts, message, webhookBackoff(attempts).Seconds())
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// webhookBackoff возвращает задержку перед следующей попыткой после attempts неудачных
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookRetryDelay
+	for i := 1; i < attempts && delay < webhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetryDelay {
+		delay = webhookMaxRetryDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+)
+
+// relayAll пересылает все неопубликованные события outbox
+func relayAll(t *testing.T, publisher events.Publisher) {
+	t.Helper()
+
+	db := openTestDB(t)
+	for {
+		n, err := RelayOutbox(context.Background(), db, 1000, []OutboxPublisher{{Name: "test", Publisher: publisher}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func TestWebhookDeliversSignedDepletionEvent(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+	p := createTestProduct(t, db, w.ID, 1)
+
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(events.TimestampHeader), 10, 64)
+		if !events.VerifySignature("test-secret", timestamp, body, r.Header.Get(events.SignatureHeader)) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received = append(received, r.Header.Get("X-Event-Type"))
+	}))
+	defer srv.Close()
+
+	s := &WebhookSubscription{URL: srv.URL, Secret: "test-secret", EventTypes: []string{EventStockDepleted}, ProductCode: &p.Code}
+	if err := CreateWebhookSubscription(db, s); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteWebhookSubscription(db, s.ID)
+
+	if err := ReserveProducts(db, []string{p.Code}); err != nil {
+		t.Fatal(err)
+	}
+	relayAll(t, WebhookFanout(db))
+
+	if _, err := DeliverWebhooks(context.Background(), db, srv.Client(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != EventStockDepleted {
+		t.Fatalf("Expected one stock.depleted delivery, got %v", received)
+	}
+
+	deliveries, err := GetWebhookDeliveries(db, s.ID, DeliveryDelivered, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || len(deliveries[0].Log) != 1 || *deliveries[0].Log[0].StatusCode != http.StatusOK {
+		t.Fatalf("Expected one delivered delivery with one logged attempt, got %+v", deliveries)
+	}
+
+	// завершенная доставка удаляется по истечении срока хранения
+	if _, err := db.Exec("UPDATE webhook_deliveries SET delivered_at = now() - $1 * INTERVAL '1 second' WHERE id = $2",
+		(webhookRetention + time.Hour).Seconds(), deliveries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PurgeWebhookDeliveries(db); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries, err := GetWebhookDeliveries(db, s.ID, "", 10); err != nil || len(deliveries) != 0 {
+		t.Errorf("Expected the expired delivery to be purged, got %+v, %v", deliveries, err)
+	}
+}
+
+func TestWebhookRetriesAndDeadLetters(t *testing.T) {
+	db := openTestDB(t)
+	w := createTestWarehouse(t, db)
+
+	maxAttempts, delay := webhookMaxAttempts, webhookRetryDelay
+	SetWebhookRetryPolicy(2, 0)
+	defer SetWebhookRetryPolicy(maxAttempts, delay)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := &WebhookSubscription{URL: srv.URL, EventTypes: []string{EventWarehouseOffline}, WarehouseID: &w.ID}
+	if err := CreateWebhookSubscription(db, s); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteWebhookSubscription(db, s.ID)
+	if s.Secret == "" {
+		t.Error("Expected a generated secret")
+	}
+
+	w.IsAvailable = false
+	if err := UpdateWarehouse(db, w, nil); err != nil {
+		t.Fatal(err)
+	}
+	relayAll(t, WebhookFanout(db))
+
+	for i := 0; i < 2; i++ {
+		if _, err := DeliverWebhooks(context.Background(), db, srv.Client(), 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 delivery attempts, got %d", calls)
+	}
+
+	dead, err := GetWebhookDeliveries(db, s.ID, DeliveryDead, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 || len(dead[0].Log) != 2 {
+		t.Fatalf("Expected one dead-lettered delivery after 2 attempts, got %+v", dead)
+	}
+
+	if err := RetryWebhookDelivery(db, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RetryWebhookDelivery(db, dead[0].ID); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus for a pending delivery, got %v", err)
+	}
+}
+
+func TestCreateWebhookSubscriptionValidates(t *testing.T) {
+	db := openTestDB(t)
+
+	invalid := []WebhookSubscription{
+		{URL: "ftp://example.com", EventTypes: []string{EventStockChanged}},
+		{URL: "http://example.com"},
+		{URL: "http://example.com", EventTypes: []string{"stock.unknown"}},
+	}
+	for _, s := range invalid {
+		if err := CreateWebhookSubscription(db, &s); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("Expected ErrInvalidWebhook for %+v, got %v", s, err)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	delay := webhookRetryDelay
+	SetWebhookRetryPolicy(webhookMaxAttempts, 10*time.Second)
+	defer SetWebhookRetryPolicy(webhookMaxAttempts, delay)
+
+	cases := map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 20: time.Hour}
+	for attempts, want := range cases {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerBackorderRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/backorders", func(c *gin.Context) {
+		backorders, err := controller.GetBackorders(db, c.Query("code"), c.Query("status"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, backorders)
+	})
+
+	r.DELETE("/backorders/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			abortWithBadRequest(c, "invalid backorder ID")
+			return
+		}
+
+		b, err := controller.CancelBackorder(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, b)
+	})
+}
@@ -0,0 +1,58 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerBarcodeRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/products/:code", func(c *gin.Context) {
+		p, err := controller.GetProductByCode(db, c.Param("code"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		setVersionETag(c, p.Version)
+		c.JSON(http.StatusOK, p)
+	})
+
+	r.PUT("/products/:code", func(c *gin.Context) {
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return
+		}
+
+		var p controller.Product
+		if err := c.ShouldBindJSON(&p); err != nil {
+			abortWithBadRequest(c, "invalid product data")
+			return
+		}
+
+		if err := controller.UpdateProduct(db, c.Param("code"), &p, versions); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		setVersionETag(c, p.Version)
+		c.JSON(http.StatusOK, p)
+	})
+
+	r.POST("/products/:code/barcodes", func(c *gin.Context) {
+		var b controller.ProductBarcode
+		if err := c.ShouldBindJSON(&b); err != nil {
+			abortWithBadRequest(c, "invalid barcode data")
+			return
+		}
+
+		if err := controller.AddProductBarcode(db, c.Param("code"), &b); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, b)
+	})
+}
@@ -0,0 +1,36 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerBundleRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/bundles", func(c *gin.Context) {
+		var b controller.Bundle
+		if err := c.ShouldBindJSON(&b); err != nil {
+			abortWithBadRequest(c, "invalid bundle data")
+			return
+		}
+
+		if err := controller.CreateBundle(db, &b); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": b.ID})
+	})
+
+	r.GET("/bundles/:code", func(c *gin.Context) {
+		b, err := controller.GetBundle(db, c.Param("code"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, b)
+	})
+}
@@ -0,0 +1,116 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerCalendarRoutes(r *gin.Engine, db *sql.DB) {
+	r.PUT("/warehouses/:id/calendar", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var cal controller.WarehouseCalendar
+		if err := c.ShouldBindJSON(&cal); err != nil {
+			abortWithBadRequest(c, "invalid calendar")
+			return
+		}
+
+		if err := controller.SetWarehouseCalendar(db, id, &cal); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cal)
+	})
+
+	r.GET("/warehouses/:id/calendar", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		cal, err := controller.GetWarehouseCalendar(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cal)
+	})
+
+	r.POST("/warehouses/:id/maintenance", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var w controller.MaintenanceWindow
+		if err := c.ShouldBindJSON(&w); err != nil {
+			abortWithBadRequest(c, "invalid maintenance window")
+			return
+		}
+		w.WarehouseID = id
+
+		if err := controller.CreateMaintenanceWindow(db, &w); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, w)
+	})
+
+	r.DELETE("/warehouses/:id/maintenance/:window_id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+		windowID, err := strconv.Atoi(c.Param("window_id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid maintenance window ID")
+			return
+		}
+
+		if err := controller.DeleteMaintenanceWindow(db, id, windowID); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.GET("/warehouses/:id/availability", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		at := time.Now()
+		if v := c.Query("at"); v != "" {
+			if at, err = time.Parse(time.RFC3339, v); err != nil {
+				abortWithBadRequest(c, "invalid at")
+				return
+			}
+		}
+
+		a, err := controller.GetWarehouseAvailability(db, id, at)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, a)
+	})
+}
@@ -0,0 +1,79 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerCapacityRoutes(r *gin.Engine, db *sql.DB) {
+	r.PUT("/warehouses/:id/capacity", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var capacity controller.WarehouseCapacity
+		if err := c.ShouldBindJSON(&capacity); err != nil {
+			abortWithBadRequest(c, "invalid capacity")
+			return
+		}
+
+		if err := controller.SetWarehouseCapacity(db, id, &capacity); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, capacity)
+	})
+
+	r.GET("/warehouses/:id/utilization", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		u, err := controller.GetWarehouseUtilization(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, u)
+	})
+
+	r.PUT("/products/:code/dimensions", func(c *gin.Context) {
+		var d controller.Dimensions
+		if err := c.ShouldBindJSON(&d); err != nil {
+			abortWithBadRequest(c, "invalid dimensions")
+			return
+		}
+
+		if err := controller.SetProductDimensions(db, c.Param("code"), d); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, d)
+	})
+
+	r.PUT("/styles/:code/dimensions", func(c *gin.Context) {
+		var d controller.Dimensions
+		if err := c.ShouldBindJSON(&d); err != nil {
+			abortWithBadRequest(c, "invalid dimensions")
+			return
+		}
+
+		if err := controller.SetStyleDimensions(db, c.Param("code"), d); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, d)
+	})
+}
@@ -0,0 +1,83 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerCatalogRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/categories", func(c *gin.Context) {
+		var cat controller.Category
+		if err := c.ShouldBindJSON(&cat); err != nil {
+			abortWithBadRequest(c, "invalid category data")
+			return
+		}
+
+		if err := controller.CreateCategory(db, &cat); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, cat)
+	})
+
+	r.GET("/categories/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid category ID")
+			return
+		}
+
+		cat, err := controller.GetCategory(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cat)
+	})
+
+	r.POST("/styles", func(c *gin.Context) {
+		var s controller.Style
+		if err := c.ShouldBindJSON(&s); err != nil {
+			abortWithBadRequest(c, "invalid style data")
+			return
+		}
+
+		if err := controller.CreateStyle(db, &s); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, s)
+	})
+
+	r.POST("/styles/:code/variants", func(c *gin.Context) {
+		var v controller.StyleVariant
+		if err := c.ShouldBindJSON(&v); err != nil {
+			abortWithBadRequest(c, "invalid variant data")
+			return
+		}
+
+		if err := controller.AddStyleVariant(db, c.Param("code"), v); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.GET("/styles/:code/stock", func(c *gin.Context) {
+		stock, err := controller.GetStyleStock(db, c.Param("code"), c.Query("size"), c.Query("system"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, stock)
+	})
+}
@@ -0,0 +1,136 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerChannelRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/channels", func(c *gin.Context) {
+		var ch controller.SalesChannel
+		if err := c.ShouldBindJSON(&ch); err != nil {
+			abortWithBadRequest(c, "invalid channel data")
+			return
+		}
+
+		if err := controller.CreateSalesChannel(db, &ch); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, ch)
+	})
+
+	r.GET("/channels", func(c *gin.Context) {
+		channels, err := controller.GetSalesChannels(db)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, channels)
+	})
+
+	r.PUT("/channels/:id/allocations/:code", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid channel ID")
+			return
+		}
+
+		var a controller.ChannelAllocation
+		if err := c.ShouldBindJSON(&a); err != nil {
+			abortWithBadRequest(c, "invalid allocation")
+			return
+		}
+
+		if err := controller.SetChannelAllocation(db, id, c.Param("code"), a); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, a)
+	})
+
+	r.DELETE("/channels/:id/allocations/:code", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid channel ID")
+			return
+		}
+
+		if err := controller.DeleteChannelAllocation(db, id, c.Param("code")); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.GET("/channels/:id/availability", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid channel ID")
+			return
+		}
+		warehouseID, err := strconv.Atoi(c.DefaultQuery("warehouse_id", "0"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		lines, err := controller.GetChannelAvailability(db, id, warehouseID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lines)
+	})
+
+	r.POST("/channels/:id/reserve-products", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid channel ID")
+			return
+		}
+
+		var productCodes []string
+		if err := c.ShouldBindJSON(&productCodes); err != nil {
+			abortWithBadRequest(c, "invalid request body")
+			return
+		}
+
+		if err := controller.ReserveChannelProducts(db, id, productCodes); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.POST("/channels/:id/release-products", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid channel ID")
+			return
+		}
+
+		var productCodes []string
+		if err := c.ShouldBindJSON(&productCodes); err != nil {
+			abortWithBadRequest(c, "invalid request body")
+			return
+		}
+
+		if err := controller.ReleaseChannelProducts(db, id, productCodes); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,100 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerCycleCountRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/cycle-counts", func(c *gin.Context) {
+		var cc controller.CycleCount
+		if err := c.ShouldBindJSON(&cc); err != nil {
+			abortWithBadRequest(c, "invalid cycle count data")
+			return
+		}
+
+		if err := controller.CreateCycleCount(db, &cc); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, cc)
+	})
+
+	r.GET("/cycle-counts/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid cycle count ID")
+			return
+		}
+
+		cc, err := controller.GetCycleCount(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cc)
+	})
+
+	r.POST("/cycle-counts/:id/counts", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid cycle count ID")
+			return
+		}
+
+		var entries []controller.CycleCountEntry
+		if err := c.ShouldBindJSON(&entries); err != nil {
+			abortWithBadRequest(c, "invalid request body")
+			return
+		}
+
+		if err := controller.RecordCycleCountEntries(db, id, entries); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.POST("/cycle-counts/:id/approve", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid cycle count ID")
+			return
+		}
+
+		if err := controller.ApproveCycleCount(db, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		cc, err := controller.GetCycleCount(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cc)
+	})
+
+	r.POST("/cycle-counts/:id/cancel", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid cycle count ID")
+			return
+		}
+
+		if err := controller.CancelCycleCount(db, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
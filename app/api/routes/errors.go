@@ -0,0 +1,69 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+// errorStatus подбирает HTTP-статус для ошибки контроллера
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, controller.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, controller.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, controller.ErrInvalidStatus),
+		errors.Is(err, controller.ErrProductFrozen),
+		errors.Is(err, controller.ErrOutOfStock),
+		errors.Is(err, controller.ErrNegativeStock),
+		errors.Is(err, controller.ErrSerialUnavailable),
+		errors.Is(err, controller.ErrDuplicateSerial),
+		errors.Is(err, controller.ErrDuplicateCode),
+		errors.Is(err, controller.ErrCapacityExceeded),
+		errors.Is(err, controller.ErrLotExpiryMismatch),
+		errors.Is(err, controller.ErrNotReserved),
+		errors.Is(err, controller.ErrWarehouseClosed):
+		return http.StatusConflict
+	case errors.Is(err, controller.ErrEmptyProductCodes),
+		errors.Is(err, controller.ErrInvalidQuantity),
+		errors.Is(err, controller.ErrInvalidLocation),
+		errors.Is(err, controller.ErrInvalidLot),
+		errors.Is(err, controller.ErrInvalidReceipt),
+		errors.Is(err, controller.ErrSerialsMismatch),
+		errors.Is(err, controller.ErrInvalidBundle),
+		errors.Is(err, controller.ErrInvalidCatalog),
+		errors.Is(err, controller.ErrInvalidCode),
+		errors.Is(err, controller.ErrTooManyLabels),
+		errors.Is(err, controller.ErrInvalidImport),
+		errors.Is(err, controller.ErrInvalidWebhook),
+		errors.Is(err, controller.ErrInvalidOrderEvent),
+		errors.Is(err, controller.ErrInvalidThreshold),
+		errors.Is(err, controller.ErrInvalidReport),
+		errors.Is(err, controller.ErrInvalidChannel),
+		errors.Is(err, controller.ErrInvalidCapacity),
+		errors.Is(err, controller.ErrInvalidGeoPoint),
+		errors.Is(err, controller.ErrInvalidCalendar),
+		errors.Is(err, controller.ErrInvalidRegion):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortWithError(c *gin.Context, err error) {
+	status := errorStatus(err)
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
+
+func abortWithBadRequest(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: message,
+	})
+}
@@ -0,0 +1,65 @@
+package route
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+// setVersionETag выставляет заголовок ETag по версии ресурса
+func setVersionETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatchVersions возвращает версии из тегов заголовка If-Match или nil, если заголовка нет
+// или в нем есть "*". If-Match сравнивает теги строго: слабый тег (W/) и тег не из числа версий
+// не совпадают ни с одной версией, и если совпасть не может ни один тег, запрос завершается
+// с ошибкой 412. При некорректном заголовке запрос завершается с ошибкой 400. В обоих случаях
+// ok равно false.
+func ifMatchVersions(c *gin.Context) (versions []int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return nil, true
+	}
+
+	versions = []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			abortWithBadRequest(c, "If-Match must contain a list of entity tags")
+			return nil, false
+		}
+
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if weak || err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		abortWithError(c, controller.ErrVersionMismatch)
+		return nil, false
+	}
+
+	return versions, true
+}
+
+// etagMatches проверяет, совпадает ли тег с одним из тегов заголовка If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package route
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/xlsx"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mimeCSV    = "text/csv"
+	mimeXLSX   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// exportFormats сопоставляет параметр format с MIME-типом и расширением файла
+var exportFormats = map[string]struct{ mime, ext string }{
+	"csv":    {mimeCSV, "csv"},
+	"xlsx":   {mimeXLSX, "xlsx"},
+	"ndjson": {mimeNDJSON, "ndjson"},
+}
+
+// stockEncoder пишет строки выгрузки остатков в конкретном формате
+type stockEncoder interface {
+	Encode(controller.StockExportRow) error
+	Close() error
+}
+
+func registerExportRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/export/stock", func(c *gin.Context) {
+		warehouseID := 0
+		if v := c.Query("warehouse_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				abortWithBadRequest(c, "invalid warehouse ID")
+				return
+			}
+			if err := controller.WarehouseExists(db, id); err != nil {
+				abortWithError(c, err)
+				return
+			}
+			warehouseID = id
+		}
+
+		mime := c.NegotiateFormat(mimeCSV, mimeXLSX, mimeNDJSON)
+		ext := exportFormats["csv"].ext
+		if format := c.Query("format"); format != "" {
+			f, ok := exportFormats[format]
+			if !ok {
+				abortWithBadRequest(c, "unknown export format")
+				return
+			}
+			mime, ext = f.mime, f.ext
+		} else {
+			for _, f := range exportFormats {
+				if f.mime == mime {
+					ext = f.ext
+				}
+			}
+		}
+		if mime == "" {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, ErrorResponse{
+				Code:    http.StatusNotAcceptable,
+				Message: "supported formats: text/csv, " + mimeXLSX + ", " + mimeNDJSON,
+			})
+			return
+		}
+
+		scope := "all"
+		if warehouseID != 0 {
+			scope = "warehouse-" + strconv.Itoa(warehouseID)
+		}
+		filename := fmt.Sprintf("stock-%s-%s.%s", scope, time.Now().Format("20060102"), ext)
+
+		c.Header("Content-Type", mime)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+
+		out := bufio.NewWriter(c.Writer)
+		enc, err := newStockEncoder(out, mime)
+		if err == nil {
+			err = controller.ExportStock(db, warehouseID, enc.Encode)
+		}
+		if err == nil {
+			err = enc.Close()
+		}
+		if err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			// заголовки уже отправлены, поэтому ошибку можно только записать в журнал
+			logging.GetLogger(c.Request.Context()).WithError(err).Error("stock export failed")
+			c.Abort()
+		}
+	})
+}
+
+func newStockEncoder(w io.Writer, mime string) (stockEncoder, error) {
+	switch mime {
+	case mimeXLSX:
+		xw, err := xlsx.NewWriter(w, "Stock")
+		if err != nil {
+			return nil, err
+		}
+		enc := &xlsxStockEncoder{w: xw}
+		return enc, xw.WriteRow(stringsToValues(controller.StockExportColumns)...)
+	case mimeNDJSON:
+		return &ndjsonStockEncoder{enc: json.NewEncoder(w)}, nil
+	default:
+		cw := csv.NewWriter(w)
+		return &csvStockEncoder{w: cw}, cw.Write(controller.StockExportColumns)
+	}
+}
+
+type csvStockEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvStockEncoder) Encode(r controller.StockExportRow) error {
+	values := r.Values()
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = fmt.Sprint(v)
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvStockEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonStockEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonStockEncoder) Encode(r controller.StockExportRow) error {
+	return e.enc.Encode(r)
+}
+
+func (e *ndjsonStockEncoder) Close() error {
+	return nil
+}
+
+type xlsxStockEncoder struct {
+	w *xlsx.Writer
+}
+
+func (e *xlsxStockEncoder) Encode(r controller.StockExportRow) error {
+	return e.w.WriteRow(r.Values()...)
+}
+
+func (e *xlsxStockEncoder) Close() error {
+	return e.w.Close()
+}
+
+func stringsToValues(s []string) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, v := range s {
+		values[i] = v
+	}
+	return values
+}
@@ -0,0 +1,71 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/geo"
+	"github.com/gin-gonic/gin"
+)
+
+func registerGeoRoutes(r *gin.Engine, db *sql.DB) {
+	r.PUT("/warehouses/:id/location", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var l controller.WarehouseLocation
+		if err := c.ShouldBindJSON(&l); err != nil {
+			abortWithBadRequest(c, "invalid location")
+			return
+		}
+
+		if err := controller.SetWarehouseLocation(db, id, l); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, l)
+	})
+
+	r.POST("/warehouses/nearest", func(c *gin.Context) {
+		var q controller.NearestQuery
+		if err := c.ShouldBindJSON(&q); err != nil {
+			abortWithBadRequest(c, "invalid query")
+			return
+		}
+
+		matches, err := controller.FindNearestWarehouses(db, q)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, matches)
+	})
+}
+
+// nearLocation читает точку доставки из параметров latitude и longitude. Если их нет, возвращается nil;
+// если задан только один или значение некорректно, запрос завершается с ошибкой 400 и ok равно false.
+func nearLocation(c *gin.Context) (point *geo.Point, ok bool) {
+	latitude, hasLatitude := c.GetQuery("latitude")
+	longitude, hasLongitude := c.GetQuery("longitude")
+	if !hasLatitude && !hasLongitude {
+		return nil, true
+	}
+
+	var p geo.Point
+	var errLat, errLon error
+	p.Latitude, errLat = strconv.ParseFloat(latitude, 64)
+	p.Longitude, errLon = strconv.ParseFloat(longitude, 64)
+	if errLat != nil || errLon != nil || !p.Valid() {
+		abortWithBadRequest(c, "invalid latitude or longitude")
+		return nil, false
+	}
+
+	return &p, true
+}
@@ -0,0 +1,119 @@
+package route
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = maxImportSize
+)
+
+// idempotency повторяет сохраненный ответ для POST и DELETE запросов с заголовком Idempotency-Key.
+// Ключ привязан к методу, пути и телу запроса: повтор с другим запросом отклоняется,
+// а ответы с ошибкой сервера не сохраняются и паника обработчика освобождает ключ,
+// чтобы запрос можно было повторить.
+func idempotency(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodDelete) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithBadRequest(c, "idempotency key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentRequestBytes))
+		if err != nil {
+			abortWithBadRequest(c, "request body is too large")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		stored, err := controller.BeginIdempotentRequest(db, key, requestHash)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if stored != nil {
+			replayIdempotentResponse(c, stored, requestHash)
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				if err := controller.ReleaseIdempotencyKey(db, key, requestHash); err != nil {
+					logging.GetLogger(c.Request.Context()).WithError(err).Error("failed to release idempotency key")
+				}
+				panic(r)
+			}
+		}()
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		status := w.Status()
+		if status >= http.StatusInternalServerError {
+			err = controller.ReleaseIdempotencyKey(db, key, requestHash)
+		} else {
+			err = controller.CompleteIdempotentRequest(db, key, requestHash, status, w.Header().Get("Content-Type"), w.body.Bytes())
+		}
+		if err != nil {
+			logging.GetLogger(c.Request.Context()).WithError(err).Error("failed to store idempotent response")
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, stored *controller.IdempotentResponse, requestHash string) {
+	switch {
+	case stored.RequestHash != requestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "idempotency key was already used for a different request",
+		})
+	case stored.StatusCode == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "request with this idempotency key is still in progress",
+		})
+	default:
+		c.Header(idempotentReplayedHeader, "true")
+		c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
+
+// recordingWriter копирует тело ответа, чтобы сохранить его для повторов
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,84 @@
+package route
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize ограничивает размер загружаемого файла импорта
+const maxImportSize = 64 << 20
+
+func registerImportRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/products/import", func(c *gin.Context) {
+		var opts controller.ImportOptions
+		var err error
+		if opts.DryRun, err = strconv.ParseBool(c.DefaultQuery("dry_run", "false")); err != nil {
+			abortWithBadRequest(c, "invalid dry_run value")
+			return
+		}
+		if opts.ChunkSize, err = strconv.Atoi(c.DefaultQuery("chunk_size", "0")); err != nil {
+			abortWithBadRequest(c, "invalid chunk size")
+			return
+		}
+		if opts.FromLine, err = strconv.Atoi(c.DefaultQuery("from_line", "0")); err != nil {
+			abortWithBadRequest(c, "invalid from_line value")
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+		var body io.Reader = c.Request.Body
+		format := c.Query("format")
+		if c.ContentType() == "multipart/form-data" {
+			file, header, err := c.Request.FormFile("file")
+			if err != nil {
+				abortWithBadRequest(c, "file is required")
+				return
+			}
+			defer file.Close()
+
+			body = file
+			if format == "" {
+				format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+			}
+		}
+		if format == "" {
+			format = importFormat(c.ContentType())
+		}
+
+		report, err := controller.ImportProducts(db, body, format, opts)
+		if err != nil && report == nil {
+			abortWithError(c, err)
+			return
+		}
+		if err != nil {
+			// часть строк уже записана: отчет подсказывает, с какой строки продолжить
+			c.AbortWithStatusJSON(errorStatus(err), report)
+			return
+		}
+		if len(report.Errors) > 0 && !report.DryRun {
+			c.AbortWithStatusJSON(http.StatusBadRequest, report)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	})
+}
+
+func importFormat(contentType string) string {
+	switch contentType {
+	case "text/csv":
+		return controller.ImportFormatCSV
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return controller.ImportFormatXLSX
+	default:
+		return ""
+	}
+}
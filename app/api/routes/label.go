@@ -0,0 +1,89 @@
+package route
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/label"
+	"github.com/gin-gonic/gin"
+)
+
+func registerLabelRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/labels/products/:code", func(c *gin.Context) {
+		copies, err := strconv.Atoi(c.DefaultQuery("copies", "1"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid number of copies")
+			return
+		}
+
+		labels, err := controller.GetProductLabels(db, c.Param("code"), copies)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		renderLabels(c, labels)
+	})
+
+	r.GET("/labels/bins/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid location ID")
+			return
+		}
+
+		labels, err := controller.GetBinLabel(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		renderLabels(c, labels)
+	})
+
+	r.GET("/labels/receipts/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid receipt ID")
+			return
+		}
+
+		labels, err := controller.GetReceiptLabels(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		renderLabels(c, labels)
+	})
+}
+
+// renderLabels печатает этикетки в формате из параметра format (по умолчанию PDF)
+func renderLabels(c *gin.Context, labels []label.Label) {
+	format := c.DefaultQuery("format", label.FormatPDF)
+	contentType, err := label.ContentType(format)
+	if err != nil {
+		abortWithBadRequest(c, err.Error())
+		return
+	}
+	if len(labels) == 0 {
+		abortWithBadRequest(c, "nothing to print")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := label.Render(&buf, format, labels); err != nil {
+		if errors.Is(err, label.ErrUnsupportedCode) || errors.Is(err, label.ErrCodeTooLong) {
+			abortWithBadRequest(c, err.Error())
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,118 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerLocationRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/locations", func(c *gin.Context) {
+		var l controller.Location
+		if err := c.ShouldBindJSON(&l); err != nil {
+			abortWithBadRequest(c, "invalid location data")
+			return
+		}
+
+		if err := controller.CreateLocation(db, &l); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, l)
+	})
+
+	r.GET("/warehouses/:id/locations", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		locations, err := controller.GetLocations(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, locations)
+	})
+
+	r.POST("/locations/:id/receive", func(c *gin.Context) {
+		id, req, ok := bindBinStockRequest(c)
+		if !ok {
+			return
+		}
+
+		if err := controller.ReceiveToBin(db, id, req.Code, req.Quantity); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.POST("/locations/:id/move", func(c *gin.Context) {
+		id, req, ok := bindBinStockRequest(c)
+		if !ok {
+			return
+		}
+
+		if err := controller.MoveBinStock(db, id, req.ToLocationID, req.Code, req.Quantity); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.POST("/locations/:id/pick", func(c *gin.Context) {
+		id, req, ok := bindBinStockRequest(c)
+		if !ok {
+			return
+		}
+
+		if err := controller.PickFromBin(db, id, req.Code, req.Quantity); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.POST("/pick-list", func(c *gin.Context) {
+		var productCodes []string
+		if err := c.ShouldBindJSON(&productCodes); err != nil {
+			abortWithBadRequest(c, "invalid request body")
+			return
+		}
+
+		lines, err := controller.GetPickList(db, productCodes)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lines)
+	})
+}
+
+func bindBinStockRequest(c *gin.Context) (int, controller.BinStockRequest, bool) {
+	var req controller.BinStockRequest
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithBadRequest(c, "invalid location ID")
+		return 0, req, false
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithBadRequest(c, "invalid request body")
+		return 0, req, false
+	}
+
+	return id, req, true
+}
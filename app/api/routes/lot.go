@@ -0,0 +1,53 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerLotRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/lots", func(c *gin.Context) {
+		var l controller.StockLot
+		if err := c.ShouldBindJSON(&l); err != nil {
+			abortWithBadRequest(c, "invalid lot data")
+			return
+		}
+
+		if err := controller.ReceiveLot(db, &l); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, l)
+	})
+
+	r.GET("/products/:code/lots", func(c *gin.Context) {
+		lots, err := controller.GetProductLots(db, c.Param("code"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lots)
+	})
+
+	r.GET("/lots/expiring", func(c *gin.Context) {
+		days, err := strconv.Atoi(c.Query("days"))
+		if err != nil || days < 0 {
+			abortWithBadRequest(c, "invalid number of days")
+			return
+		}
+
+		lots, err := controller.GetExpiringLots(db, days)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lots)
+	})
+}
@@ -0,0 +1,38 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerOrderRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/orders/:id", func(c *gin.Context) {
+		o, err := controller.GetOrder(db, c.Param("id"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, o)
+	})
+
+	r.GET("/order-event-failures", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid limit")
+			return
+		}
+
+		failures, err := controller.GetOrderEventFailures(db, limit)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, failures)
+	})
+}
@@ -0,0 +1,64 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerReceiptRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/receipts", func(c *gin.Context) {
+		var rc controller.Receipt
+		if err := c.ShouldBindJSON(&rc); err != nil {
+			abortWithBadRequest(c, "invalid receipt data")
+			return
+		}
+
+		if err := controller.CreateReceipt(db, &rc); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, rc)
+	})
+
+	r.GET("/receipts/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid receipt ID")
+			return
+		}
+
+		rc, err := controller.GetReceipt(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, rc)
+	})
+
+	r.POST("/receipts/:id/receive", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid receipt ID")
+			return
+		}
+
+		if err := controller.PostReceipt(db, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		rc, err := controller.GetReceipt(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, rc)
+	})
+}
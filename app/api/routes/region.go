@@ -0,0 +1,102 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerRegionRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/regions", func(c *gin.Context) {
+		var region controller.Region
+		if err := c.ShouldBindJSON(&region); err != nil {
+			abortWithBadRequest(c, "invalid region")
+			return
+		}
+
+		if err := controller.CreateRegion(db, &region); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, region)
+	})
+
+	r.GET("/regions", func(c *gin.Context) {
+		regions, err := controller.GetRegions(db)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, regions)
+	})
+
+	r.PUT("/regions/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid region ID")
+			return
+		}
+
+		var region controller.Region
+		if err := c.ShouldBindJSON(&region); err != nil {
+			abortWithBadRequest(c, "invalid region")
+			return
+		}
+		region.ID = id
+
+		if err := controller.UpdateRegion(db, &region); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, region)
+	})
+
+	r.GET("/regions/:id/remaining-products", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid region ID")
+			return
+		}
+
+		stock, err := controller.GetRegionStock(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, stock)
+	})
+
+	r.PUT("/warehouses/:id/region", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var body controller.WarehouseRegion
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abortWithBadRequest(c, "invalid region")
+			return
+		}
+
+		if err := controller.SetWarehouseRegion(db, id, body.RegionID); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		w, err := controller.GetWarehouse(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, w)
+	})
+}
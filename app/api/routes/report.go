@@ -0,0 +1,61 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerReportRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/reports/replenishment", func(c *gin.Context) {
+		var opts controller.ReplenishmentOptions
+		var err error
+		if opts.WarehouseID, err = strconv.Atoi(c.DefaultQuery("warehouse_id", "0")); err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+		if opts.WindowDays, err = strconv.Atoi(c.DefaultQuery("window_days", "30")); err != nil {
+			abortWithBadRequest(c, "invalid window_days")
+			return
+		}
+		if opts.CoverDays, err = strconv.Atoi(c.DefaultQuery("cover_days", "14")); err != nil {
+			abortWithBadRequest(c, "invalid cover_days")
+			return
+		}
+		if opts.All, err = strconv.ParseBool(c.DefaultQuery("all", "false")); err != nil {
+			abortWithBadRequest(c, "invalid all")
+			return
+		}
+
+		lines, err := controller.GetReplenishmentReport(db, opts)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lines)
+	})
+
+	r.GET("/reports/valuation", func(c *gin.Context) {
+		warehouseID, err := strconv.Atoi(c.DefaultQuery("warehouse_id", "0"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		report, err := controller.GetInventoryValuation(db, controller.ValuationOptions{
+			WarehouseID: warehouseID,
+			AsOf:        c.Query("as_of"),
+			Method:      c.Query("method"),
+		})
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
 
 	_ "github.com/DmitriiKumancev/lamoda-test/docs"
 	"github.com/gin-gonic/gin"
@@ -19,8 +20,9 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-func NewRouter(db *sql.DB) *gin.Engine {
+func NewRouter(db *sql.DB, bus *events.Bus) *gin.Engine {
 	r := gin.Default()
+	r.Use(idempotency(db))
 
 	r.GET("/swagger/*any", gin.WrapH(httpSwagger.Handler()))
 	r.GET("/swagger", func(c *gin.Context) {
@@ -44,6 +46,51 @@ func NewRouter(db *sql.DB) *gin.Engine {
 		c.JSON(http.StatusCreated, gin.H{"id": w.ID})
 	})
 
+	r.GET("/warehouses/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		w, err := controller.GetWarehouse(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		setVersionETag(c, w.Version)
+		c.JSON(http.StatusOK, w)
+	})
+
+	r.PUT("/warehouses/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return
+		}
+
+		var w controller.Warehouse
+		if err := c.ShouldBindJSON(&w); err != nil {
+			abortWithBadRequest(c, "invalid warehouse data")
+			return
+		}
+		w.ID = id
+
+		if err := controller.UpdateWarehouse(db, &w, versions); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		setVersionETag(c, w.Version)
+		c.JSON(http.StatusOK, w)
+	})
+
 	r.POST("/create-product", func(c *gin.Context) {
 		var p controller.Product
 		err := c.BindJSON(&p)
@@ -54,7 +101,7 @@ func NewRouter(db *sql.DB) *gin.Engine {
 
 		err = controller.CreateProduct(db, &p)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.AbortWithStatusJSON(errorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -68,8 +115,13 @@ func NewRouter(db *sql.DB) *gin.Engine {
 			return
 		}
 
-		if err := controller.DeleteProduct(db, id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return
+		}
+
+		if err := controller.DeleteProduct(db, id, versions); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -86,10 +138,66 @@ func NewRouter(db *sql.DB) *gin.Engine {
 			return
 		}
 
-		err := controller.ReserveProducts(db, productCodes)
+		backorder, err := strconv.ParseBool(c.DefaultQuery("backorder", "false"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Code:    http.StatusInternalServerError,
+			abortWithBadRequest(c, "invalid backorder")
+			return
+		}
+		near, ok := nearLocation(c)
+		if !ok {
+			return
+		}
+		if v := c.Query("region_id"); v != "" {
+			regionID, err := strconv.Atoi(v)
+			if err != nil {
+				abortWithBadRequest(c, "invalid region_id")
+				return
+			}
+			if backorder || near != nil {
+				abortWithBadRequest(c, "region_id cannot be combined with backorder or distance-based allocation")
+				return
+			}
+
+			allocations, err := controller.ReserveProductsInRegion(db, regionID, productCodes)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, allocations)
+			return
+		}
+		if near != nil {
+			if backorder {
+				abortWithBadRequest(c, "backorder cannot be combined with distance-based allocation")
+				return
+			}
+
+			allocations, err := controller.ReserveProductsNearest(db, productCodes, *near)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, allocations)
+			return
+		}
+		if backorder {
+			result, err := controller.ReserveProductsWithBackorder(db, productCodes, c.Query("reference"))
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		err = controller.ReserveProducts(db, productCodes)
+		if err != nil {
+			status := errorStatus(err)
+			c.JSON(status, ErrorResponse{
+				Code:    status,
 				Message: err.Error(),
 			})
 			return
@@ -110,8 +218,9 @@ func NewRouter(db *sql.DB) *gin.Engine {
 
 		err := controller.ReleaseProducts(db, productCodes)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Code:    http.StatusInternalServerError,
+			status := errorStatus(err)
+			c.JSON(status, ErrorResponse{
+				Code:    status,
 				Message: err.Error(),
 			})
 			return
@@ -131,6 +240,21 @@ func NewRouter(db *sql.DB) *gin.Engine {
 			return
 		}
 
+		etag, err := controller.GetRemainingProductsETag(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		etag = `"` + etag + `"`
+		c.Header("ETag", etag)
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
 		products, err := controller.GetRemainingProducts(db, id)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -143,5 +267,28 @@ func NewRouter(db *sql.DB) *gin.Engine {
 		c.JSON(http.StatusOK, products)
 	})
 
+	registerCycleCountRoutes(r, db)
+	registerLocationRoutes(r, db)
+	registerLotRoutes(r, db)
+	registerReceiptRoutes(r, db)
+	registerSerialRoutes(r, db)
+	registerBundleRoutes(r, db)
+	registerCatalogRoutes(r, db)
+	registerBarcodeRoutes(r, db)
+	registerLabelRoutes(r, db)
+	registerImportRoutes(r, db)
+	registerExportRoutes(r, db)
+	registerWebhookRoutes(r, db)
+	registerStreamRoutes(r, db, bus)
+	registerOrderRoutes(r, db)
+	registerStockAlertRoutes(r, db)
+	registerReportRoutes(r, db)
+	registerBackorderRoutes(r, db)
+	registerChannelRoutes(r, db)
+	registerCapacityRoutes(r, db)
+	registerGeoRoutes(r, db)
+	registerCalendarRoutes(r, db)
+	registerRegionRoutes(r, db)
+
 	return r
 }
@@ -0,0 +1,42 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerSerialRoutes(r *gin.Engine, db *sql.DB) {
+	serialHandler := func(fn func(db *sql.DB, serials []string) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var serials []string
+			if err := c.ShouldBindJSON(&serials); err != nil {
+				abortWithBadRequest(c, "invalid request body")
+				return
+			}
+
+			if err := fn(db, serials); err != nil {
+				abortWithError(c, err)
+				return
+			}
+
+			c.Status(http.StatusOK)
+		}
+	}
+
+	r.POST("/reserve-serials", serialHandler(controller.ReserveSerials))
+	r.POST("/release-serials", serialHandler(controller.ReleaseSerials))
+	r.POST("/ship-serials", serialHandler(controller.ShipSerials))
+
+	r.GET("/serials/:serial", func(c *gin.Context) {
+		u, err := controller.GetSerial(db, c.Param("serial"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, u)
+	})
+}
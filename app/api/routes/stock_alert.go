@@ -0,0 +1,69 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerStockAlertRoutes(r *gin.Engine, db *sql.DB) {
+	r.PUT("/products/:code/thresholds", func(c *gin.Context) {
+		var t controller.StockThresholds
+		if err := c.ShouldBindJSON(&t); err != nil {
+			abortWithBadRequest(c, "invalid thresholds")
+			return
+		}
+
+		if err := controller.SetProductThresholds(db, c.Param("code"), t); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, t)
+	})
+
+	r.PUT("/warehouses/:id/thresholds", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var t controller.StockThresholds
+		if err := c.ShouldBindJSON(&t); err != nil {
+			abortWithBadRequest(c, "invalid thresholds")
+			return
+		}
+
+		if err := controller.SetWarehouseThresholds(db, id, t); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, t)
+	})
+
+	r.GET("/stock-alerts", func(c *gin.Context) {
+		warehouseID, err := strconv.Atoi(c.DefaultQuery("warehouse_id", "0"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+		includeResolved, err := strconv.ParseBool(c.DefaultQuery("include_resolved", "false"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid include_resolved")
+			return
+		}
+
+		alerts, err := controller.GetStockAlerts(db, warehouseID, c.Query("level"), includeResolved)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, alerts)
+	})
+}
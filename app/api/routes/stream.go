@@ -0,0 +1,134 @@
+package route
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamPollInterval — как часто поток проверяет новые опубликованные события. Уведомление
+	// шины будит поток раньше, но события читаются из базы, поэтому поток работает на любом экземпляре.
+	streamPollInterval = time.Second
+	// streamHeartbeat — период комментариев, которые не дают прокси закрыть соединение
+	streamHeartbeat = 15 * time.Second
+)
+
+func registerStreamRoutes(r *gin.Engine, db *sql.DB, bus *events.Bus) {
+	r.GET("/stream/stock", streamStock(db, bus))
+}
+
+//	@Summary		Stream stock changes.
+//	@Description	Stream stock.changed events as Server-Sent Events for a warehouse and/or a comma-separated list of codes. Each event carries its position in the stream, so a reconnecting client that sends Last-Event-ID (or last_event_id) first receives the events it missed.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			warehouse_id	query	int		false	"Warehouse ID"
+//	@Param			codes			query	string	false	"Comma-separated product codes or barcodes"
+//	@Param			Last-Event-ID	header	int		false	"Position of the last received event"
+//	@Success		200
+//	@Failure		400	{object}	ErrorResponse	"Invalid filter"
+//	@Failure		404	{object}	ErrorResponse	"Warehouse or product not found"
+//	@Router			/stream/stock [get]
+//
+// streamStock отдает поток изменений остатков, читая опубликованные события из базы по позиции
+func streamStock(db *sql.DB, bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		warehouseID, err := strconv.Atoi(c.DefaultQuery("warehouse_id", "0"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid warehouse ID")
+			return
+		}
+
+		var codes []string
+		for _, code := range strings.Split(c.Query("codes"), ",") {
+			if code = strings.TrimSpace(code); code != "" {
+				codes = append(codes, code)
+			}
+		}
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.DefaultQuery("last_event_id", "0")
+		}
+		lastID, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			abortWithBadRequest(c, "invalid Last-Event-ID")
+			return
+		}
+
+		filter, err := controller.NewStockStreamFilter(db, warehouseID, codes)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		// новый клиент получает события, опубликованные после подключения
+		if lastID == 0 {
+			if lastID, err = controller.GetStockStreamPosition(db); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		wake := make(chan struct{}, 1)
+		unsubscribe := bus.Subscribe(func(e events.Event) {
+			if !filter.Match(e) {
+				return
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		})
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		poll := time.NewTicker(streamPollInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			for {
+				batch, err := controller.GetStockEventsSince(db, lastID, filter)
+				if err != nil {
+					return
+				}
+				for _, e := range batch {
+					writeStreamEvent(c, e)
+					lastID = e.ID
+				}
+				c.Writer.Flush()
+				if len(batch) < controller.StockStreamReplayBatch {
+					break
+				}
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(c.Writer, ": ping\n\n")
+			case <-poll.C:
+			case <-wake:
+			}
+		}
+	}
+}
+
+func writeStreamEvent(c *gin.Context, e events.Event) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Payload)
+}
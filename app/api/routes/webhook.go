@@ -0,0 +1,120 @@
+package route
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/gin-gonic/gin"
+)
+
+func registerWebhookRoutes(r *gin.Engine, db *sql.DB) {
+	r.POST("/webhooks", func(c *gin.Context) {
+		var s controller.WebhookSubscription
+		if err := c.ShouldBindJSON(&s); err != nil {
+			abortWithBadRequest(c, "invalid subscription data")
+			return
+		}
+
+		if err := controller.CreateWebhookSubscription(db, &s); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, s)
+	})
+
+	r.GET("/webhooks", func(c *gin.Context) {
+		subscriptions, err := controller.GetWebhookSubscriptions(db)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, subscriptions)
+	})
+
+	r.GET("/webhooks/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid subscription ID")
+			return
+		}
+
+		s, err := controller.GetWebhookSubscription(db, id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, s)
+	})
+
+	r.DELETE("/webhooks/:id", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid subscription ID")
+			return
+		}
+
+		if err := controller.DeleteWebhookSubscription(db, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	r.GET("/webhooks/:id/deliveries", func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid subscription ID")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid limit")
+			return
+		}
+
+		deliveries, err := controller.GetWebhookDeliveries(db, id, c.Query("status"), limit)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	})
+
+	r.GET("/webhook-deliveries", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil {
+			abortWithBadRequest(c, "invalid limit")
+			return
+		}
+
+		deliveries, err := controller.GetAllWebhookDeliveries(db, c.Query("status"), limit)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, deliveries)
+	})
+
+	r.POST("/webhook-deliveries/:id/retry", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			abortWithBadRequest(c, "invalid delivery ID")
+			return
+		}
+
+		if err := controller.RetryWebhookDelivery(db, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	})
+}
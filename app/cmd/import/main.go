@@ -0,0 +1,83 @@
+// Команда import загружает товары из файла CSV или XLSX так же, как POST /products/import.
+//
+//	go run ./cmd/import -file products.csv -dry-run
+//	go run ./cmd/import -file products.xlsx -chunk 1000 -from-line 5002
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/internal/config"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/barcode"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/client/postgresql"
+)
+
+func main() {
+	configPath := flag.String("config", "../../configs/app.env", "path to the config file")
+	filePath := flag.String("file", "", "CSV or XLSX file with products")
+	format := flag.String("format", "", "file format: csv or xlsx (by file extension if empty)")
+	dryRun := flag.Bool("dry-run", false, "validate the file without writing")
+	chunkSize := flag.Int("chunk", 0, "rows per transaction, 0 imports the whole file in one transaction")
+	fromLine := flag.Int("from-line", 0, "first file line to import when resuming")
+	flag.Parse()
+
+	if *filePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*filePath)), ".")
+	}
+
+	cfg, err := config.ReadConfig(*configPath)
+	if err != nil {
+		log.Fatal("failed to read config: ", err)
+	}
+
+	validator, err := barcode.NewValidator(cfg.ProductCodeFormats, cfg.ProductSKUPattern)
+	if err != nil {
+		log.Fatal(err)
+	}
+	controller.SetCodeValidator(validator)
+
+	pgConfig := postgresql.NewPgConfig(cfg.DBUser, cfg.DBPass, cfg.DBHost, cfg.DBPort, cfg.DBName)
+	db, err := postgresql.NewClient(context.Background(), 5, 3*time.Second, pgConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	f, err := os.Open(*filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	report, importErr := controller.ImportProducts(db, f, *format, controller.ImportOptions{
+		DryRun:    *dryRun,
+		ChunkSize: *chunkSize,
+		FromLine:  *fromLine,
+	})
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	}
+
+	switch {
+	case importErr != nil && report != nil && report.LastLine > 0:
+		log.Fatalf("import failed: %v; resume with -from-line %d", importErr, report.LastLine+1)
+	case importErr != nil:
+		log.Fatal("import failed: ", importErr)
+	case len(report.Errors) > 0:
+		os.Exit(1)
+	}
+}
@@ -9,9 +9,13 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
 	route "github.com/DmitriiKumancev/lamoda-test/api/routes"
 	config "github.com/DmitriiKumancev/lamoda-test/internal/config"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/barcode"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/broker"
 	"github.com/DmitriiKumancev/lamoda-test/pkg/client/postgresql"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
 	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
 
 	"github.com/gin-gonic/gin"
@@ -24,6 +28,10 @@ type App struct {
 	router     *gin.Engine
 	httpServer *http.Server
 	pgClient   *sql.DB
+	bus        *events.Bus
+	publishers []controller.OutboxPublisher
+	// orders — брокер в памяти для ORDER_BROKER=memory; смещения группы переживают переподключения потребителя
+	orders *broker.Memory
 }
 
 func NewApp(ctx context.Context, config *config.Config) (*App, error) {
@@ -36,13 +44,34 @@ func NewApp(ctx context.Context, config *config.Config) (*App, error) {
 		return nil, err
 	}
 
-	router := route.NewRouter(pgClient)
+	validator, err := barcode.NewValidator(config.ProductCodeFormats, config.ProductSKUPattern)
+	if err != nil {
+		return nil, err
+	}
+	controller.SetCodeValidator(validator)
+	controller.SetIdempotencyPolicy(config.IdempotencyRetention, config.IdempotencyLease)
+	controller.SetOutboxRetention(config.OutboxRetention)
+	controller.SetWebhookRetryPolicy(config.WebhookMaxAttempts, config.WebhookRetryDelay)
+	controller.SetWebhookRetention(config.WebhookRetention)
+	controller.SetOrderRetryPolicy(config.OrderMaxAttempts, config.OrderRetryDelay)
+	controller.SetOrderDedupRetention(config.OrderDedupRetention)
+
+	bus := events.NewBus()
+	publishers, err := newOutboxPublishers(config, pgClient, bus)
+	if err != nil {
+		return nil, err
+	}
+
+	router := route.NewRouter(pgClient, bus)
 	logging.GetLogger(ctx).Info("router initializing")
 
 	return &App{
-		cfg:      config,
-		router:   router,
-		pgClient: pgClient,
+		cfg:        config,
+		router:     router,
+		pgClient:   pgClient,
+		bus:        bus,
+		publishers: publishers,
+		orders:     broker.NewMemory(),
 	}, nil
 }
 
@@ -53,6 +82,11 @@ func (a *App) Run(ctx context.Context) error {
 			logging.GetLogger(ctx).Error(err)
 		}
 	}()
+	defer func() {
+		if err := closePublishers(a.publishers); err != nil {
+			logging.GetLogger(ctx).Error(err)
+		}
+	}()
 
 	grp, ctx := errgroup.WithContext(ctx)
 
@@ -60,9 +94,65 @@ func (a *App) Run(ctx context.Context) error {
 		return a.startHTTP(ctx)
 	})
 
+	grp.Go(func() error {
+		return a.purgeExpired(ctx)
+	})
+
+	grp.Go(func() error {
+		return a.relayOutbox(ctx)
+	})
+
+	grp.Go(func() error {
+		return a.deliverWebhooks(ctx)
+	})
+
+	grp.Go(func() error {
+		return a.consumeOrders(ctx)
+	})
+
+	grp.Go(func() error {
+		return a.applyCalendars(ctx)
+	})
+
 	return grp.Wait()
 }
 
+// purgeTask — очистка таблицы от записей с истекшим сроком хранения
+type purgeTask struct {
+	name  string
+	purge func(db *sql.DB) (int64, error)
+}
+
+// purgeExpired периодически удаляет записи с истекшим сроком хранения: ключи идемпотентности,
+// опубликованные события outbox, завершенные доставки вебхуков и отметки об обработанных сообщениях
+func (a *App) purgeExpired(ctx context.Context) error {
+	tasks := []purgeTask{
+		{name: "idempotency keys", purge: controller.PurgeIdempotencyKeys},
+		{name: "outbox events", purge: controller.PurgeOutboxEvents},
+		{name: "webhook deliveries", purge: controller.PurgeWebhookDeliveries},
+		{name: "consumed messages", purge: controller.PurgeConsumedMessages},
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, task := range tasks {
+				n, err := task.purge(a.pgClient)
+				if err != nil {
+					logging.GetLogger(ctx).WithError(err).Errorf("failed to purge %s", task.name)
+					continue
+				}
+				logging.GetLogger(ctx).WithField("count", n).Infof("expired %s purged", task.name)
+			}
+		}
+	}
+}
+
 func (a *App) startHTTP(ctx context.Context) error {
 	logging.GetLogger(ctx).WithFields(map[string]interface{}{
 		"IP":   a.cfg.IP,
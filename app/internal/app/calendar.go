@@ -0,0 +1,31 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
+)
+
+// applyCalendars переключает доступность складов по их календарям, пока не будет отменен контекст
+func (a *App) applyCalendars(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.CalendarPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			n, err := controller.ApplyWarehouseCalendars(a.pgClient, now)
+			if err != nil {
+				logging.GetLogger(ctx).WithError(err).Error("failed to apply warehouse calendars")
+				continue
+			}
+			if n > 0 {
+				logging.GetLogger(ctx).WithField("count", n).Info("warehouse availability switched by calendar")
+			}
+		}
+	}
+}
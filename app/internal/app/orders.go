@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/broker"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
+)
+
+// consumeOrders применяет события заказов из брокера, пока не будет отменен контекст.
+// После ошибки потребитель переподключается и продолжает с последнего зафиксированного смещения.
+func (a *App) consumeOrders(ctx context.Context) error {
+	if a.cfg.OrderBroker == "" {
+		return nil
+	}
+
+	for {
+		err := a.consumeOrdersOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logging.GetLogger(ctx).WithError(err).Error("order consumer stopped, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(a.cfg.OrderRetryDelay):
+		}
+	}
+}
+
+func (a *App) consumeOrdersOnce(ctx context.Context) error {
+	consumer, err := a.newOrderConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	for {
+		if _, err := controller.ConsumeOrderBatch(ctx, a.pgClient, consumer); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *App) newOrderConsumer(ctx context.Context) (broker.Consumer, error) {
+	switch a.cfg.OrderBroker {
+	case "kafka-rest":
+		return broker.NewKafkaREST(ctx, a.cfg.OrderBrokerURL, a.cfg.OrderConsumerGroup, a.cfg.OrderTopic, a.cfg.OrderPollTimeout)
+	case "memory":
+		return a.orders.Consumer(a.cfg.OrderTopic, a.cfg.OrderConsumerGroup, a.cfg.OrderPollTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown order broker %q", a.cfg.OrderBroker)
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DmitriiKumancev/lamoda-test/api/controller"
+	config "github.com/DmitriiKumancev/lamoda-test/internal/config"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/events"
+	"github.com/DmitriiKumancev/lamoda-test/pkg/logging"
+)
+
+// newOutboxPublishers собирает получателей событий outbox из настроек: bus, file и webhook.
+// Подписки на вебхуки получают события всегда.
+func newOutboxPublishers(cfg *config.Config, db *sql.DB, bus *events.Bus) ([]controller.OutboxPublisher, error) {
+	publishers := []controller.OutboxPublisher{{Name: "webhooks", Publisher: controller.WebhookFanout(db)}}
+	for _, name := range cfg.OutboxPublishers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "bus":
+			publishers = append(publishers, controller.OutboxPublisher{Name: name, Publisher: bus})
+		case "file":
+			p, err := events.NewFilePublisher(cfg.OutboxFile)
+			if err != nil {
+				closePublishers(publishers)
+				return nil, err
+			}
+			publishers = append(publishers, controller.OutboxPublisher{Name: name, Publisher: p})
+		case "webhook":
+			if cfg.OutboxWebhookURL == "" {
+				closePublishers(publishers)
+				return nil, fmt.Errorf("OUTBOX_WEBHOOK_URL is required for the webhook publisher")
+			}
+			publishers = append(publishers, controller.OutboxPublisher{Name: name, Publisher: events.NewWebhookPublisher(cfg.OutboxWebhookURL)})
+		case "":
+		default:
+			closePublishers(publishers)
+			return nil, fmt.Errorf("unknown outbox publisher %q", name)
+		}
+	}
+
+	return publishers, nil
+}
+
+// closePublishers закрывает получателей, которые держат ресурсы, например файл
+func closePublishers(publishers []controller.OutboxPublisher) error {
+	var errs []error
+	for _, p := range publishers {
+		if c, ok := p.Publisher.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close %s publisher: %w", p.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// relayOutbox пересылает события outbox получателям, пока не будет отменен контекст
+func (a *App) relayOutbox(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.OutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := controller.RelayOutbox(ctx, a.pgClient, a.cfg.OutboxBatchSize, a.publishers)
+			if err != nil {
+				logging.GetLogger(ctx).WithError(err).Error("failed to relay outbox events")
+				break
+			}
+			if n < a.cfg.OutboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverWebhooks отправляет доставки вебхуков, срок которых наступил, пока не будет отменен контекст
+func (a *App) deliverWebhooks(ctx context.Context) error {
+	client := &http.Client{Timeout: a.cfg.WebhookTimeout}
+	ticker := time.NewTicker(a.cfg.WebhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			n, err := controller.DeliverWebhooks(ctx, a.pgClient, client, a.cfg.OutboxBatchSize)
+			if err != nil {
+				logging.GetLogger(ctx).WithError(err).Error("failed to deliver webhooks")
+				break
+			}
+			if n < a.cfg.OutboxBatchSize {
+				break
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -15,6 +16,36 @@ type Config struct {
 	DBName string `env:"POSTGRES_NAME"`
 	IP     string `env:"IP"`
 	Port   string `env:"PORT"`
+
+	ProductCodeFormats []string `env:"PRODUCT_CODE_FORMATS" env-separator:"," env-default:"ean13,upca,gtin14,sku"`
+	ProductSKUPattern  string   `env:"PRODUCT_SKU_PATTERN"`
+
+	IdempotencyRetention time.Duration `env:"IDEMPOTENCY_RETENTION" env-default:"24h"`
+	IdempotencyLease     time.Duration `env:"IDEMPOTENCY_LEASE" env-default:"1m"`
+
+	OutboxPublishers   []string      `env:"OUTBOX_PUBLISHERS" env-separator:"," env-default:"bus"`
+	OutboxFile         string        `env:"OUTBOX_FILE" env-default:"outbox.ndjson"`
+	OutboxWebhookURL   string        `env:"OUTBOX_WEBHOOK_URL"`
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	OutboxBatchSize    int           `env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	OutboxRetention    time.Duration `env:"OUTBOX_RETENTION" env-default:"168h"`
+
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+	WebhookRetryDelay   time.Duration `env:"WEBHOOK_RETRY_DELAY" env-default:"10s"`
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"5s"`
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" env-default:"1s"`
+	WebhookRetention    time.Duration `env:"WEBHOOK_RETENTION" env-default:"168h"`
+
+	OrderBroker         string        `env:"ORDER_BROKER"`
+	OrderBrokerURL      string        `env:"ORDER_BROKER_URL"`
+	OrderTopic          string        `env:"ORDER_TOPIC" env-default:"orders"`
+	OrderConsumerGroup  string        `env:"ORDER_CONSUMER_GROUP" env-default:"lamoda-stock"`
+	OrderPollTimeout    time.Duration `env:"ORDER_POLL_TIMEOUT" env-default:"5s"`
+	OrderMaxAttempts    int           `env:"ORDER_MAX_ATTEMPTS" env-default:"5"`
+	OrderRetryDelay     time.Duration `env:"ORDER_RETRY_DELAY" env-default:"1s"`
+	OrderDedupRetention time.Duration `env:"ORDER_DEDUP_RETENTION" env-default:"168h"`
+
+	CalendarPollInterval time.Duration `env:"CALENDAR_POLL_INTERVAL" env-default:"1m"`
 }
 
 var instance *Config
@@ -22,11 +53,11 @@ var once sync.Once
 
 func GetConfig() *Config {
 	once.Do(func() {
-		cfg := Config{}
-		if err := cleanenv.ReadConfig("../../configs/app.env", &cfg); err != nil {
+		cfg, err := ReadConfig("../../configs/app.env")
+		if err != nil {
 			log.Fatal("failed to read config", err)
 		}
-		instance = &cfg
+		instance = cfg
 	})
 	if instance == nil {
 		log.Fatal("failed to initialize config")
@@ -34,3 +65,11 @@ func GetConfig() *Config {
 	return instance
 }
 
+// ReadConfig читает конфигурацию из файла и переменных окружения
+func ReadConfig(path string) (*Config, error) {
+	cfg := Config{}
+	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,145 @@
+package barcode
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatEAN13  = "ean13"
+	FormatUPCA   = "upca"
+	FormatGTIN14 = "gtin14"
+	FormatSKU    = "sku"
+)
+
+// DefaultSKUPattern — шаблон внутреннего артикула по умолчанию
+const DefaultSKUPattern = `^[A-Z0-9][A-Z0-9._-]{2,63}$`
+
+var (
+	ErrEmptyCode       = errors.New("code is empty")
+	ErrInvalidChecksum = errors.New("invalid barcode checksum")
+	ErrFormatDisabled  = errors.New("code format is not allowed")
+	ErrPatternMismatch = errors.New("code does not match the SKU pattern")
+	ErrUnknownFormat   = errors.New("unknown code format")
+)
+
+// Validator проверяет коды товаров по набору разрешенных форматов.
+// Цифровые коды длиной 12, 13 и 14 символов считаются штрихкодами UPC-A, EAN-13 и GTIN-14
+// и проверяются по контрольной цифре, все остальные коды — по шаблону внутреннего артикула.
+type Validator struct {
+	formats    map[string]bool
+	skuPattern *regexp.Regexp
+}
+
+// NewValidator создает валидатор для перечисленных форматов и шаблона артикула
+func NewValidator(formats []string, skuPattern string) (*Validator, error) {
+	v := &Validator{formats: make(map[string]bool, len(formats))}
+	for _, f := range formats {
+		f = strings.ToLower(strings.TrimSpace(f))
+		switch f {
+		case FormatEAN13, FormatUPCA, FormatGTIN14, FormatSKU:
+			v.formats[f] = true
+		case "":
+		default:
+			return nil, ErrUnknownFormat
+		}
+	}
+
+	if skuPattern == "" {
+		skuPattern = DefaultSKUPattern
+	}
+	re, err := regexp.Compile(skuPattern)
+	if err != nil {
+		return nil, err
+	}
+	v.skuPattern = re
+
+	return v, nil
+}
+
+// DefaultValidator разрешает все форматы с шаблоном артикула по умолчанию
+func DefaultValidator() *Validator {
+	v, _ := NewValidator([]string{FormatEAN13, FormatUPCA, FormatGTIN14, FormatSKU}, DefaultSKUPattern)
+	return v
+}
+
+// Normalize убирает пробелы по краям и приводит код к верхнему регистру
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Format определяет формат нормализованного кода
+func Format(code string) string {
+	if !isDigits(code) {
+		return FormatSKU
+	}
+
+	switch len(code) {
+	case 12:
+		return FormatUPCA
+	case 13:
+		return FormatEAN13
+	case 14:
+		return FormatGTIN14
+	default:
+		return FormatSKU
+	}
+}
+
+// Validate нормализует код, проверяет его и возвращает нормализованное значение и формат
+func (v *Validator) Validate(code string) (string, string, error) {
+	code = Normalize(code)
+	if code == "" {
+		return "", "", ErrEmptyCode
+	}
+
+	format := Format(code)
+	if !v.formats[format] {
+		return "", "", ErrFormatDisabled
+	}
+
+	if format == FormatSKU {
+		if !v.skuPattern.MatchString(code) {
+			return "", "", ErrPatternMismatch
+		}
+		return code, format, nil
+	}
+
+	if !ValidChecksum(code) {
+		return "", "", ErrInvalidChecksum
+	}
+
+	return code, format, nil
+}
+
+// ValidChecksum проверяет контрольную цифру GTIN (UPC-A, EAN-13, GTIN-14) по модулю 10
+func ValidChecksum(code string) bool {
+	if len(code) < 2 || !isDigits(code) {
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	check := (10 - sum%10) % 10
+	return check == int(code[len(code)-1]-'0')
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package barcode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidChecksum(t *testing.T) {
+	cases := map[string]bool{
+		"4006381333931":  true,
+		"4006381333932":  false,
+		"036000291452":   true,
+		"036000291453":   false,
+		"10614141000415": true,
+		"10614141000416": false,
+	}
+
+	for code, want := range cases {
+		if got := ValidChecksum(code); got != want {
+			t.Errorf("ValidChecksum(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestValidatorNormalizesAndValidates(t *testing.T) {
+	v := DefaultValidator()
+
+	code, format, err := v.Validate("  abc-123 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "ABC-123" || format != FormatSKU {
+		t.Errorf("Expected normalized SKU ABC-123, got %q (%s)", code, format)
+	}
+
+	if _, _, err := v.Validate("4006381333932"); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("Expected ErrInvalidChecksum, got %v", err)
+	}
+	if _, _, err := v.Validate("   "); !errors.Is(err, ErrEmptyCode) {
+		t.Errorf("Expected ErrEmptyCode, got %v", err)
+	}
+}
+
+func TestValidatorRespectsFormats(t *testing.T) {
+	v, err := NewValidator([]string{FormatEAN13}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := v.Validate("4006381333931"); err != nil {
+		t.Errorf("Expected EAN-13 to be accepted, got %v", err)
+	}
+	if _, _, err := v.Validate("ABC-123"); !errors.Is(err, ErrFormatDisabled) {
+		t.Errorf("Expected ErrFormatDisabled for SKU, got %v", err)
+	}
+
+	if _, err := NewValidator([]string{"qr"}, ""); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Package broker описывает чтение сообщений из брокера очередей с ручной фиксацией смещений.
+// Есть адаптер для Kafka REST Proxy и брокер в памяти для локального запуска и тестов.
+package broker
+
+import (
+	"context"
+	"strconv"
+)
+
+// Message — сообщение из раздела топика
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// ID однозначно определяет сообщение в брокере
+func (m Message) ID() string {
+	return m.Topic + ":" + strconv.Itoa(int(m.Partition)) + ":" + strconv.FormatInt(m.Offset, 10)
+}
+
+// Consumer читает сообщения группы потребителей. Fetch ждет новых сообщений не дольше
+// таймаута опроса и может вернуть пустую пачку. Commit фиксирует обработку сообщения и всех
+// предыдущих в его разделе; незафиксированные сообщения будут прочитаны снова после переподключения.
+type Consumer interface {
+	Fetch(ctx context.Context) ([]Message, error)
+	Commit(ctx context.Context, m Message) error
+	Close() error
+}
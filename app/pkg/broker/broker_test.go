@@ -0,0 +1,110 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRedeliversUncommitted(t *testing.T) {
+	b := NewMemory()
+	b.Produce("orders", []byte("1"), []byte("a"))
+	b.Produce("orders", []byte("2"), []byte("b"))
+
+	c := b.Consumer("orders", "stock", 10*time.Millisecond)
+	batch, err := c.Fetch(context.Background())
+	if err != nil || len(batch) != 2 {
+		t.Fatalf("Fetch returned %d messages, %v", len(batch), err)
+	}
+	if err := c.Commit(context.Background(), batch[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if batch, _ := c.Fetch(context.Background()); len(batch) != 0 {
+		t.Errorf("expected an empty poll, got %d messages", len(batch))
+	}
+
+	batch, err = b.Consumer("orders", "stock", 10*time.Millisecond).Fetch(context.Background())
+	if err != nil || len(batch) != 1 || string(batch[0].Value) != "b" {
+		t.Errorf("expected the uncommitted message after reconnect, got %v, %v", batch, err)
+	}
+	if got := b.Committed("orders", "stock"); got != 1 {
+		t.Errorf("committed offset %d, want 1", got)
+	}
+}
+
+func TestMemoryFetchWaitsForMessages(t *testing.T) {
+	b := NewMemory()
+	c := b.Consumer("orders", "stock", time.Second)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Produce("orders", nil, []byte("late"))
+	}()
+
+	batch, err := c.Fetch(context.Background())
+	if err != nil || len(batch) != 1 {
+		t.Errorf("expected the produced message, got %v, %v", batch, err)
+	}
+}
+
+func TestKafkaREST(t *testing.T) {
+	var committed []map[string]interface{}
+	var deleted bool
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/consumers/stock":
+			json.NewEncoder(w).Encode(map[string]string{"instance_id": "i1", "base_uri": srv.URL + "/consumers/stock/instances/i1"})
+		case strings.HasSuffix(r.URL.Path, "/subscription"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/records"):
+			if r.Header.Get("Accept") != kafkaRESTBinary {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Write([]byte(`[{"topic":"orders","key":"MQ==","value":"e30=","partition":2,"offset":41}]`))
+		case strings.HasSuffix(r.URL.Path, "/offsets"):
+			var body struct {
+				Offsets []map[string]interface{} `json:"offsets"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			committed = body.Offsets
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewKafkaREST(context.Background(), srv.URL, "stock", "orders", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := c.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 1 || string(batch[0].Key) != "1" || string(batch[0].Value) != "{}" || batch[0].ID() != "orders:2:41" {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+
+	if err := c.Commit(context.Background(), batch[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(committed) != 1 || committed[0]["offset"] != float64(41) || committed[0]["partition"] != float64(2) {
+		t.Errorf("unexpected committed offsets %v", committed)
+	}
+
+	if err := c.Close(); err != nil || !deleted {
+		t.Errorf("Close did not delete the consumer instance: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kafkaRESTContentType = "application/vnd.kafka.v2+json"
+	kafkaRESTBinary      = "application/vnd.kafka.binary.v2+json"
+)
+
+// KafkaREST читает топик Kafka через Kafka REST Proxy (API v2) в двоичном формате.
+// Автоматическая фиксация смещений отключена: смещение фиксируется только через Commit.
+type KafkaREST struct {
+	client      *http.Client
+	baseURI     string
+	pollTimeout time.Duration
+}
+
+// NewKafkaREST создает экземпляр потребителя группы group на прокси proxyURL и подписывает его на topic
+func NewKafkaREST(ctx context.Context, proxyURL, group, topic string, pollTimeout time.Duration) (*KafkaREST, error) {
+	c := &KafkaREST{client: &http.Client{Timeout: pollTimeout + 10*time.Second}, pollTimeout: pollTimeout}
+
+	var instance struct {
+		InstanceID string `json:"instance_id"`
+		BaseURI    string `json:"base_uri"`
+	}
+	err := c.do(ctx, http.MethodPost, strings.TrimRight(proxyURL, "/")+"/consumers/"+url.PathEscape(group), map[string]string{
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &instance)
+	if err != nil {
+		return nil, err
+	}
+	c.baseURI = instance.BaseURI
+
+	err = c.do(ctx, http.MethodPost, c.baseURI+"/subscription", map[string][]string{"topics": {topic}}, nil)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *KafkaREST) Fetch(ctx context.Context) ([]Message, error) {
+	var records []struct {
+		Topic     string `json:"topic"`
+		Key       []byte `json:"key"`
+		Value     []byte `json:"value"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	endpoint := c.baseURI + "/records?timeout=" + strconv.FormatInt(c.pollTimeout.Milliseconds(), 10)
+	if err := c.do(ctx, http.MethodGet, endpoint, nil, &records); err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(records))
+	for _, r := range records {
+		messages = append(messages, Message{Topic: r.Topic, Partition: r.Partition, Offset: r.Offset, Key: r.Key, Value: r.Value})
+	}
+	return messages, nil
+}
+
+// Commit фиксирует смещение обработанного сообщения; прокси сохраняет в Kafka следующее за ним
+func (c *KafkaREST) Commit(ctx context.Context, m Message) error {
+	type offset struct {
+		Topic     string `json:"topic"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	body := map[string][]offset{"offsets": {{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}}}
+	return c.do(ctx, http.MethodPost, c.baseURI+"/offsets", body, nil)
+}
+
+// Close удаляет экземпляр потребителя на прокси, чтобы его разделы сразу перешли другим участникам группы
+func (c *KafkaREST) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.do(ctx, http.MethodDelete, c.baseURI, nil, nil)
+}
+
+func (c *KafkaREST) do(ctx context.Context, method, endpoint string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", kafkaRESTContentType)
+	}
+	req.Header.Set("Accept", kafkaRESTContentType)
+	if method == http.MethodGet {
+		req.Header.Set("Accept", kafkaRESTBinary)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var proxyErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&proxyErr)
+		return fmt.Errorf("kafka rest proxy %s %s: status %d: %s", method, endpoint, resp.StatusCode, proxyErr.Message)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory — брокер в памяти процесса с одним разделом на топик
+type Memory struct {
+	mu        sync.Mutex
+	topics    map[string][]Message
+	committed map[string]int64
+	notify    chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		topics:    make(map[string][]Message),
+		committed: make(map[string]int64),
+		notify:    make(chan struct{}),
+	}
+}
+
+// Produce добавляет сообщение в конец топика
+func (b *Memory) Produce(topic string, key, value []byte) Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := Message{Topic: topic, Offset: int64(len(b.topics[topic])), Key: key, Value: value}
+	b.topics[topic] = append(b.topics[topic], m)
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+
+	return m
+}
+
+// Consumer подключает потребителя группы group к топику с последнего зафиксированного смещения
+func (b *Memory) Consumer(topic, group string, pollTimeout time.Duration) Consumer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return &memoryConsumer{
+		broker:      b,
+		topic:       topic,
+		group:       group,
+		position:    b.committed[group+"/"+topic],
+		pollTimeout: pollTimeout,
+	}
+}
+
+// Committed возвращает смещение, с которого группа продолжит чтение топика
+func (b *Memory) Committed(topic, group string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.committed[group+"/"+topic]
+}
+
+type memoryConsumer struct {
+	broker      *Memory
+	topic       string
+	group       string
+	position    int64
+	pollTimeout time.Duration
+}
+
+func (c *memoryConsumer) Fetch(ctx context.Context) ([]Message, error) {
+	timer := time.NewTimer(c.pollTimeout)
+	defer timer.Stop()
+
+	for {
+		c.broker.mu.Lock()
+		messages := c.broker.topics[c.topic]
+		notify := c.broker.notify
+		c.broker.mu.Unlock()
+
+		if int64(len(messages)) > c.position {
+			batch := append([]Message(nil), messages[c.position:]...)
+			c.position = int64(len(messages))
+			return batch, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, nil
+		case <-notify:
+		}
+	}
+}
+
+func (c *memoryConsumer) Commit(_ context.Context, m Message) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	key := c.group + "/" + c.topic
+	if m.Offset+1 > c.broker.committed[key] {
+		c.broker.committed[key] = m.Offset + 1
+	}
+	return nil
+}
+
+func (c *memoryConsumer) Close() error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	// справочник часовых поясов встроен, чтобы не зависеть от tzdata в образе
+	_ "time/tzdata"
+)
+
+const dateLayout = "2006-01-02"
+
+// Причины, по которым склад закрыт по календарю
+const (
+	ReasonMaintenance = "maintenance"
+	ReasonHoliday     = "holiday"
+	ReasonClosed      = "closed"
+)
+
+// Hours — рабочее время в день недели по местному времени склада в формате ЧЧ:ММ.
+// Closes может быть 24:00; ночная смена задается двумя интервалами в соседние дни.
+type Hours struct {
+	Weekday time.Weekday `json:"weekday"`
+	Opens   string       `json:"opens"`
+	Closes  string       `json:"closes"`
+}
+
+// Window — период, когда склад закрыт независимо от рабочего времени, например техническое обслуживание
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Calendar — график работы склада. Без рабочих часов склад работает круглосуточно.
+// Holidays — местные даты в формате ГГГГ-ММ-ДД.
+type Calendar struct {
+	Location    *time.Location
+	Hours       []Hours
+	Holidays    []string
+	Maintenance []Window
+}
+
+// ParseClock переводит время ЧЧ:ММ в минуты от начала суток; допускается 24:00
+func ParseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// Validate проверяет дни недели, интервалы рабочего времени, даты праздников и окна обслуживания
+func (c Calendar) Validate() error {
+	for _, h := range c.Hours {
+		if h.Weekday < time.Sunday || h.Weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", h.Weekday)
+		}
+		opens, err := ParseClock(h.Opens)
+		if err != nil {
+			return err
+		}
+		closes, err := ParseClock(h.Closes)
+		if err != nil {
+			return err
+		}
+		if opens >= closes {
+			return fmt.Errorf("opening time %s is not before closing time %s", h.Opens, h.Closes)
+		}
+	}
+	for _, d := range c.Holidays {
+		if _, err := time.Parse(dateLayout, d); err != nil {
+			return fmt.Errorf("invalid holiday date %q", d)
+		}
+	}
+	for _, w := range c.Maintenance {
+		if !w.End.After(w.Start) {
+			return errors.New("maintenance window must end after it starts")
+		}
+	}
+	return nil
+}
+
+// Status сообщает, открыт ли склад в момент t, и если нет — почему.
+// Обслуживание важнее праздника, праздник — рабочего времени.
+func (c Calendar) Status(t time.Time) (open bool, reason string) {
+	for _, w := range c.Maintenance {
+		if !t.Before(w.Start) && t.Before(w.End) {
+			return false, ReasonMaintenance
+		}
+	}
+
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	date := local.Format(dateLayout)
+	for _, d := range c.Holidays {
+		if d == date {
+			return false, ReasonHoliday
+		}
+	}
+
+	if len(c.Hours) == 0 {
+		return true, ""
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, h := range c.Hours {
+		if h.Weekday != local.Weekday() {
+			continue
+		}
+		opens, errOpens := ParseClock(h.Opens)
+		closes, errCloses := ParseClock(h.Closes)
+		if errOpens == nil && errCloses == nil && minute >= opens && minute < closes {
+			return true, ""
+		}
+	}
+
+	return false, ReasonClosed
+}
@@ -0,0 +1,75 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Calendar{
+		Location: moscow,
+		Hours: []Hours{
+			{Weekday: time.Monday, Opens: "09:00", Closes: "18:00"},
+			{Weekday: time.Friday, Opens: "22:00", Closes: "24:00"},
+		},
+		Holidays: []string{"2024-01-08"},
+		Maintenance: []Window{{
+			Start: time.Date(2024, 1, 15, 12, 0, 0, 0, moscow),
+			End:   time.Date(2024, 1, 15, 14, 0, 0, 0, moscow),
+		}},
+	}
+
+	cases := []struct {
+		at     time.Time
+		open   bool
+		reason string
+	}{
+		{time.Date(2024, 1, 15, 9, 0, 0, 0, moscow), true, ""},
+		{time.Date(2024, 1, 15, 8, 59, 0, 0, moscow), false, ReasonClosed},
+		{time.Date(2024, 1, 15, 18, 0, 0, 0, moscow), false, ReasonClosed},
+		// 06:30 UTC — 09:30 по Москве
+		{time.Date(2024, 1, 15, 6, 30, 0, 0, time.UTC), true, ""},
+		{time.Date(2024, 1, 15, 13, 0, 0, 0, moscow), false, ReasonMaintenance},
+		{time.Date(2024, 1, 15, 14, 0, 0, 0, moscow), true, ""},
+		{time.Date(2024, 1, 8, 10, 0, 0, 0, moscow), false, ReasonHoliday},
+		{time.Date(2024, 1, 19, 23, 59, 0, 0, moscow), true, ""},
+		{time.Date(2024, 1, 16, 10, 0, 0, 0, moscow), false, ReasonClosed},
+	}
+	for _, tc := range cases {
+		open, reason := c.Status(tc.at)
+		if open != tc.open || reason != tc.reason {
+			t.Errorf("Status(%v) = %v, %q, want %v, %q", tc.at, open, reason, tc.open, tc.reason)
+		}
+	}
+
+	if open, _ := (Calendar{}).Status(time.Now()); !open {
+		t.Error("Expected an empty calendar to be open around the clock")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Calendar{Hours: []Hours{{Weekday: time.Sunday, Opens: "00:00", Closes: "24:00"}}, Holidays: []string{"2024-05-01"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid calendar, got %v", err)
+	}
+
+	now := time.Now()
+	invalid := []Calendar{
+		{Hours: []Hours{{Weekday: 7, Opens: "09:00", Closes: "18:00"}}},
+		{Hours: []Hours{{Weekday: time.Monday, Opens: "18:00", Closes: "09:00"}}},
+		{Hours: []Hours{{Weekday: time.Monday, Opens: "9:00", Closes: "18:00"}}},
+		{Hours: []Hours{{Weekday: time.Monday, Opens: "09:00", Closes: "24:30"}}},
+		{Holidays: []string{"01.05.2024"}},
+		{Maintenance: []Window{{Start: now, End: now}}},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", c)
+		}
+	}
+}
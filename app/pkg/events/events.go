@@ -0,0 +1,30 @@
+// Package events описывает события об изменениях на складе и способы их доставки:
+// HTTP-вебхук, файл NDJSON и шина внутри процесса.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event — событие из outbox. Key задает порядок: события с одним ключом доставляются в порядке ID.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Publisher доставляет событие получателю. Ошибка означает, что событие нужно доставить повторно.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// PublisherFunc позволяет использовать функцию как Publisher
+type PublisherFunc func(ctx context.Context, e Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
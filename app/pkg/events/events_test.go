@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBusDeliversToSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var got []int64
+	unsubscribe := bus.Subscribe(func(e Event) { got = append(got, e.ID) })
+
+	bus.Publish(context.Background(), Event{ID: 1})
+	unsubscribe()
+	bus.Publish(context.Background(), Event{ID: 2})
+
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("got events %v, want [1]", got)
+	}
+}
+
+func TestFilePublisherAppendsNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	p, err := NewFilePublisher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := int64(1); i <= 2; i++ {
+		if err := p.Publish(context.Background(), Event{ID: i, Type: "stock.changed", Payload: json.RawMessage(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e.ID != 2 {
+		t.Errorf("unexpected second line %q: %v", lines[1], err)
+	}
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	status := http.StatusOK
+	var received Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := NewWebhookPublisher(srv.URL)
+	if err := p.Publish(context.Background(), Event{ID: 7, Type: "stock.changed", Payload: json.RawMessage(`{"delta":-1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if received.ID != 7 {
+		t.Errorf("webhook received event %d, want 7", received.ID)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := p.Publish(context.Background(), Event{ID: 8}); err == nil {
+		t.Error("expected an error for a failed delivery")
+	}
+}
+
+func TestWebhookPublisherSignsRequests(t *testing.T) {
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		verified = VerifySignature("secret", timestamp, body, r.Header.Get(SignatureHeader)) &&
+			r.Header.Get("X-Delivery-ID") == "42"
+	}))
+	defer srv.Close()
+
+	p := NewWebhookPublisher(srv.URL)
+	p.Secret = "secret"
+	status, err := p.Send(context.Background(), Event{ID: 1, Type: "stock.depleted"}, "42")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Send returned %d, %v", status, err)
+	}
+	if !verified {
+		t.Error("webhook signature or delivery ID did not verify")
+	}
+
+	if VerifySignature("other", 1, []byte("{}"), Sign("secret", 1, []byte("{}"))) {
+		t.Error("signature must not verify with a different secret")
+	}
+}
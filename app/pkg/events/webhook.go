@@ -0,0 +1,88 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader содержит HMAC-SHA256 тела запроса в виде "sha256=<hex>"
+	SignatureHeader = "X-Signature"
+	// TimestampHeader содержит время отправки в секундах Unix, которое входит в подпись
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+// WebhookPublisher отправляет событие POST-запросом с JSON-телом.
+// Если задан Secret, запрос подписывается. Любой ответ кроме 2xx считается ошибкой доставки.
+type WebhookPublisher struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	return &WebhookPublisher{URL: url, Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, e Event) error {
+	_, err := p.Send(ctx, e, "")
+	return err
+}
+
+// Send отправляет событие и возвращает HTTP-статус ответа (0, если ответа нет).
+// deliveryID передается в заголовке X-Delivery-ID, чтобы получатель мог отсеять повторы.
+func (p *WebhookPublisher) Send(ctx context.Context, e Event, deliveryID string) (int, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Event-Type", e.Type)
+	if deliveryID != "" {
+		req.Header.Set("X-Delivery-ID", deliveryID)
+	}
+	if p.Secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign(p.Secret, timestamp, body))
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign возвращает подпись "sha256=<hex>" для строки "<timestamp>.<body>"
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature проверяет подпись запроса за постоянное время
+func VerifySignature(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
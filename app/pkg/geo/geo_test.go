@@ -0,0 +1,36 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKM(t *testing.T) {
+	moscow := Point{Latitude: 55.7558, Longitude: 37.6173}
+	petersburg := Point{Latitude: 59.9343, Longitude: 30.3351}
+
+	if d := DistanceKM(moscow, petersburg); math.Abs(d-634) > 3 {
+		t.Errorf("Expected about 634 km between Moscow and Saint Petersburg, got %.1f", d)
+	}
+	if d := DistanceKM(moscow, moscow); d != 0 {
+		t.Errorf("Expected zero distance to itself, got %v", d)
+	}
+	if d1, d2 := DistanceKM(moscow, petersburg), DistanceKM(petersburg, moscow); math.Abs(d1-d2) > 1e-9 {
+		t.Errorf("Expected symmetric distance, got %v and %v", d1, d2)
+	}
+}
+
+func TestPointValid(t *testing.T) {
+	cases := map[Point]bool{
+		{Latitude: 0, Longitude: 0}:       true,
+		{Latitude: 90, Longitude: 180}:    true,
+		{Latitude: 91, Longitude: 0}:      false,
+		{Latitude: 0, Longitude: -180.5}:  false,
+		{Latitude: -45.5, Longitude: 120}: true,
+	}
+	for p, want := range cases {
+		if got := p.Valid(); got != want {
+			t.Errorf("%+v.Valid() = %v, want %v", p, got, want)
+		}
+	}
+}
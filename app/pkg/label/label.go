@@ -0,0 +1,234 @@
+// Package label печатает этикетки товаров и ячеек со штрихкодом
+// в форматах PDF, PNG и ZPL (для термопринтеров Zebra).
+package label
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+const (
+	FormatPDF = "pdf"
+	FormatPNG = "png"
+	FormatZPL = "zpl"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown label format")
+	ErrCodeTooLong   = errors.New("code is too long to fit the barcode on a label")
+)
+
+// Label — одна этикетка: заголовок, подзаголовок и код, который печатается штрихкодом и текстом под ним
+type Label struct {
+	Title    string
+	Subtitle string
+	Code     string
+}
+
+// ContentType возвращает MIME-тип для формата этикеток
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatPDF:
+		return "application/pdf", nil
+	case FormatPNG:
+		return "image/png", nil
+	case FormatZPL:
+		return "text/plain; charset=utf-8", nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Render печатает этикетки в заданном формате: PDF — по странице на этикетку,
+// PNG — этикетки одна под другой, ZPL — по блоку ^XA...^XZ на этикетку.
+func Render(w io.Writer, format string, labels []Label) error {
+	switch format {
+	case FormatPDF:
+		return RenderPDF(w, labels)
+	case FormatPNG:
+		return RenderPNG(w, labels)
+	case FormatZPL:
+		return RenderZPL(w, labels)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// Размеры этикетки 4x2 дюйма: в точках термопринтера 203 dpi и в пунктах PDF
+const (
+	dotsWidth  = 812
+	dotsHeight = 406
+	pageWidth  = 288
+	pageHeight = 144
+)
+
+// RenderZPL печатает этикетки командами ZPL II. EAN-13 печатается командой ^BE,
+// остальные коды — ^BC (Code 128), контрольные символы рассчитывает принтер.
+func RenderZPL(w io.Writer, labels []Label) error {
+	for _, l := range labels {
+		_, symbology, err := Encode(l.Code)
+		if err != nil {
+			return err
+		}
+
+		data := l.Code
+		barcodeCmd := "^BCN,160,Y,N,N"
+		if symbology == SymbologyEAN13 {
+			data = l.Code[:12]
+			barcodeCmd = "^BEN,160,Y,N"
+		}
+
+		_, err = fmt.Fprintf(w, "^XA\n^CI28\n^PW%d\n^LL%d\n"+
+			"^FO30,30^A0N,40,40^FB752,1,0,L^FD%s^FS\n"+
+			"^FO30,80^A0N,30,30^FB752,1,0,L^FD%s^FS\n"+
+			"^FO30,140^BY3%s^FD%s^FS\n^XZ\n",
+			dotsWidth, dotsHeight, zplText(l.Title), zplText(l.Subtitle), barcodeCmd, zplText(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// zplText убирает из текста символы, которые ZPL воспринимает как начало команды
+func zplText(s string) string {
+	return strings.NewReplacer("^", " ", "~", " ", "\n", " ").Replace(s)
+}
+
+// RenderPNG печатает этикетки на одном изображении, одну под другой, с разделительной линией.
+// Модуль штрихкода занимает не меньше точки, поэтому слишком длинные коды не печатаются.
+func RenderPNG(w io.Writer, labels []Label) error {
+	if len(labels) == 0 {
+		return errors.New("no labels to render")
+	}
+
+	img := image.NewGray(image.Rect(0, 0, dotsWidth, dotsHeight*len(labels)))
+	fillRect(img, 0, 0, dotsWidth, dotsHeight*len(labels), color.Gray{Y: 255})
+
+	for i, l := range labels {
+		modules, _, err := Encode(l.Code)
+		if err != nil {
+			return err
+		}
+		if len(modules) > dotsWidth-60 {
+			return ErrCodeTooLong
+		}
+
+		top := i * dotsHeight
+		if i > 0 {
+			fillRect(img, 0, top, dotsWidth, 2, color.Gray{Y: 0})
+		}
+
+		drawText(img, 30, top+30, 4, fitText(l.Title, 4, dotsWidth-60))
+		drawText(img, 30, top+80, 3, fitText(l.Subtitle, 3, dotsWidth-60))
+
+		module := (dotsWidth - 60) / len(modules)
+		left := (dotsWidth - module*len(modules)) / 2
+		for j, bar := range modules {
+			if bar {
+				fillRect(img, left+j*module, top+130, module, 200, color.Gray{Y: 0})
+			}
+		}
+
+		drawText(img, (dotsWidth-textWidth(l.Code, 3))/2, top+345, 3, l.Code)
+	}
+
+	return png.Encode(w, img)
+}
+
+// fitText обрезает текст, чтобы он поместился в заданную ширину
+func fitText(s string, scale, width int) string {
+	r := []rune(s)
+	for len(r) > 0 && textWidth(string(r), scale) > width {
+		r = r[:len(r)-1]
+	}
+	return string(r)
+}
+
+// RenderPDF печатает этикетки в PDF, по странице 4x2 дюйма на этикетку.
+// Текст печатается встроенным шрифтом DejaVu Sans, поэтому кириллица печатается как есть.
+func RenderPDF(w io.Writer, labels []Label) error {
+	if len(labels) == 0 {
+		return errors.New("no labels to render")
+	}
+
+	font, err := loadFont()
+	if err != nil {
+		return err
+	}
+	enc := newFontEncoder(font)
+
+	var contents []string
+	for _, l := range labels {
+		modules, _, err := Encode(l.Code)
+		if err != nil {
+			return err
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "BT /F1 14 Tf 14 %d Td %s Tj ET\n", pageHeight-24, enc.text(l.Title))
+		fmt.Fprintf(&b, "BT /F1 10 Tf 14 %d Td %s Tj ET\n", pageHeight-40, enc.text(l.Subtitle))
+
+		module := float64(pageWidth-28) / float64(len(modules))
+		if module > 2 {
+			module = 2
+		}
+		left := (float64(pageWidth) - module*float64(len(modules))) / 2
+		for j, bar := range modules {
+			if bar {
+				fmt.Fprintf(&b, "%.2f 30 %.2f 64 re f\n", left+float64(j)*module, module)
+			}
+		}
+
+		fmt.Fprintf(&b, "BT /F1 10 Tf %.2f 14 Td %s Tj ET\n", left, enc.text(l.Code))
+		contents = append(contents, b.String())
+	}
+
+	return writePDF(w, enc.objects(3), contents)
+}
+
+// writePDF собирает минимальный документ PDF 1.4 из объектов шрифта и потоков содержимого страниц
+func writePDF(w io.Writer, font []string, contents []string) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1 — каталог, 2 — дерево страниц, с 3 — объекты шрифта, далее пары «страница, содержимое»
+	first := 3 + len(font)
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", first+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents)))
+	for _, f := range font {
+		object(f)
+	}
+	for i, c := range contents {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, first+1+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(c), c))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,128 @@
+package label
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestCode128PatternsHaveElevenModules(t *testing.T) {
+	seen := make(map[string]bool, len(code128Patterns))
+	for i, p := range code128Patterns {
+		sum := 0
+		for _, w := range p {
+			sum += int(w - '0')
+		}
+
+		want := 11
+		if i == code128Stop {
+			want = 13
+		}
+		if sum != want {
+			t.Errorf("pattern %d has %d modules, want %d", i, sum, want)
+		}
+		if seen[p] {
+			t.Errorf("pattern %d is duplicated", i)
+		}
+		seen[p] = true
+	}
+}
+
+func TestEncode(t *testing.T) {
+	modules, symbology, err := Encode("4006381333931")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if symbology != SymbologyEAN13 || len(modules) != 95 {
+		t.Errorf("EAN-13 encoded as %s with %d modules", symbology, len(modules))
+	}
+
+	modules, symbology, err = Encode("SKU-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// старт, 7 символов, контрольный символ по 11 модулей и стоп из 13
+	if symbology != SymbologyCode128 || len(modules) != 11*9+13 {
+		t.Errorf("Code 128 encoded as %s with %d modules", symbology, len(modules))
+	}
+	if !modules[0] || !modules[len(modules)-1] {
+		t.Error("barcode must start and end with a bar")
+	}
+
+	if _, _, err := Encode("ЯЧЕЙКА"); err != ErrUnsupportedCode {
+		t.Errorf("expected ErrUnsupportedCode, got %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	labels := []Label{
+		{Title: "Sneakers (white)", Subtitle: "Size 42", Code: "4006381333931"},
+		{Title: "T-shirt", Subtitle: "Size M", Code: "SKU-001"},
+	}
+
+	var zpl bytes.Buffer
+	if err := RenderZPL(&zpl, labels); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(zpl.String(), "^XA") != 2 || !strings.Contains(zpl.String(), "^BEN,160,Y,N^FD400638133393^FS") {
+		t.Errorf("unexpected ZPL:\n%s", zpl.String())
+	}
+
+	var img bytes.Buffer
+	if err := RenderPNG(&img, labels); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds().Dy() != 2*dotsHeight {
+		t.Errorf("image height = %d, want %d", decoded.Bounds().Dy(), 2*dotsHeight)
+	}
+
+	var pdf bytes.Buffer
+	if err := RenderPDF(&pdf, labels); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(pdf.String(), "%PDF-1.4") || !strings.Contains(pdf.String(), "/Count 2") ||
+		!strings.Contains(pdf.String(), "/FontFile2") {
+		t.Errorf("unexpected PDF:\n%s", pdf.String())
+	}
+
+	if err := Render(&pdf, "svg", labels); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestRenderPDFPrintsCyrillic(t *testing.T) {
+	font, err := loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range "Кроссовки белые" {
+		if font.glyph(r) == 0 {
+			t.Fatalf("no glyph for %q", r)
+		}
+	}
+
+	var pdf bytes.Buffer
+	if err := RenderPDF(&pdf, []Label{{Title: "Кроссовки", Subtitle: "Размер 42", Code: "SKU-001"}}); err != nil {
+		t.Fatal(err)
+	}
+	// ToUnicode сопоставляет глиф букве «К» (U+041A)
+	want := fmt.Sprintf("<%04X> <041A>", font.glyph('К'))
+	if !strings.Contains(pdf.String(), want) {
+		t.Errorf("expected %s in ToUnicode map", want)
+	}
+}
+
+func TestRenderPNGRejectsLongCodes(t *testing.T) {
+	var img bytes.Buffer
+	err := RenderPNG(&img, []Label{{Code: strings.Repeat("A", 80)}})
+	if !errors.Is(err, ErrCodeTooLong) {
+		t.Errorf("expected ErrCodeTooLong, got %v", err)
+	}
+}
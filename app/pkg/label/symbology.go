@@ -0,0 +1,140 @@
+package label
+
+import (
+	"errors"
+
+	"github.com/DmitriiKumancev/lamoda-test/pkg/barcode"
+)
+
+const (
+	SymbologyCode128 = "code128"
+	SymbologyEAN13   = "ean13"
+)
+
+var ErrUnsupportedCode = errors.New("code cannot be encoded as a barcode")
+
+// code128Patterns — ширины штрихов и пробелов для значений 0..106 символики Code 128
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+// eanLeftOdd — коды цифр левой половины EAN-13 с нечетной четностью (набор L)
+var eanLeftOdd = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// eanParity задает наборы L/G для левой половины в зависимости от первой цифры
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLG", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// Encode кодирует код товара в последовательность модулей штрихкода (true — штрих).
+// Корректные EAN-13 кодируются как EAN-13, остальные коды — как Code 128 (набор B).
+func Encode(code string) ([]bool, string, error) {
+	if len(code) == 13 && barcode.ValidChecksum(code) {
+		modules, err := EncodeEAN13(code)
+		return modules, SymbologyEAN13, err
+	}
+
+	modules, err := EncodeCode128(code)
+	return modules, SymbologyCode128, err
+}
+
+// EncodeCode128 кодирует печатные ASCII-символы в Code 128 набора B с контрольным символом
+func EncodeCode128(data string) ([]bool, error) {
+	if data == "" {
+		return nil, ErrUnsupportedCode
+	}
+
+	values := []int{code128StartB}
+	checksum := code128StartB
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c < 32 || c > 126 {
+			return nil, ErrUnsupportedCode
+		}
+		v := int(c) - 32
+		values = append(values, v)
+		checksum += v * (i + 1)
+	}
+	values = append(values, checksum%103, code128Stop)
+
+	var modules []bool
+	for _, v := range values {
+		bar := true
+		for _, w := range code128Patterns[v] {
+			for n := 0; n < int(w-'0'); n++ {
+				modules = append(modules, bar)
+			}
+			bar = !bar
+		}
+	}
+
+	return modules, nil
+}
+
+// EncodeEAN13 кодирует 13-значный EAN с проверкой контрольной цифры
+func EncodeEAN13(code string) ([]bool, error) {
+	if len(code) != 13 || !barcode.ValidChecksum(code) {
+		return nil, ErrUnsupportedCode
+	}
+
+	pattern := "101"
+	parity := eanParity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		l := eanLeftOdd[code[i]-'0']
+		if parity[i-1] == 'G' {
+			l = reverse(complement(l))
+		}
+		pattern += l
+	}
+	pattern += "01010"
+	for i := 7; i <= 12; i++ {
+		pattern += complement(eanLeftOdd[code[i]-'0'])
+	}
+	pattern += "101"
+
+	modules := make([]bool, len(pattern))
+	for i := range pattern {
+		modules[i] = pattern[i] == '1'
+	}
+
+	return modules, nil
+}
+
+func complement(s string) string {
+	b := []byte(s)
+	for i := range b {
+		if b[i] == '0' {
+			b[i] = '1'
+		} else {
+			b[i] = '0'
+		}
+	}
+	return string(b)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
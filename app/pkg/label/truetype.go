@@ -0,0 +1,248 @@
+package label
+
+import (
+	"bytes"
+	"compress/zlib"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fontData — шрифт DejaVu Sans с кириллицей, который встраивается в PDF-этикетки (лицензия в fonts/LICENSE)
+//
+//go:embed fonts/DejaVuSans.ttf
+var fontData []byte
+
+const fontName = "DejaVuSans"
+
+var errBadFont = errors.New("malformed TrueType font")
+
+// trueType — разобранные из TrueType-файла данные, нужные для встраивания шрифта в PDF
+type trueType struct {
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	advances   []uint16
+	cmap       []byte
+	compressed []byte
+}
+
+var (
+	embeddedFont    *trueType
+	embeddedFontErr error
+	embeddedOnce    sync.Once
+)
+
+// loadFont разбирает встроенный шрифт при первом обращении
+func loadFont() (*trueType, error) {
+	embeddedOnce.Do(func() {
+		embeddedFont, embeddedFontErr = parseTrueType(fontData)
+	})
+	return embeddedFont, embeddedFontErr
+}
+
+func parseTrueType(data []byte) (*trueType, error) {
+	tables := make(map[string][]byte)
+	if len(data) < 12 {
+		return nil, errBadFont
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, errBadFont
+		}
+		offset, length := binary.BigEndian.Uint32(data[rec+8:]), binary.BigEndian.Uint32(data[rec+12:])
+		if int(offset)+int(length) > len(data) {
+			return nil, errBadFont
+		}
+		tables[string(data[rec:rec+4])] = data[offset : offset+length]
+	}
+
+	head, hhea, hmtx, cmap := tables["head"], tables["hhea"], tables["hmtx"], tables["cmap"]
+	if len(head) < 54 || len(hhea) < 36 || hmtx == nil || cmap == nil {
+		return nil, errBadFont
+	}
+
+	f := &trueType{unitsPerEm: int(binary.BigEndian.Uint16(head[18:]))}
+	if f.unitsPerEm == 0 {
+		return nil, errBadFont
+	}
+	for i := range f.bbox {
+		f.bbox[i] = f.scale(int(int16(binary.BigEndian.Uint16(head[36+i*2:]))))
+	}
+	f.ascent = f.scale(int(int16(binary.BigEndian.Uint16(hhea[4:]))))
+	f.descent = f.scale(int(int16(binary.BigEndian.Uint16(hhea[6:]))))
+
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if metrics == 0 || len(hmtx) < metrics*4 {
+		return nil, errBadFont
+	}
+	f.advances = make([]uint16, metrics)
+	for i := range f.advances {
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[i*4:])
+	}
+
+	// используется таблица Unicode BMP формата 4: Windows (3, 1) или Unicode (0, *)
+	for i := 0; i < int(binary.BigEndian.Uint16(cmap[2:])); i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform, encoding := binary.BigEndian.Uint16(cmap[rec:]), binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if (platform == 3 && encoding == 1 || platform == 0) && offset+14 <= len(cmap) && binary.BigEndian.Uint16(cmap[offset:]) == 4 {
+			f.cmap = cmap[offset:]
+			break
+		}
+	}
+	if f.cmap == nil {
+		return nil, errBadFont
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	f.compressed = buf.Bytes()
+
+	return f, nil
+}
+
+// scale переводит единицы шрифта в тысячные доли кегля, принятые в PDF
+func (f *trueType) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// glyph возвращает номер глифа для символа; 0 — глиф отсутствующего символа
+func (f *trueType) glyph(r rune) uint16 {
+	if r < 0 || r > 0xFFFF {
+		return 0
+	}
+	c := uint16(r)
+
+	segments := int(binary.BigEndian.Uint16(f.cmap[6:])) / 2
+	ends := 14
+	starts := ends + segments*2 + 2
+	deltas := starts + segments*2
+	rangeOffsets := deltas + segments*2
+	if rangeOffsets+segments*2 > len(f.cmap) {
+		return 0
+	}
+
+	for i := 0; i < segments; i++ {
+		if binary.BigEndian.Uint16(f.cmap[ends+i*2:]) < c {
+			continue
+		}
+		start := binary.BigEndian.Uint16(f.cmap[starts+i*2:])
+		if start > c {
+			return 0
+		}
+		delta := binary.BigEndian.Uint16(f.cmap[deltas+i*2:])
+		rangeOffset := int(binary.BigEndian.Uint16(f.cmap[rangeOffsets+i*2:]))
+		if rangeOffset == 0 {
+			return c + delta
+		}
+
+		at := rangeOffsets + i*2 + rangeOffset + int(c-start)*2
+		if at+2 > len(f.cmap) {
+			return 0
+		}
+		if g := binary.BigEndian.Uint16(f.cmap[at:]); g != 0 {
+			return g + delta
+		}
+		return 0
+	}
+
+	return 0
+}
+
+// width возвращает ширину глифа в тысячных долях кегля
+func (f *trueType) width(g uint16) int {
+	if int(g) >= len(f.advances) {
+		g = uint16(len(f.advances) - 1)
+	}
+	return f.scale(int(f.advances[g]))
+}
+
+// fontEncoder кодирует строки номерами глифов (Identity-H) и запоминает использованные символы
+// для таблицы ширин и обратного отображения в Unicode
+type fontEncoder struct {
+	font *trueType
+	used map[uint16]rune
+}
+
+func newFontEncoder(f *trueType) *fontEncoder {
+	return &fontEncoder{font: f, used: make(map[uint16]rune)}
+}
+
+// text возвращает строку для оператора Tj в шестнадцатеричном виде
+func (e *fontEncoder) text(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r < 32 {
+			r = ' '
+		}
+		g := e.font.glyph(r)
+		if g == 0 {
+			r = '\uFFFD'
+		}
+		if _, ok := e.used[g]; !ok {
+			e.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", g)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func (e *fontEncoder) glyphs() []uint16 {
+	glyphs := make([]uint16, 0, len(e.used))
+	for g := range e.used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// objects возвращает объекты шрифта PDF начиная с номера first: составной шрифт Type0,
+// шрифт CIDFontType2, описание шрифта, сжатый файл шрифта и таблицу ToUnicode
+func (e *fontEncoder) objects(first int) []string {
+	f := e.font
+	glyphs := e.glyphs()
+
+	var widths, unicode strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, f.width(g))
+		fmt.Fprintf(&unicode, "<%04X> <%04X>\n", g, e.used[g])
+	}
+
+	cmap := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		fmt.Sprintf("%d beginbfchar\n%sendbfchar\n", len(glyphs), unicode.String()) +
+		"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			fontName, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW %d /W [%s] >>",
+			fontName, first+2, f.width(0), strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 "+
+			"/Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			fontName, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], f.ascent, f.descent, f.ascent, first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(f.compressed), len(fontData), f.compressed),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(cmap), cmap),
+	}
+}
@@ -0,0 +1,135 @@
+package valuation
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	MethodFIFO            = "fifo"
+	MethodWeightedAverage = "wac"
+)
+
+var ErrUnknownMethod = errors.New("unknown valuation method")
+
+// Movement — изменение остатка в порядке журнала. UnitCost задан у поступлений с известной ценой;
+// поступления без цены (начальный остаток, возвраты, излишки) оцениваются по текущей себестоимости.
+type Movement struct {
+	Delta    int
+	UnitCost *float64
+}
+
+// Result — оценка остатка: количество, стоимость и средняя себестоимость единицы
+type Result struct {
+	Quantity int
+	Value    float64
+	UnitCost float64
+}
+
+// Value оценивает остаток по журналу движений выбранным методом
+func Value(method string, movements []Movement) (Result, error) {
+	switch method {
+	case MethodFIFO:
+		return FIFO(movements), nil
+	case MethodWeightedAverage:
+		return WeightedAverage(movements), nil
+	}
+
+	return Result{}, ErrUnknownMethod
+}
+
+type layer struct {
+	quantity int
+	cost     float64
+}
+
+// FIFO оценивает остаток по слоям поступлений: списания уменьшают самые старые слои.
+// Поступление без цены становится новым слоем по цене последней списанной единицы,
+// а если списаний еще не было — по цене самого нового слоя.
+func FIFO(movements []Movement) Result {
+	var layers []layer
+	var lastIssued float64
+	issued := false
+	for _, m := range movements {
+		switch {
+		case m.Delta > 0:
+			cost := 0.0
+			switch {
+			case m.UnitCost != nil:
+				cost = *m.UnitCost
+			case issued:
+				cost = lastIssued
+			case len(layers) > 0:
+				cost = layers[len(layers)-1].cost
+			}
+			layers = append(layers, layer{quantity: m.Delta, cost: cost})
+
+		case m.Delta < 0:
+			// остаток не оценивается ниже нуля
+			for n := -m.Delta; n > 0 && len(layers) > 0; {
+				l := &layers[0]
+				take := n
+				if take > l.quantity {
+					take = l.quantity
+				}
+				l.quantity -= take
+				n -= take
+				lastIssued, issued = l.cost, true
+				if l.quantity == 0 {
+					layers = layers[1:]
+				}
+			}
+		}
+	}
+
+	var r Result
+	for _, l := range layers {
+		r.Quantity += l.quantity
+		r.Value += float64(l.quantity) * l.cost
+	}
+
+	return r.finish()
+}
+
+// WeightedAverage оценивает остаток по скользящей средней: каждое поступление пересчитывает
+// среднюю себестоимость, списания ее не меняют. Поступление без цены оценивается по текущей средней.
+func WeightedAverage(movements []Movement) Result {
+	var r Result
+	var average float64
+	for _, m := range movements {
+		switch {
+		case m.Delta > 0:
+			cost := average
+			if m.UnitCost != nil {
+				cost = *m.UnitCost
+			}
+			r.Quantity += m.Delta
+			r.Value += float64(m.Delta) * cost
+			average = r.Value / float64(r.Quantity)
+
+		case m.Delta < 0:
+			n := -m.Delta
+			if n >= r.Quantity {
+				r.Quantity, r.Value = 0, 0
+				continue
+			}
+			r.Quantity -= n
+			r.Value = float64(r.Quantity) * average
+		}
+	}
+
+	return r.finish()
+}
+
+func (r Result) finish() Result {
+	r.Value = round(r.Value)
+	if r.Quantity > 0 {
+		r.UnitCost = round(r.Value / float64(r.Quantity))
+	}
+	return r
+}
+
+// round округляет стоимость до четырех знаков, как она хранится в базе
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,67 @@
+package valuation
+
+import (
+	"errors"
+	"testing"
+)
+
+func cost(v float64) *float64 {
+	return &v
+}
+
+func TestFIFO(t *testing.T) {
+	movements := []Movement{
+		{Delta: 10, UnitCost: cost(2)},
+		{Delta: 5, UnitCost: cost(3)},
+		{Delta: -12},
+	}
+	r := FIFO(movements)
+	if r.Quantity != 3 || r.Value != 9 || r.UnitCost != 3 {
+		t.Errorf("Expected 3 units worth 9, got %+v", r)
+	}
+
+	// возврат без цены встает по цене последней списанной единицы
+	movements = append(movements, Movement{Delta: 1}, Movement{Delta: -3})
+	r = FIFO(movements)
+	if r.Quantity != 1 || r.Value != 3 {
+		t.Errorf("Expected 1 unit worth 3, got %+v", r)
+	}
+}
+
+func TestFIFOUncostedOpeningStock(t *testing.T) {
+	r := FIFO([]Movement{{Delta: 4}, {Delta: 2, UnitCost: cost(1.5)}, {Delta: -5}})
+	if r.Quantity != 1 || r.Value != 1.5 {
+		t.Errorf("Expected 1 unit worth 1.5, got %+v", r)
+	}
+}
+
+func TestWeightedAverage(t *testing.T) {
+	r := WeightedAverage([]Movement{
+		{Delta: 10, UnitCost: cost(2)},
+		{Delta: -5},
+		{Delta: 5, UnitCost: cost(4)},
+	})
+	if r.Quantity != 10 || r.Value != 30 || r.UnitCost != 3 {
+		t.Errorf("Expected 10 units worth 30, got %+v", r)
+	}
+
+	r = WeightedAverage([]Movement{
+		{Delta: 3, UnitCost: cost(1)},
+		{Delta: -3},
+		{Delta: 2},
+	})
+	if r.Quantity != 2 || r.Value != 2 {
+		t.Errorf("Expected uncosted receipt at the last average, got %+v", r)
+	}
+
+	r = WeightedAverage([]Movement{{Delta: 3, UnitCost: cost(1)}, {Delta: -5}})
+	if r.Quantity != 0 || r.Value != 0 {
+		t.Errorf("Expected stock not to go below zero, got %+v", r)
+	}
+}
+
+func TestValueUnknownMethod(t *testing.T) {
+	if _, err := Value("lifo", nil); !errors.Is(err, ErrUnknownMethod) {
+		t.Errorf("Expected ErrUnknownMethod, got %v", err)
+	}
+}
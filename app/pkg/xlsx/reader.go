@@ -0,0 +1,235 @@
+// Package xlsx читает и пишет простые таблицы в формате Office Open XML (XLSX)
+// без сторонних зависимостей: одна таблица, строковые и числовые значения, без стилей и формул.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidFile = errors.New("invalid xlsx file")
+
+type workbookXML struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationshipsXML struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type richTextXML struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (r richTextXML) text() string {
+	if len(r.Runs) == 0 {
+		return r.T
+	}
+
+	var b strings.Builder
+	for _, run := range r.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+type sharedStringsXML struct {
+	Items []richTextXML `xml:"si"`
+}
+
+type sheetXML struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string      `xml:"r,attr"`
+			Type   string      `xml:"t,attr"`
+			Value  string      `xml:"v"`
+			Inline richTextXML `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Пределы листа Excel: столбцы до XFD и строки до 1048576. Общее число ячеек, включая пустые,
+// которыми дополняются пропуски, ограничено, чтобы файл с далекими адресами не занял всю память.
+const (
+	maxColumns = 16384
+	maxRows    = 1048576
+	maxCells   = 1 << 22
+)
+
+// Row — строка листа с номером из файла (с единицы)
+type Row struct {
+	Number int
+	Cells  []string
+}
+
+// ReadRows читает все строки первого листа книги. Пропущенные ячейки возвращаются пустыми строками,
+// пропущенные строки не возвращаются, номер строки берется из атрибута r.
+func ReadRows(data []byte) ([]Row, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidFile
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared sharedStringsXML
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeFile(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrInvalidFile
+	}
+	var sheet sheetXML
+	if err := decodeFile(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(sheet.Rows))
+	number, cells := 0, 0
+	for _, r := range sheet.Rows {
+		if r.Number != 0 {
+			if r.Number <= number || r.Number > maxRows {
+				return nil, ErrInvalidFile
+			}
+			number = r.Number
+		} else {
+			number++
+		}
+
+		var row []string
+		for i, c := range r.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if col >= maxColumns {
+				return nil, ErrInvalidFile
+			}
+			if col >= len(row) {
+				if cells += col + 1 - len(row); cells > maxCells {
+					return nil, ErrInvalidFile
+				}
+				row = append(row, make([]string, col+1-len(row))...)
+			}
+
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(shared.Items) {
+					return nil, ErrInvalidFile
+				}
+				row[col] = shared.Items[idx].text()
+			case "inlineStr":
+				row[col] = c.Inline.text()
+			default:
+				row[col] = c.Value
+			}
+		}
+		rows = append(rows, Row{Number: number, Cells: row})
+	}
+
+	return rows, nil
+}
+
+// firstSheetPath находит файл первого листа по описанию книги и ее связям
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	wf, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", ErrInvalidFile
+	}
+	var wb workbookXML
+	if err := decodeFile(wf, &wb); err != nil {
+		return "", err
+	}
+
+	rf, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok || len(wb.Sheets) == 0 {
+		return fallback, nil
+	}
+	var rels relationshipsXML
+	if err := decodeFile(rf, &rels); err != nil {
+		return "", err
+	}
+
+	for _, r := range rels.Relationships {
+		if r.ID != wb.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(r.Target, "/") {
+			return strings.TrimPrefix(r.Target, "/"), nil
+		}
+		return path.Join("xl", r.Target), nil
+	}
+
+	return fallback, nil
+}
+
+func decodeFile(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return ErrInvalidFile
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v); err != nil {
+		return ErrInvalidFile
+	}
+
+	return nil
+}
+
+// maxPartSize ограничивает размер распакованной части книги
+const maxPartSize = 256 << 20
+
+// columnIndex возвращает номер столбца (с нуля) по адресу ячейки вида "AB12".
+// Столбцы после XFD отклоняются.
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > maxColumns {
+			return 0, ErrInvalidFile
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, ErrInvalidFile
+	}
+
+	return col - 1, nil
+}
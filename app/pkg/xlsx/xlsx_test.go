@@ -0,0 +1,120 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func buildBook(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadRows(t *testing.T) {
+	data := buildBook(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Products" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId7" Target="worksheets/products.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>code</t></si><si><r><t>Snea</t></r><r><t>kers</t></r></si></sst>`,
+		"xl/worksheets/products.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>name</t></is></c></row>
+			<row r="2"><c r="A2"><v>4006381333931</v></c><c r="C2" t="s"><v>1</v></c></row>
+			</sheetData></worksheet>`,
+	})
+
+	rows, err := ReadRows(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Row{{Number: 1, Cells: []string{"code", "name"}}, {Number: 2, Cells: []string{"4006381333931", "", "Sneakers"}}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadRows() = %+v, want %+v", rows, want)
+	}
+
+	if _, err := ReadRows([]byte("name,code")); err != ErrInvalidFile {
+		t.Errorf("expected ErrInvalidFile, got %v", err)
+	}
+}
+
+func TestReadRowsLimits(t *testing.T) {
+	book := func(sheetData string) []byte {
+		return buildBook(t, map[string]string{
+			"xl/workbook.xml":          `<workbook/>`,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`,
+		})
+	}
+
+	rows, err := ReadRows(book(`<row r="1"><c r="A1"><v>code</v></c></row><row r="5"><c r="B5"><v>1</v></c></row>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1].Number != 5 || len(rows[1].Cells) != 2 {
+		t.Errorf("Expected the second row to keep number 5, got %+v", rows)
+	}
+
+	invalid := map[string]string{
+		"column past XFD":   `<row r="1"><c r="XFE1"><v>1</v></c></row>`,
+		"huge column":       `<row r="1"><c r="ZZZZZZZZZZZZZZ1"><v>1</v></c></row>`,
+		"row past limit":    `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`,
+		"rows out of order": `<row r="2"><c r="A2"><v>1</v></c></row><row r="1"><c r="A1"><v>1</v></c></row>`,
+		"too many cells":    strings.Repeat(`<row><c r="XFD1"><v>1</v></c></row>`, maxCells/maxColumns+1),
+	}
+	for name, sheetData := range invalid {
+		if _, err := ReadRows(book(sheetData)); err != ErrInvalidFile {
+			t.Errorf("%s: expected ErrInvalidFile, got %v", name, err)
+		}
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Stock & more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("code", "quantity", "bundle"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("<SKU>", 5, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadRows(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Row{{Number: 1, Cells: []string{"code", "quantity", "bundle"}}, {Number: 2, Cells: []string{"<SKU>", "5", "1"}}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadRows() = %+v, want %+v", rows, want)
+	}
+
+	if got := columnName(27); got != "AB" {
+		t.Errorf("columnName(27) = %q, want AB", got)
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS stock_movements CASCADE;
+
+DROP TABLE IF EXISTS cycle_count_lines CASCADE;
+
+DROP TABLE IF EXISTS cycle_counts CASCADE;
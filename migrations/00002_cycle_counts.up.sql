@@ -0,0 +1,31 @@
+CREATE TABLE cycle_counts (
+  id SERIAL PRIMARY KEY,
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id),
+  status TEXT NOT NULL DEFAULT 'open',
+  blind BOOLEAN NOT NULL DEFAULT FALSE,
+  freeze_reservations BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  closed_at TIMESTAMPTZ
+);
+
+CREATE TABLE cycle_count_lines (
+  id SERIAL PRIMARY KEY,
+  cycle_count_id INTEGER NOT NULL REFERENCES cycle_counts(id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  expected_quantity INTEGER NOT NULL,
+  counted_quantity INTEGER,
+  UNIQUE (cycle_count_id, product_id)
+);
+
+CREATE TABLE stock_movements (
+  id SERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  quantity_delta INTEGER NOT NULL,
+  reason TEXT NOT NULL,
+  reference TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_cycle_counts_warehouse_status ON cycle_counts (warehouse_id, status);
+CREATE INDEX idx_cycle_count_lines_product ON cycle_count_lines (product_id);
+CREATE INDEX idx_stock_movements_product ON stock_movements (product_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bin_stock CASCADE;
+
+DROP TABLE IF EXISTS locations CASCADE;
@@ -0,0 +1,18 @@
+CREATE TABLE locations (
+  id SERIAL PRIMARY KEY,
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id),
+  parent_id INTEGER REFERENCES locations(id),
+  kind TEXT NOT NULL CHECK (kind IN ('zone', 'aisle', 'bin')),
+  code TEXT NOT NULL,
+  UNIQUE (warehouse_id, parent_id, code)
+);
+
+CREATE TABLE bin_stock (
+  location_id INTEGER NOT NULL REFERENCES locations(id),
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  quantity INTEGER NOT NULL CHECK (quantity >= 0),
+  PRIMARY KEY (location_id, product_id)
+);
+
+CREATE INDEX idx_locations_warehouse ON locations (warehouse_id);
+CREATE INDEX idx_bin_stock_product ON bin_stock (product_id);
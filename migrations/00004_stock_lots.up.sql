@@ -0,0 +1,12 @@
+CREATE TABLE stock_lots (
+  id SERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  lot_number TEXT NOT NULL,
+  expiry_date DATE,
+  quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+  reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (product_id, lot_number)
+);
+
+CREATE INDEX idx_stock_lots_expiry ON stock_lots (expiry_date);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS serial_events CASCADE;
+
+DROP TABLE IF EXISTS serial_numbers CASCADE;
+
+DROP TABLE IF EXISTS receipt_lines CASCADE;
+
+DROP TABLE IF EXISTS receipts CASCADE;
+
+ALTER TABLE products DROP COLUMN IF EXISTS serial_tracked;
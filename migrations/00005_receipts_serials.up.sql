@@ -0,0 +1,45 @@
+ALTER TABLE products ADD COLUMN serial_tracked BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE receipts (
+  id SERIAL PRIMARY KEY,
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id),
+  reference TEXT,
+  status TEXT NOT NULL DEFAULT 'open',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  received_at TIMESTAMPTZ
+);
+
+CREATE TABLE receipt_lines (
+  id SERIAL PRIMARY KEY,
+  receipt_id INTEGER NOT NULL REFERENCES receipts(id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products(id),
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  location_id INTEGER REFERENCES locations(id),
+  lot_number TEXT,
+  expiry_date DATE,
+  serials TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE serial_numbers (
+  id SERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  serial TEXT NOT NULL UNIQUE,
+  status TEXT NOT NULL DEFAULT 'in_stock',
+  location_id INTEGER REFERENCES locations(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE serial_events (
+  id SERIAL PRIMARY KEY,
+  serial_id INTEGER NOT NULL REFERENCES serial_numbers(id) ON DELETE CASCADE,
+  event TEXT NOT NULL,
+  warehouse_id INTEGER,
+  location_id INTEGER,
+  reference TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_receipts_warehouse_status ON receipts (warehouse_id, status);
+CREATE INDEX idx_receipt_lines_receipt ON receipt_lines (receipt_id);
+CREATE INDEX idx_serial_numbers_product_status ON serial_numbers (product_id, status);
+CREATE INDEX idx_serial_events_serial ON serial_events (serial_id, created_at);
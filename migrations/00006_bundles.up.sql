@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN is_bundle BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE bundle_components (
+  bundle_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  component_id INTEGER NOT NULL REFERENCES products(id),
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  PRIMARY KEY (bundle_id, component_id)
+);
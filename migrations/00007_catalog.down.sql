@@ -0,0 +1,9 @@
+ALTER TABLE products DROP COLUMN IF EXISTS size_id;
+
+ALTER TABLE products DROP COLUMN IF EXISTS style_id;
+
+DROP TABLE IF EXISTS styles CASCADE;
+
+DROP TABLE IF EXISTS size_grid CASCADE;
+
+DROP TABLE IF EXISTS categories CASCADE;
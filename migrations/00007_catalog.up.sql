@@ -0,0 +1,32 @@
+CREATE TABLE categories (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE size_grid (
+  id SERIAL PRIMARY KEY,
+  category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+  label TEXT NOT NULL,
+  eu TEXT,
+  us TEXT,
+  ru TEXT,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  UNIQUE (category_id, label)
+);
+
+CREATE TABLE styles (
+  id SERIAL PRIMARY KEY,
+  code TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  category_id INTEGER NOT NULL REFERENCES categories(id),
+  brand TEXT NOT NULL,
+  color TEXT,
+  gender TEXT NOT NULL CHECK (gender IN ('men', 'women', 'unisex', 'kids')),
+  season TEXT
+);
+
+ALTER TABLE products ADD COLUMN style_id INTEGER REFERENCES styles(id);
+ALTER TABLE products ADD COLUMN size_id INTEGER REFERENCES size_grid(id);
+
+CREATE INDEX idx_products_style ON products (style_id);
+CREATE INDEX idx_styles_brand ON styles (brand);
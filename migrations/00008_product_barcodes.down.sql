@@ -0,0 +1,3 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_code_not_empty;
+
+DROP TABLE IF EXISTS product_barcodes CASCADE;
@@ -0,0 +1,9 @@
+CREATE TABLE product_barcodes (
+  barcode TEXT PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  format TEXT NOT NULL
+);
+
+CREATE INDEX idx_product_barcodes_product ON product_barcodes (product_id);
+
+ALTER TABLE products ADD CONSTRAINT products_code_not_empty CHECK (code IS NOT NULL AND btrim(code) <> '') NOT VALID;
@@ -0,0 +1,10 @@
+CREATE TABLE idempotency_keys (
+  key TEXT PRIMARY KEY,
+  request_hash TEXT NOT NULL,
+  status_code INTEGER,
+  content_type TEXT,
+  response BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at);
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS bin_stock_bump_product_version ON bin_stock;
+
+DROP TRIGGER IF EXISTS products_bump_version ON products;
+
+DROP TRIGGER IF EXISTS warehouse_bump_version ON warehouse;
+
+DROP FUNCTION IF EXISTS bump_product_version_from_bin();
+
+DROP FUNCTION IF EXISTS bump_version();
+
+ALTER TABLE products DROP COLUMN IF EXISTS version;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS version;
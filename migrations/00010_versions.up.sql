@@ -0,0 +1,32 @@
+ALTER TABLE warehouse ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- каждое изменение строки увеличивает ее версию, в том числе изменение остатка
+CREATE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER warehouse_bump_version BEFORE UPDATE ON warehouse
+  FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+CREATE TRIGGER products_bump_version BEFORE UPDATE ON products
+  FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+-- перемещение между ячейками не меняет строку товара, поэтому версию товара увеличивает триггер
+CREATE FUNCTION bump_product_version_from_bin() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    UPDATE products SET version = version WHERE id = OLD.product_id;
+  ELSE
+    UPDATE products SET version = version WHERE id = NEW.product_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bin_stock_bump_product_version AFTER INSERT OR UPDATE OR DELETE ON bin_stock
+  FOR EACH ROW EXECUTE FUNCTION bump_product_version_from_bin();
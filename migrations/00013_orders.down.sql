@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_event_failures CASCADE;
+DROP TABLE IF EXISTS consumed_messages CASCADE;
+DROP TABLE IF EXISTS orders CASCADE;
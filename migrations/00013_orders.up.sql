@@ -0,0 +1,24 @@
+CREATE TABLE orders (
+  id TEXT PRIMARY KEY,
+  status TEXT NOT NULL CHECK (status IN ('reserved', 'confirmed', 'cancelled')),
+  items TEXT[] NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE consumed_messages (
+  consumer TEXT NOT NULL,
+  message_id TEXT NOT NULL,
+  consumed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (consumer, message_id)
+);
+
+CREATE TABLE order_event_failures (
+  id BIGSERIAL PRIMARY KEY,
+  message_id TEXT NOT NULL,
+  order_id TEXT,
+  payload BYTEA NOT NULL,
+  error TEXT NOT NULL,
+  attempts INTEGER NOT NULL,
+  failed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
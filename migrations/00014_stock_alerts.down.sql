@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS stock_alerts CASCADE;
+
+ALTER TABLE products DROP COLUMN IF EXISTS safety_stock;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_point;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS default_safety_stock;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS default_reorder_point;
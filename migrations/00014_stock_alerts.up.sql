@@ -0,0 +1,18 @@
+ALTER TABLE warehouse ADD COLUMN default_reorder_point INTEGER CHECK (default_reorder_point >= 0);
+ALTER TABLE warehouse ADD COLUMN default_safety_stock INTEGER CHECK (default_safety_stock >= 0);
+
+ALTER TABLE products ADD COLUMN reorder_point INTEGER CHECK (reorder_point >= 0);
+ALTER TABLE products ADD COLUMN safety_stock INTEGER CHECK (safety_stock >= 0);
+
+CREATE TABLE stock_alerts (
+  id BIGSERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  level TEXT NOT NULL CHECK (level IN ('low_stock', 'out_of_stock')),
+  quantity INTEGER NOT NULL,
+  reorder_point INTEGER,
+  safety_stock INTEGER,
+  raised_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  resolved_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_stock_alerts_open ON stock_alerts (product_id) WHERE resolved_at IS NULL;
@@ -0,0 +1,12 @@
+CREATE TABLE backorders (
+  id BIGSERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  reference TEXT,
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  fulfilled INTEGER NOT NULL DEFAULT 0 CHECK (fulfilled >= 0 AND fulfilled <= quantity),
+  status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'fulfilled', 'cancelled')),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_backorders_waiting ON backorders (product_id, id) WHERE status = 'waiting';
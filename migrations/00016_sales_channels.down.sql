@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS channel_allocations CASCADE;
+DROP TABLE IF EXISTS sales_channels CASCADE;
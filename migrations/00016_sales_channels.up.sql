@@ -0,0 +1,17 @@
+CREATE TABLE sales_channels (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE channel_allocations (
+  channel_id INTEGER NOT NULL REFERENCES sales_channels(id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('fixed', 'percent')),
+  value INTEGER NOT NULL CHECK (value >= 0 AND (kind = 'fixed' OR value <= 100)),
+  reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (channel_id, product_id)
+);
+
+CREATE INDEX idx_channel_allocations_product ON channel_allocations (product_id);
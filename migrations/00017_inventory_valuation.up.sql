@@ -0,0 +1,2 @@
+ALTER TABLE receipt_lines ADD COLUMN unit_cost NUMERIC(14, 4) CHECK (unit_cost >= 0);
+ALTER TABLE stock_movements ADD COLUMN unit_cost NUMERIC(14, 4);
@@ -0,0 +1,13 @@
+ALTER TABLE receipts DROP COLUMN IF EXISTS capacity_exceeded;
+
+ALTER TABLE products DROP COLUMN IF EXISTS height_cm;
+ALTER TABLE products DROP COLUMN IF EXISTS width_cm;
+ALTER TABLE products DROP COLUMN IF EXISTS length_cm;
+
+ALTER TABLE styles DROP COLUMN IF EXISTS height_cm;
+ALTER TABLE styles DROP COLUMN IF EXISTS width_cm;
+ALTER TABLE styles DROP COLUMN IF EXISTS length_cm;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS capacity_policy;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS capacity_volume;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS capacity_units;
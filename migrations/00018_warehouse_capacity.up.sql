@@ -0,0 +1,14 @@
+ALTER TABLE warehouse ADD COLUMN capacity_units INTEGER CHECK (capacity_units >= 0);
+ALTER TABLE warehouse ADD COLUMN capacity_volume NUMERIC(14, 3) CHECK (capacity_volume >= 0);
+ALTER TABLE warehouse ADD COLUMN capacity_policy TEXT NOT NULL DEFAULT 'reject' CHECK (capacity_policy IN ('reject', 'warn'));
+
+-- габариты в сантиметрах; у товара без своих габаритов используются габариты модели
+ALTER TABLE styles ADD COLUMN length_cm NUMERIC(10, 2) CHECK (length_cm > 0);
+ALTER TABLE styles ADD COLUMN width_cm NUMERIC(10, 2) CHECK (width_cm > 0);
+ALTER TABLE styles ADD COLUMN height_cm NUMERIC(10, 2) CHECK (height_cm > 0);
+
+ALTER TABLE products ADD COLUMN length_cm NUMERIC(10, 2) CHECK (length_cm > 0);
+ALTER TABLE products ADD COLUMN width_cm NUMERIC(10, 2) CHECK (width_cm > 0);
+ALTER TABLE products ADD COLUMN height_cm NUMERIC(10, 2) CHECK (height_cm > 0);
+
+ALTER TABLE receipts ADD COLUMN capacity_exceeded BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_products_style_size;
+
+ALTER TABLE warehouse DROP CONSTRAINT IF EXISTS warehouse_coordinates_check;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS longitude;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS latitude;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS address;
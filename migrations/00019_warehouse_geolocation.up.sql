@@ -0,0 +1,7 @@
+ALTER TABLE warehouse ADD COLUMN address TEXT;
+ALTER TABLE warehouse ADD COLUMN latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE warehouse ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180);
+ALTER TABLE warehouse ADD CONSTRAINT warehouse_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- один и тот же размер модели на разных складах — взаимозаменяемые товары
+CREATE INDEX idx_products_style_size ON products (style_id, size_id);
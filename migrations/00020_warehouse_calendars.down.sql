@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS maintenance_windows;
+DROP TABLE IF EXISTS warehouse_holidays;
+DROP TABLE IF EXISTS warehouse_hours;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS calendar_open;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS timezone;
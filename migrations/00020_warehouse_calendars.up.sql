@@ -0,0 +1,33 @@
+ALTER TABLE warehouse ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+-- состояние по календарю, которое планировщик применил последним
+ALTER TABLE warehouse ADD COLUMN calendar_open BOOLEAN NOT NULL DEFAULT true;
+
+CREATE TABLE warehouse_hours (
+  id SERIAL PRIMARY KEY,
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+  weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+  opens TIME NOT NULL,
+  closes TIME NOT NULL,
+  CHECK (opens < closes)
+);
+
+CREATE INDEX idx_warehouse_hours_warehouse ON warehouse_hours (warehouse_id);
+
+CREATE TABLE warehouse_holidays (
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+  holiday DATE NOT NULL,
+  name TEXT,
+  PRIMARY KEY (warehouse_id, holiday)
+);
+
+CREATE TABLE maintenance_windows (
+  id SERIAL PRIMARY KEY,
+  warehouse_id INTEGER NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+  starts_at TIMESTAMPTZ NOT NULL,
+  ends_at TIMESTAMPTZ NOT NULL,
+  reason TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (starts_at < ends_at)
+);
+
+CREATE INDEX idx_maintenance_windows_warehouse ON maintenance_windows (warehouse_id, ends_at);
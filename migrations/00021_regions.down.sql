@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_warehouse_region;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS region_id;
+
+DROP TABLE IF EXISTS regions;
@@ -0,0 +1,15 @@
+-- регионы образуют дерево; регион верхнего уровня — сеть складов
+CREATE TABLE regions (
+  id SERIAL PRIMARY KEY,
+  code TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  parent_id INTEGER REFERENCES regions(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (parent_id <> id)
+);
+
+CREATE INDEX idx_regions_parent ON regions (parent_id);
+
+ALTER TABLE warehouse ADD COLUMN region_id INTEGER REFERENCES regions(id);
+
+CREATE INDEX idx_warehouse_region ON warehouse (region_id);
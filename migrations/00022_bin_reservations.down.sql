@@ -0,0 +1,2 @@
+ALTER TABLE bin_stock DROP COLUMN IF EXISTS reserved;
+ALTER TABLE products DROP COLUMN IF EXISTS reserved;
@@ -0,0 +1,3 @@
+-- зарезервированные, но еще не отобранные единицы: по товару и по ячейкам, где они лежат
+ALTER TABLE products ADD COLUMN reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0);
+ALTER TABLE bin_stock ADD COLUMN reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS product_barcodes_check_code_unique ON product_barcodes;
+
+DROP TRIGGER IF EXISTS products_check_code_unique ON products;
+
+DROP FUNCTION IF EXISTS check_code_unique();
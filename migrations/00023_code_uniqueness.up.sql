@@ -0,0 +1,24 @@
+-- код товара и штрихкод ищутся одним запросом, поэтому значение не может быть одновременно
+-- кодом одного товара и штрихкодом другого; блокировка по значению закрывает гонку двух вставок
+CREATE FUNCTION check_code_unique() RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_TABLE_NAME = 'products' THEN
+    PERFORM pg_advisory_xact_lock(hashtext(NEW.code));
+    IF EXISTS (SELECT 1 FROM product_barcodes WHERE barcode = NEW.code) THEN
+      RAISE EXCEPTION 'code % is already used as a barcode', NEW.code USING ERRCODE = 'unique_violation';
+    END IF;
+  ELSE
+    PERFORM pg_advisory_xact_lock(hashtext(NEW.barcode));
+    IF EXISTS (SELECT 1 FROM products WHERE code = NEW.barcode) THEN
+      RAISE EXCEPTION 'barcode % is already used as a product code', NEW.barcode USING ERRCODE = 'unique_violation';
+    END IF;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_check_code_unique BEFORE INSERT OR UPDATE OF code ON products
+  FOR EACH ROW EXECUTE FUNCTION check_code_unique();
+
+CREATE TRIGGER product_barcodes_check_code_unique BEFORE INSERT OR UPDATE OF barcode ON product_barcodes
+  FOR EACH ROW EXECUTE FUNCTION check_code_unique();
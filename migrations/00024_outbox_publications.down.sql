@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_outbox_events_published_at;
+
+DROP INDEX IF EXISTS idx_outbox_events_unpublished_key;
+
+DROP TABLE IF EXISTS outbox_publications;
@@ -0,0 +1,17 @@
+-- прогресс доставки события каждому получателю: событие публикуется, когда его приняли все,
+-- а получатели, уже принявшие событие, не получают его повторно
+CREATE TABLE outbox_publications (
+  publisher TEXT NOT NULL,
+  event_id BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  retry_at TIMESTAMPTZ,
+  published_at TIMESTAMPTZ,
+  PRIMARY KEY (publisher, event_id)
+);
+
+CREATE INDEX idx_outbox_publications_event ON outbox_publications (event_id);
+
+CREATE INDEX idx_outbox_events_unpublished_key ON outbox_events (aggregate_key, id) WHERE published_at IS NULL;
+
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at) WHERE published_at IS NOT NULL;
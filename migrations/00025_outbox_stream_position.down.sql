@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_outbox_events_stream_position;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS stream_position;
+
+DROP SEQUENCE IF EXISTS outbox_stream_position_seq;
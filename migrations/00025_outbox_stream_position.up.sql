@@ -0,0 +1,11 @@
+-- позиция события в потоке остатков: назначается при публикации под блокировкой пересылки,
+-- поэтому растет в порядке, в котором события становятся видны клиентам потока
+CREATE SEQUENCE outbox_stream_position_seq;
+
+ALTER TABLE outbox_events ADD COLUMN stream_position BIGINT;
+
+UPDATE outbox_events SET stream_position = id WHERE published_at IS NOT NULL;
+
+SELECT setval('outbox_stream_position_seq', COALESCE((SELECT MAX(id) FROM outbox_events), 0) + 1, false);
+
+CREATE UNIQUE INDEX idx_outbox_events_stream_position ON outbox_events (stream_position) WHERE stream_position IS NOT NULL;
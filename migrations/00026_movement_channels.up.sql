@@ -0,0 +1,2 @@
+-- канал продаж, от имени которого зарезервирована или возвращена единица
+ALTER TABLE stock_movements ADD COLUMN channel_id INTEGER REFERENCES sales_channels(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE warehouse DROP COLUMN IF EXISTS calendar_checked_at;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS calendar_closed;
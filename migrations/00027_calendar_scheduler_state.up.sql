@@ -0,0 +1,8 @@
+-- склад выключил планировщик при закрытии по календарю: только такой склад он включает при открытии,
+-- склад, выключенный вручную, остается выключенным
+ALTER TABLE warehouse ADD COLUMN calendar_closed BOOLEAN NOT NULL DEFAULT false;
+-- момент последнего запуска планировщика: окна обслуживания, целиком прошедшие между запусками,
+-- тоже порождают события warehouse.offline и warehouse.online
+ALTER TABLE warehouse ADD COLUMN calendar_checked_at TIMESTAMPTZ;
+
+UPDATE warehouse SET calendar_closed = true WHERE NOT calendar_open AND NOT COALESCE(is_available, false);